@@ -41,7 +41,10 @@ type GatewayStatus struct {
 	Phase              corev1.PodPhase              `json:"phase,omitempty"`
 	Gateway            []GatewayState               `json:"gateway,omitempty"`
 	ObservedGeneration int64                        `json:"observedGeneration,omitempty"`
+	RepositoryRef      string                       `json:"repositoryRef,omitempty"`
 	CommitID           string                       `json:"commitId,omitempty"`
+	BundleCommitID     string                       `json:"bundleCommitId,omitempty"`
+	RolloutCommitID    string                       `json:"rolloutCommitId,omitempty"`
 	Ready              int32                        `json:"ready,omitempty"`
 	State              string                       `json:"state,omitempty"`
 	Replicas           int32                        `json:"replicas,omitempty"`
@@ -49,6 +52,80 @@ type GatewayStatus struct {
 	Image              string                       `json:"image,omitempty"`
 	LabelSelectorPath  string                       `json:"labelSelectorPath,omitempty"`
 	ManagementPod      string                       `json:"managementPod,omitempty"`
+	// Repositories are the commits of the referenced Repositories being applied to the Gateway pods
+	Repositories []RepositoryReferenceStatus `json:"repositories,omitempty"`
+	// RepositoryConflicts are the entities defined by more than one of the Repositories being applied, those
+	// with a reason in Repositories aren't included
+	RepositoryConflicts []RepositoryConflict `json:"repositoryConflicts,omitempty"`
+	// BundleErrors are the problems found validating bundles, bundles aren't delivered until they're fixed
+	BundleErrors []BundleError `json:"bundleErrors,omitempty"`
+	// PendingChanges are the changes a commit held back by a dry run would make to the Gateway
+	PendingChanges *BundleDiff `json:"pendingChanges,omitempty"`
+	// GraphmanChecksum identifies the Graphman ConfigMap bundles applied to the Gateway pods, in dry run mode
+	// it only changes once the checksum of the changed bundles is approved
+	GraphmanChecksum string `json:"graphmanChecksum,omitempty"`
+	// PendingGraphmanChanges are the changes held back Graphman ConfigMap bundles would make in dry run
+	// mode, its commitId is their checksum
+	PendingGraphmanChanges *BundleDiff `json:"pendingGraphmanChanges,omitempty"`
+	// TrustedCerts are the certificates from spec.app.trustedCerts and when they expire
+	TrustedCerts []TrustedCertStatus `json:"trustedCerts,omitempty"`
+	// JDBCConnections reports whether the connections in spec.app.jdbcConnections exist on the Gateway
+	JDBCConnections []JDBCConnectionStatus `json:"jdbcConnections,omitempty"`
+	// ClusterProperties are the cluster properties applied live, see spec.app.cwp.live
+	ClusterProperties *ClusterPropertiesStatus `json:"clusterProperties,omitempty"`
+	// TemplateChecksum identifies the template values the bundles applied live were resolved with
+	TemplateChecksum string `json:"templateChecksum,omitempty"`
+}
+
+// ClusterPropertiesStatus is the Gateway generation and checksum of the cluster properties every ready pod
+// has. Names are the properties the operator manages, those removed from the spec are deleted from the pods.
+type ClusterPropertiesStatus struct {
+	Generation int64    `json:"generation,omitempty"`
+	Checksum   string   `json:"checksum,omitempty"`
+	Names      []string `json:"names,omitempty"`
+}
+
+// JDBCConnectionStatus is created or missing as found on Pod, or unknown when the Gateway's Restman API
+// isn't enabled or no pod is ready
+type JDBCConnectionStatus struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+	Pod   string `json:"pod,omitempty"`
+}
+
+// TrustedCertStatus is the expiry of a trusted certificate, Expiring is set within 30 days of NotAfter
+type TrustedCertStatus struct {
+	Name     string      `json:"name"`
+	Subject  string      `json:"subject"`
+	NotAfter metav1.Time `json:"notAfter"`
+	Expiring bool        `json:"expiring,omitempty"`
+}
+
+// BundleError is a problem found in a bundle file. Source is repository, repositories/<name> or configmaps/<name>.
+type BundleError struct {
+	Source string `json:"source"`
+	File   string `json:"file"`
+	Error  string `json:"error"`
+}
+
+// BundleDiff summarises the entities a commit would create, update or delete, computed against Pod
+// at ComputedAt. It's recomputed every minute while the commit waits. Changes lists at most 200 entities.
+type BundleDiff struct {
+	CommitID   string         `json:"commitId"`
+	Pod        string         `json:"pod,omitempty"`
+	ComputedAt metav1.Time    `json:"computedAt,omitempty"`
+	Created    int32          `json:"created"`
+	Updated    int32          `json:"updated"`
+	Deleted    int32          `json:"deleted"`
+	Changes    []EntityChange `json:"changes,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// EntityChange is an entity a commit would create, update or delete
+type EntityChange struct {
+	Action string `json:"action"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
 }
 
 type GatewayContainerState struct {
@@ -81,6 +158,22 @@ type GatewayState struct {
 	ResponseTime string          `json:"responseTime,omitempty"`
 	Ready        bool            `json:"ready"`
 	StartTime    string          `json:"startTime,omitempty"`
+	CommitID     string          `json:"commitId,omitempty"`
+	SyncStatus   string          `json:"syncStatus,omitempty"`
+	SyncError    string          `json:"syncError,omitempty"`
+	// BundleChecksum identifies the Graphman ConfigMap bundles last applied to this pod
+	BundleChecksum  string `json:"bundleChecksum,omitempty"`
+	EntitiesApplied int32  `json:"entitiesApplied,omitempty"`
+	EntitiesFailed  int32  `json:"entitiesFailed,omitempty"`
+	// RepositoryCommits are the commits of the referenced Repositories last applied to this pod
+	RepositoryCommits []RepositoryCommit `json:"repositoryCommits,omitempty"`
+	// ClusterPropertiesChecksum identifies the cluster properties last applied live to this pod
+	ClusterPropertiesChecksum string `json:"clusterPropertiesChecksum,omitempty"`
+}
+
+type RepositoryCommit struct {
+	Name     string `json:"name"`
+	CommitID string `json:"commitId"`
 }
 
 type Management struct {
@@ -92,6 +185,19 @@ type Management struct {
 	Restman    Restman  `json:"restman,omitempty"`
 	Graphman   Graphman `json:"graphman,omitempty"`
 	Service    Service  `json:"service,omitempty"`
+	// CA verifies the certificate of the Restman and Graphman APIs of the Gateway pods. Without one the operator
+	// doesn't verify their certificate and reports it with a ManagementCertificateVerified condition set to False.
+	CA ManagementCA `json:"ca,omitempty"`
+}
+
+// ManagementCA is the ConfigMap or Secret holding a PEM CA bundle under Key, which defaults to ca.crt.
+// The operator connects to the Gateway pods by IP, ServerName is the name verified in their certificate
+// if it doesn't include the pod IP.
+type ManagementCA struct {
+	ConfigMapName string `json:"configMapName,omitempty"`
+	SecretName    string `json:"secretName,omitempty"`
+	Key           string `json:"key,omitempty"`
+	ServerName    string `json:"serverName,omitempty"`
 }
 
 type Restman struct {
@@ -100,6 +206,9 @@ type Restman struct {
 
 type Graphman struct {
 	Enabled bool `json:"enabled,omitempty"`
+	// ConfigMaps lists ConfigMaps whose keys are Graphman JSON bundles that are installed on every ready Gateway pod.
+	// Keys ending in .delete.json are sent as delete mutations instead. Entities no longer in any bundle are deleted.
+	ConfigMaps []string `json:"configMaps,omitempty"`
 }
 
 type Bundle struct {
@@ -157,7 +266,7 @@ type App struct {
 	Replicas           int32                         `json:"replicas,omitempty"`
 	Service            Service                       `json:"service,omitempty"`
 	Bundle             []Bundle                      `json:"bundle,omitempty"`
-	Repository         Repository                    `json:"repository,omitempty"`
+	Repository         GatewayRepository             `json:"repository,omitempty"`
 	Ingress            Ingress                       `json:"ingress,omitempty"`
 	Sidecars           []corev1.Container            `json:"sidecars,omitempty"`
 	InitContainers     []corev1.Container            `json:"initContainers,omitempty"`
@@ -165,36 +274,214 @@ type App struct {
 	Autoscaling        Autoscaling                   `json:"autoscaling,omitempty"`
 	ServiceAccountName string                        `json:"serviceAccountName,omitempty"`
 	Hazelcast          Hazelcast                     `json:"hazelcast,omitempty"`
+	// ChecksumExclusions lists ConfigMaps and Secrets, as configmap/<name> or secret/<name>, that are applied
+	// live and shouldn't roll the Gateway pods when their contents change
+	ChecksumExclusions []string `json:"checksumExclusions,omitempty"`
+	// RepositoryReferences are Repositories in the Gateway namespace whose bundles are applied to each ready Gateway pod.
+	// They're applied in ascending Priority, then list order, and entities defined by more than one Repository
+	// are taken from the one applied last.
+	RepositoryReferences []RepositoryReference `json:"repositoryReferences,omitempty"`
+	// SecurePasswords are created as stored passwords on the Gateway from keys of Secrets in the Gateway
+	// namespace. The Gateway pods are rolled when one of the Secrets changes.
+	SecurePasswords []SecurePassword `json:"securePasswords,omitempty"`
+	// PrivateKeys are added to the Gateway keystore from kubernetes.io/tls Secrets in the Gateway namespace.
+	// The Gateway pods are rolled when one of the Secrets is renewed.
+	PrivateKeys []PrivateKey `json:"privateKeys,omitempty"`
+	// TrustedCerts are added to the Gateway's trusted certificates from PEM certificates in ConfigMaps or Secrets
+	// in the Gateway namespace. Their expiry is reported in status.trustedCerts.
+	TrustedCerts []TrustedCert `json:"trustedCerts,omitempty"`
+	// JDBCConnections are created on the Gateway with credentials read from Secrets in the Gateway namespace.
+	// Whether each one exists on the Gateway is reported in status.jdbcConnections.
+	JDBCConnections []JDBCConnection `json:"jdbcConnections,omitempty"`
+	// Templating resolves placeholders in repository and ConfigMap bundles with values read from ConfigMaps
+	// and Secrets in the Gateway namespace, so the same bundles can be delivered to every environment.
+	Templating Templating `json:"templating,omitempty"`
+}
+
+// Templating replaces ${<prefix><name>} placeholders with the value of key name. Bundles with placeholders that don't
+// resolve fail validation and aren't delivered. ConfigMap bundles are mounted from a resolved copy, stored
+// in a Secret when a value is read from a Secret. Repositories using the init method aren't resolved.
+// Changed values only roll the Gateway pods when a mounted bundle resolves differently, bundles applied
+// through the management APIs are applied again instead.
+type Templating struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Prefix limits placeholders to ${<prefix><name>}, leaving Gateway context variables such as
+	// ${request.http.uri} alone. It defaults to env.
+	Prefix string `json:"prefix,omitempty"`
+	// Values are the ConfigMaps and Secrets holding the values by key, later ones override earlier ones
+	Values []TemplateValues `json:"values,omitempty"`
+}
+
+// TemplateValues is a ConfigMap or Secret holding template values
+type TemplateValues struct {
+	ConfigMapName string `json:"configMapName,omitempty"`
+	SecretName    string `json:"secretName,omitempty"`
+}
+
+// JDBCConnection is a Gateway JDBC connection. Its password is created as the secure password
+// jdbc-<name>, which the connection refers to.
+type JDBCConnection struct {
+	Name        string `json:"name"`
+	DriverClass string `json:"driverClass"`
+	JdbcURL     string `json:"jdbcUrl"`
+	// MinimumPoolSize defaults to 3 and MaximumPoolSize to 15
+	MinimumPoolSize int32 `json:"minimumPoolSize,omitempty"`
+	MaximumPoolSize int32 `json:"maximumPoolSize,omitempty"`
+	// Properties are additional connection properties passed to the driver
+	Properties []JDBCProperty `json:"properties,omitempty"`
+	// SecretName is the Secret holding the user and password of the connection
+	SecretName string `json:"secretName"`
+	// UsernameKey and PasswordKey are the keys of the user and password in the Secret,
+	// they default to username and password
+	UsernameKey string `json:"usernameKey,omitempty"`
+	PasswordKey string `json:"passwordKey,omitempty"`
+}
+
+type JDBCProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// TrustedCert is a certificate trusted by the Gateway, read from the first PEM certificate in a ConfigMap or Secret key
+type TrustedCert struct {
+	// Name of the trusted certificate on the Gateway
+	Name string `json:"name"`
+	// ConfigMapName or SecretName is the ConfigMap or Secret holding the certificate
+	ConfigMapName string `json:"configMapName,omitempty"`
+	SecretName    string `json:"secretName,omitempty"`
+	// Key of the certificate in the ConfigMap or Secret
+	Key string `json:"key"`
+	// TrustedFor lists what the certificate is trusted for, any of SSL, SIGNING_SERVER_CERTS,
+	// SIGNING_CLIENT_CERTS, SAML_ISSUER and SAML_ATTESTING_ENTITY
+	TrustedFor     []string `json:"trustedFor,omitempty"`
+	TrustAnchor    bool     `json:"trustAnchor,omitempty"`
+	VerifyHostname bool     `json:"verifyHostname,omitempty"`
+	// RevocationCheckPolicyType is USE_DEFAULT or NONE, defaults to NONE
+	RevocationCheckPolicyType string `json:"revocationCheckPolicyType,omitempty"`
+}
+
+// PrivateKey is a key in the Gateway keystore read from a kubernetes.io/tls Secret. Custom listen ports
+// present it by setting their privateKeyAlias to its alias.
+type PrivateKey struct {
+	// Alias of the key in the Gateway keystore
+	Alias string `json:"alias"`
+	// SecretName is the kubernetes.io/tls Secret holding the key and its certificate chain
+	SecretName string `json:"secretName"`
+	// DefaultSSLKey makes this the Gateway SSL key, presented by listen ports that don't set a privateKeyAlias
+	DefaultSSLKey bool `json:"defaultSSLKey,omitempty"`
+}
+
+// SecurePassword is a Gateway stored password whose value is read from a Secret key
+type SecurePassword struct {
+	// Name of the stored password on the Gateway
+	Name string `json:"name"`
+	// SecretName is the Secret holding the password
+	SecretName string `json:"secretName"`
+	// Key of the password in the Secret
+	Key         string `json:"key"`
+	Description string `json:"description,omitempty"`
+}
+
+type RepositoryReference struct {
+	Name string `json:"name"`
+	// Method is graphman (the default) to apply Graphman JSON bundles or restman to apply .bundle files to
+	// each ready Gateway pod, or mount to mount .bundle files into the pods from a generated ConfigMap, which
+	// rolls the Deployment on every new commit
+	// +kubebuilder:validation:Enum=graphman;restman;mount
+	Method string `json:"method,omitempty"`
+	// Directory is the sub-directory of the Repository holding bundles, defaults to the repository root
+	Directory string `json:"directory,omitempty"`
+	Priority  int32  `json:"priority,omitempty"`
+}
+
+type RepositoryReferenceStatus struct {
+	Name     string `json:"name"`
+	CommitID string `json:"commitId,omitempty"`
+	// Reason is why the Repository isn't applied: NotFetched when it has no commit yet, WaitingForApproval
+	// when no commit has been approved in dry run mode, Invalid when its method or bundles are invalid, or
+	// WaitingForEarlier when a Repository applied before it isn't applied
+	Reason string `json:"reason,omitempty"`
+	// PendingChanges are the changes the latest commit of the Repository would make when it's held back in
+	// dry run mode, CommitID is the commit applied meanwhile
+	PendingChanges *BundleDiff `json:"pendingChanges,omitempty"`
+}
+
+// RepositoryConflict is an entity defined by more than one referenced Repository
+type RepositoryConflict struct {
+	Entity string `json:"entity"`
+	// Repositories define the entity, in the order they're applied
+	Repositories []string `json:"repositories"`
 }
 
 type ClusterProperties struct {
-	Enabled    bool              `json:"enabled,omitempty"`
+	Enabled bool `json:"enabled,omitempty"`
+	// Live applies property changes to the ready Gateway pods through the Restman API rather than rolling them,
+	// it needs management.restman.enabled. The pods still start with the properties in the cluster property bundle.
+	Live       bool              `json:"live,omitempty"`
 	Properties []ClusterProperty `json:"properties,omitempty"`
 }
 
 type ClusterProperty struct {
 	Name  string `json:"name,omitempty"`
 	Value string `json:"value,omitempty"`
+	// ValueFrom reads the value from a ConfigMap or Secret key in the Gateway namespace instead of Value.
+	// The cluster property bundle is stored in a Secret when a value is read from a Secret.
+	ValueFrom *ClusterPropertySource `json:"valueFrom,omitempty"`
+}
+
+// ClusterPropertySource selects the ConfigMap or Secret key holding a cluster property value, only one may be set
+type ClusterPropertySource struct {
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+	SecretKeyRef    *corev1.SecretKeySelector    `json:"secretKeyRef,omitempty"`
 }
 
 // Layer7 Gateway instantiates the following HTTP(s) ports by default
-// Harden applies the following changes. These values are currently hardcoded and will be more configurable in the future
+// Harden applies the following changes
 // - 8080 (HTTP)
 //   - Disable
 //   - Allow Published Service Message input only
+//
 // - 8443 (HTTPS)
 //   - Remove Management Features (no Policy Manager Access)
 //   - Enables TLSv1.2,TLS1.3 only
 //   - Disables insecure Cipher Suites
+//
 // - 9443 (HTTPS)
 //   - Enables TLSv1.2,TLS1.3 only
 //   - Disables insecure Cipher Suites
+//
 // - 2124 (Internode communication)
 //   - No changes
+//
+// Custom ports are created or updated by name alongside these and are added to the Gateway Service.
 type ListenPorts struct {
-	Harden       bool     `json:"harden,omitempty"`
-	CipherSuites []string `json:"cipherSuites,omitempty"`
+	Harden       bool               `json:"harden,omitempty"`
+	CipherSuites []string           `json:"cipherSuites,omitempty"`
+	TlsVersions  []string           `json:"tlsVersions,omitempty"`
+	Custom       []CustomListenPort `json:"custom,omitempty"`
+}
+
+type CustomListenPort struct {
+	Name string `json:"name"`
+	Port int32  `json:"port"`
+	// Protocol is HTTP or HTTPS, defaults to HTTPS
+	Protocol string `json:"protocol,omitempty"`
+	// EnabledFeatures defaults to Published service message input
+	EnabledFeatures []string `json:"enabledFeatures,omitempty"`
+	// ClientAuthentication is one of None, Optional or Required, defaults to Optional
+	ClientAuthentication string `json:"clientAuthentication,omitempty"`
+	// TlsVersions and CipherSuites default to the values set on listenPorts, or TLSv1.2 and TLSv1.3 with
+	// the hardened cipher suites when neither sets them
 	TlsVersions  []string `json:"tlsVersions,omitempty"`
+	CipherSuites []string `json:"cipherSuites,omitempty"`
+	// PrivateKeyAlias is the alias of the key in the Gateway keystore that the port presents, defaults to the Gateway SSL key
+	PrivateKeyAlias string               `json:"privateKeyAlias,omitempty"`
+	Properties      []ListenPortProperty `json:"properties,omitempty"`
+}
+
+type ListenPortProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
 }
 
 type Hazelcast struct {
@@ -223,14 +510,40 @@ type System struct {
 	Properties string `json:"properties,omitempty"`
 }
 
-type Repository struct {
-	Enabled         bool             ` json:"enabled,omitempty"`
-	Name            string           `json:"name,omitempty"`
-	URL             string           `json:"url,omitempty"`
-	Method          string           `json:"method,omitempty"`
-	Init            corev1.Container `json:"init,omitempty"`
-	SecretName      string           `json:"secretName,omitempty"`
-	BundleDirectory string           `json:"bundleDirectory,omitempty"`
+// GatewayRepository is a repository of bundles configured directly on the Gateway
+type GatewayRepository struct {
+	Enabled bool   ` json:"enabled,omitempty"`
+	Name    string `json:"name,omitempty"`
+	URL     string `json:"url,omitempty"`
+	// Method is one of init, restman or graphman. init loads bundles with an init container and rolls the
+	// Deployment on every new commit. restman applies changed bundles to each ready Gateway pod
+	// and only rolls the Deployment if applying them fails. graphman installs changed Graphman JSON
+	// bundles on each ready Gateway pod and deletes entities that were removed from them.
+	Method string `json:"method,omitempty"`
+	// Init is the container that loads bundles with the init method. It's given GIT_REPO_URL, BUNDLE_DIR and
+	// GIT_COMMIT, the commit to check out once one has been resolved from Branch, Tag or Commit. When SecretName
+	// is set it's also given GIT_USERNAME, GIT_PASSWORD, GIT_TOKEN and GIT_SSH_COMMAND with the Secret mounted
+	// read only at /etc/git-credentials. Its volume mounts are shared with the Gateway container.
+	Init corev1.Container `json:"init,omitempty"`
+	// SecretName is a Secret with credentials for private repositories, either username and password or token
+	// for HTTPS, or ssh-privatekey and known_hosts for SSH. With the init method they're only given to the
+	// init container.
+	SecretName string `json:"secretName,omitempty"`
+	// BundleDirectory limits bundles to a sub-path of the repository. Commits that don't change files
+	// under it don't roll or update the Gateway.
+	BundleDirectory string `json:"bundleDirectory,omitempty"`
+	// Commit (a full SHA) takes precedence over Tag, and Tag over Branch. The default branch is used when none are set.
+	Branch string `json:"branch,omitempty"`
+	Tag    string `json:"tag,omitempty"`
+	Commit string `json:"commit,omitempty"`
+	// DryRun holds back commits that change bundles and reports the changes they would make in
+	// status.pendingChanges. A commit is applied once the Gateway is annotated with
+	// security.brcmlabs.com/approve-commit set to its id. The security.brcmlabs.com/dry-run: "true"
+	// annotation has the same effect. New commits of repositoryReferences are held back the same way with
+	// their changes reported in status.repositories, and changed Graphman ConfigMap bundles until their
+	// checksum in status.pendingGraphmanChanges is approved. The annotation takes a comma separated list to
+	// approve several, the BundlesHeldBack condition lists what is waiting.
+	DryRun bool `json:"dryRun,omitempty"`
 }
 
 type PodDisruptionBudgetSpec struct {
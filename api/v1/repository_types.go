@@ -0,0 +1,88 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RepositorySpec defines the desired state of Repository
+type RepositorySpec struct {
+	// Type is git, http, s3 or oci, defaults to git. http and s3 Repositories are .zip or .tar.gz archives at URL,
+	// s3 URLs are path style object URLs, e.g. https://minio.example.com/bundles/release-1.0.tar.gz. oci Repositories
+	// are artifacts in an OCI registry, e.g. registry.example.com/bundles/gateway, pulled by Digest or Tag.
+	Type string `json:"type,omitempty"`
+	URL  string `json:"url"`
+	// Commit (a full SHA) takes precedence over Tag, and Tag over Branch. The default branch is used when none are set.
+	Branch string `json:"branch,omitempty"`
+	Tag    string `json:"tag,omitempty"`
+	Commit string `json:"commit,omitempty"`
+	// Digest pins an oci artifact, taking precedence over Tag which defaults to latest
+	Digest string `json:"digest,omitempty"`
+	// SHA256 is the expected digest of an http or s3 archive
+	SHA256 string `json:"sha256,omitempty"`
+	// Region signs s3 requests, defaults to us-east-1
+	Region string `json:"region,omitempty"`
+	// SecretName is a Secret with credentials for private repositories, either username and password or token
+	// for HTTPS, ssh-privatekey and known_hosts for SSH, accessKeyId and secretAccessKey for s3, or a
+	// kubernetes.io/dockerconfigjson Secret like those used as imagePullSecrets for oci
+	SecretName string `json:"secretName,omitempty"`
+	// SyncInterval is how often the repository is checked for new commits in seconds, defaults to the operator poll interval
+	SyncInterval int32 `json:"syncInterval,omitempty"`
+}
+
+// RepositoryStatus defines the observed state of Repository
+type RepositoryStatus struct {
+	CommitID string `json:"commitId,omitempty"`
+	Ref      string `json:"ref,omitempty"`
+	// Revision identifies the content of an archive or the digest of an oci artifact, CommitID is the commit it was imported as
+	Revision string `json:"revision,omitempty"`
+	// LastSyncTime is when CommitID was last fetched
+	LastSyncTime string `json:"lastSyncTime,omitempty"`
+	// StorageSecretName is a Secret holding a tar.gz archive of the repository at CommitID. It isn't
+	// created for repositories that don't fit in a Secret.
+	StorageSecretName  string             `json:"storageSecretName,omitempty"`
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+	Conditions         []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Commit",type=string,JSONPath=`.status.commitId`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Repository is the Schema for the repositories API
+type Repository struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RepositorySpec   `json:"spec,omitempty"`
+	Status RepositoryStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// RepositoryList contains a list of Repository
+type RepositoryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Repository `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Repository{}, &RepositoryList{})
+}
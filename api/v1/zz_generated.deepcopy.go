@@ -26,6 +26,7 @@ import (
 	"k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
@@ -78,6 +79,41 @@ func (in *App) DeepCopyInto(out *App) {
 	in.Resources.DeepCopyInto(&out.Resources)
 	in.Autoscaling.DeepCopyInto(&out.Autoscaling)
 	out.Hazelcast = in.Hazelcast
+	if in.ChecksumExclusions != nil {
+		in, out := &in.ChecksumExclusions, &out.ChecksumExclusions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RepositoryReferences != nil {
+		in, out := &in.RepositoryReferences, &out.RepositoryReferences
+		*out = make([]RepositoryReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecurePasswords != nil {
+		in, out := &in.SecurePasswords, &out.SecurePasswords
+		*out = make([]SecurePassword, len(*in))
+		copy(*out, *in)
+	}
+	if in.PrivateKeys != nil {
+		in, out := &in.PrivateKeys, &out.PrivateKeys
+		*out = make([]PrivateKey, len(*in))
+		copy(*out, *in)
+	}
+	if in.TrustedCerts != nil {
+		in, out := &in.TrustedCerts, &out.TrustedCerts
+		*out = make([]TrustedCert, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.JDBCConnections != nil {
+		in, out := &in.JDBCConnections, &out.JDBCConnections
+		*out = make([]JDBCConnection, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Templating.DeepCopyInto(&out.Templating)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new App.
@@ -123,6 +159,42 @@ func (in *Bundle) DeepCopy() *Bundle {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BundleDiff) DeepCopyInto(out *BundleDiff) {
+	*out = *in
+	in.ComputedAt.DeepCopyInto(&out.ComputedAt)
+	if in.Changes != nil {
+		in, out := &in.Changes, &out.Changes
+		*out = make([]EntityChange, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BundleDiff.
+func (in *BundleDiff) DeepCopy() *BundleDiff {
+	if in == nil {
+		return nil
+	}
+	out := new(BundleDiff)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BundleError) DeepCopyInto(out *BundleError) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BundleError.
+func (in *BundleError) DeepCopy() *BundleError {
+	if in == nil {
+		return nil
+	}
+	out := new(BundleError)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CSI) DeepCopyInto(out *CSI) {
 	*out = *in
@@ -160,7 +232,9 @@ func (in *ClusterProperties) DeepCopyInto(out *ClusterProperties) {
 	if in.Properties != nil {
 		in, out := &in.Properties, &out.Properties
 		*out = make([]ClusterProperty, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
@@ -174,9 +248,34 @@ func (in *ClusterProperties) DeepCopy() *ClusterProperties {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterPropertiesStatus) DeepCopyInto(out *ClusterPropertiesStatus) {
+	*out = *in
+	if in.Names != nil {
+		in, out := &in.Names, &out.Names
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterPropertiesStatus.
+func (in *ClusterPropertiesStatus) DeepCopy() *ClusterPropertiesStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterPropertiesStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterProperty) DeepCopyInto(out *ClusterProperty) {
 	*out = *in
+	if in.ValueFrom != nil {
+		in, out := &in.ValueFrom, &out.ValueFrom
+		*out = new(ClusterPropertySource)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterProperty.
@@ -189,6 +288,31 @@ func (in *ClusterProperty) DeepCopy() *ClusterProperty {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterPropertySource) DeepCopyInto(out *ClusterPropertySource) {
+	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(corev1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterPropertySource.
+func (in *ClusterPropertySource) DeepCopy() *ClusterPropertySource {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterPropertySource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConfigMap) DeepCopyInto(out *ConfigMap) {
 	*out = *in
@@ -209,6 +333,41 @@ func (in *ConfigMap) DeepCopy() *ConfigMap {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CustomListenPort) DeepCopyInto(out *CustomListenPort) {
+	*out = *in
+	if in.EnabledFeatures != nil {
+		in, out := &in.EnabledFeatures, &out.EnabledFeatures
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TlsVersions != nil {
+		in, out := &in.TlsVersions, &out.TlsVersions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CipherSuites != nil {
+		in, out := &in.CipherSuites, &out.CipherSuites
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Properties != nil {
+		in, out := &in.Properties, &out.Properties
+		*out = make([]ListenPortProperty, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CustomListenPort.
+func (in *CustomListenPort) DeepCopy() *CustomListenPort {
+	if in == nil {
+		return nil
+	}
+	out := new(CustomListenPort)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Database) DeepCopyInto(out *Database) {
 	*out = *in
@@ -224,6 +383,21 @@ func (in *Database) DeepCopy() *Database {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EntityChange) DeepCopyInto(out *EntityChange) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EntityChange.
+func (in *EntityChange) DeepCopy() *EntityChange {
+	if in == nil {
+		return nil
+	}
+	out := new(EntityChange)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Gateway) DeepCopyInto(out *Gateway) {
 	*out = *in
@@ -298,6 +472,22 @@ func (in *GatewayList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayRepository) DeepCopyInto(out *GatewayRepository) {
+	*out = *in
+	in.Init.DeepCopyInto(&out.Init)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayRepository.
+func (in *GatewayRepository) DeepCopy() *GatewayRepository {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayRepository)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GatewaySpec) DeepCopyInto(out *GatewaySpec) {
 	*out = *in
@@ -318,6 +508,11 @@ func (in *GatewaySpec) DeepCopy() *GatewaySpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GatewayState) DeepCopyInto(out *GatewayState) {
 	*out = *in
+	if in.RepositoryCommits != nil {
+		in, out := &in.RepositoryCommits, &out.RepositoryCommits
+		*out = make([]RepositoryCommit, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayState.
@@ -343,8 +538,56 @@ func (in *GatewayStatus) DeepCopyInto(out *GatewayStatus) {
 	if in.Gateway != nil {
 		in, out := &in.Gateway, &out.Gateway
 		*out = make([]GatewayState, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Repositories != nil {
+		in, out := &in.Repositories, &out.Repositories
+		*out = make([]RepositoryReferenceStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RepositoryConflicts != nil {
+		in, out := &in.RepositoryConflicts, &out.RepositoryConflicts
+		*out = make([]RepositoryConflict, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.BundleErrors != nil {
+		in, out := &in.BundleErrors, &out.BundleErrors
+		*out = make([]BundleError, len(*in))
+		copy(*out, *in)
+	}
+	if in.PendingChanges != nil {
+		in, out := &in.PendingChanges, &out.PendingChanges
+		*out = new(BundleDiff)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PendingGraphmanChanges != nil {
+		in, out := &in.PendingGraphmanChanges, &out.PendingGraphmanChanges
+		*out = new(BundleDiff)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TrustedCerts != nil {
+		in, out := &in.TrustedCerts, &out.TrustedCerts
+		*out = make([]TrustedCertStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.JDBCConnections != nil {
+		in, out := &in.JDBCConnections, &out.JDBCConnections
+		*out = make([]JDBCConnectionStatus, len(*in))
 		copy(*out, *in)
 	}
+	if in.ClusterProperties != nil {
+		in, out := &in.ClusterProperties, &out.ClusterProperties
+		*out = new(ClusterPropertiesStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayStatus.
@@ -360,6 +603,11 @@ func (in *GatewayStatus) DeepCopy() *GatewayStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Graphman) DeepCopyInto(out *Graphman) {
 	*out = *in
+	if in.ConfigMaps != nil {
+		in, out := &in.ConfigMaps, &out.ConfigMaps
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Graphman.
@@ -466,6 +714,56 @@ func (in *Ingress) DeepCopy() *Ingress {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JDBCConnection) DeepCopyInto(out *JDBCConnection) {
+	*out = *in
+	if in.Properties != nil {
+		in, out := &in.Properties, &out.Properties
+		*out = make([]JDBCProperty, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JDBCConnection.
+func (in *JDBCConnection) DeepCopy() *JDBCConnection {
+	if in == nil {
+		return nil
+	}
+	out := new(JDBCConnection)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JDBCConnectionStatus) DeepCopyInto(out *JDBCConnectionStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JDBCConnectionStatus.
+func (in *JDBCConnectionStatus) DeepCopy() *JDBCConnectionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(JDBCConnectionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JDBCProperty) DeepCopyInto(out *JDBCProperty) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JDBCProperty.
+func (in *JDBCProperty) DeepCopy() *JDBCProperty {
+	if in == nil {
+		return nil
+	}
+	out := new(JDBCProperty)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *JVMHeap) DeepCopyInto(out *JVMHeap) {
 	*out = *in
@@ -517,6 +815,21 @@ func (in *License) DeepCopy() *License {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ListenPortProperty) DeepCopyInto(out *ListenPortProperty) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ListenPortProperty.
+func (in *ListenPortProperty) DeepCopy() *ListenPortProperty {
+	if in == nil {
+		return nil
+	}
+	out := new(ListenPortProperty)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ListenPorts) DeepCopyInto(out *ListenPorts) {
 	*out = *in
@@ -530,6 +843,13 @@ func (in *ListenPorts) DeepCopyInto(out *ListenPorts) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Custom != nil {
+		in, out := &in.Custom, &out.Custom
+		*out = make([]CustomListenPort, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ListenPorts.
@@ -548,8 +868,9 @@ func (in *Management) DeepCopyInto(out *Management) {
 	out.Cluster = in.Cluster
 	out.Database = in.Database
 	out.Restman = in.Restman
-	out.Graphman = in.Graphman
+	in.Graphman.DeepCopyInto(&out.Graphman)
 	in.Service.DeepCopyInto(&out.Service)
+	out.CA = in.CA
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Management.
@@ -562,6 +883,21 @@ func (in *Management) DeepCopy() *Management {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagementCA) DeepCopyInto(out *ManagementCA) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagementCA.
+func (in *ManagementCA) DeepCopy() *ManagementCA {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagementCA)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PodAffinity) DeepCopyInto(out *PodAffinity) {
 	*out = *in
@@ -656,10 +992,28 @@ func (in *Ports) DeepCopy() *Ports {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateKey) DeepCopyInto(out *PrivateKey) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateKey.
+func (in *PrivateKey) DeepCopy() *PrivateKey {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateKey)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Repository) DeepCopyInto(out *Repository) {
 	*out = *in
-	in.Init.DeepCopyInto(&out.Init)
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Repository.
@@ -672,6 +1026,153 @@ func (in *Repository) DeepCopy() *Repository {
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Repository) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepositoryCommit) DeepCopyInto(out *RepositoryCommit) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepositoryCommit.
+func (in *RepositoryCommit) DeepCopy() *RepositoryCommit {
+	if in == nil {
+		return nil
+	}
+	out := new(RepositoryCommit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepositoryConflict) DeepCopyInto(out *RepositoryConflict) {
+	*out = *in
+	if in.Repositories != nil {
+		in, out := &in.Repositories, &out.Repositories
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepositoryConflict.
+func (in *RepositoryConflict) DeepCopy() *RepositoryConflict {
+	if in == nil {
+		return nil
+	}
+	out := new(RepositoryConflict)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepositoryList) DeepCopyInto(out *RepositoryList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Repository, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepositoryList.
+func (in *RepositoryList) DeepCopy() *RepositoryList {
+	if in == nil {
+		return nil
+	}
+	out := new(RepositoryList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RepositoryList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepositoryReference) DeepCopyInto(out *RepositoryReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepositoryReference.
+func (in *RepositoryReference) DeepCopy() *RepositoryReference {
+	if in == nil {
+		return nil
+	}
+	out := new(RepositoryReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepositoryReferenceStatus) DeepCopyInto(out *RepositoryReferenceStatus) {
+	*out = *in
+	if in.PendingChanges != nil {
+		in, out := &in.PendingChanges, &out.PendingChanges
+		*out = new(BundleDiff)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepositoryReferenceStatus.
+func (in *RepositoryReferenceStatus) DeepCopy() *RepositoryReferenceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RepositoryReferenceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepositorySpec) DeepCopyInto(out *RepositorySpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepositorySpec.
+func (in *RepositorySpec) DeepCopy() *RepositorySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RepositorySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepositoryStatus) DeepCopyInto(out *RepositoryStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepositoryStatus.
+func (in *RepositoryStatus) DeepCopy() *RepositoryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RepositoryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceList) DeepCopyInto(out *ResourceList) {
 	*out = *in
@@ -705,6 +1206,21 @@ func (in *Restman) DeepCopy() *Restman {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecurePassword) DeepCopyInto(out *SecurePassword) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecurePassword.
+func (in *SecurePassword) DeepCopy() *SecurePassword {
+	if in == nil {
+		return nil
+	}
+	out := new(SecurePassword)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Service) DeepCopyInto(out *Service) {
 	*out = *in
@@ -747,6 +1263,77 @@ func (in *System) DeepCopy() *System {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateValues) DeepCopyInto(out *TemplateValues) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateValues.
+func (in *TemplateValues) DeepCopy() *TemplateValues {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateValues)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Templating) DeepCopyInto(out *Templating) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = make([]TemplateValues, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Templating.
+func (in *Templating) DeepCopy() *Templating {
+	if in == nil {
+		return nil
+	}
+	out := new(Templating)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrustedCert) DeepCopyInto(out *TrustedCert) {
+	*out = *in
+	if in.TrustedFor != nil {
+		in, out := &in.TrustedFor, &out.TrustedFor
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrustedCert.
+func (in *TrustedCert) DeepCopy() *TrustedCert {
+	if in == nil {
+		return nil
+	}
+	out := new(TrustedCert)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrustedCertStatus) DeepCopyInto(out *TrustedCertStatus) {
+	*out = *in
+	in.NotAfter.DeepCopyInto(&out.NotAfter)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrustedCertStatus.
+func (in *TrustedCertStatus) DeepCopy() *TrustedCertStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TrustedCertStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UpdateStrategy) DeepCopyInto(out *UpdateStrategy) {
 	*out = *in
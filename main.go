@@ -19,13 +19,16 @@ package main
 import (
 	"flag"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -35,6 +38,8 @@ import (
 
 	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
 	"github.com/Layer7-Community/layer7-operator/pkg/controllers/gateway"
+	repositorycontroller "github.com/Layer7-Community/layer7-operator/pkg/controllers/repository"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/repository"
 	"github.com/Layer7-Community/layer7-operator/pkg/gateway/util"
 	//+kubebuilder:scaffold:imports
 )
@@ -55,11 +60,21 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var repositoryPollInterval time.Duration
+	var repositoryPollJitter time.Duration
+	var repositoryCacheDir string
+	var webhookAddr string
+	var webhookSecret string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.DurationVar(&repositoryPollInterval, "repository-poll-interval", 30*time.Second, "How often Gateway repositories are checked for new commits. Can be raised when push webhooks are configured.")
+	flag.DurationVar(&repositoryPollJitter, "repository-poll-jitter", 10*time.Second, "Random delay added to each repository poll to spread requests to git servers.")
+	flag.StringVar(&repositoryCacheDir, "repository-cache-dir", filepath.Join(os.TempDir(), "layer7-operator", "repositories"), "Directory where Gateway repositories are cached.")
+	flag.StringVar(&webhookAddr, "webhook-bind-address", "0", "The address the git push webhook endpoint binds to. Set to 0 to disable it.")
+	flag.StringVar(&webhookSecret, "webhook-secret", "", "The namespace/name of the Secret with the shared secret used to validate git push webhooks.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -95,12 +110,60 @@ func main() {
 		os.Exit(1)
 	}
 
+	repositoryCache := util.NewRepositoryCache(repositoryCacheDir)
+	gatewayPoller := repository.NewPoller(repositoryPollInterval, repositoryPollJitter, &securityv1.Gateway{}, ctrl.Log.WithName("repository").WithName("GatewayPoller"))
+	repositoryPoller := repository.NewPoller(repositoryPollInterval, repositoryPollJitter, &securityv1.Repository{}, ctrl.Log.WithName("repository").WithName("RepositoryPoller"))
+	for _, poller := range []*repository.Poller{gatewayPoller, repositoryPoller} {
+		poller.UseCache(repositoryCache)
+		if err := mgr.Add(poller); err != nil {
+			setupLog.Error(err, "unable to add repository poller")
+			os.Exit(1)
+		}
+	}
+
+	if webhookAddr != "0" {
+		secretNamespace, secretName, ok := strings.Cut(webhookSecret, "/")
+		if !ok {
+			setupLog.Error(nil, "webhook-secret must be set to namespace/name when webhooks are enabled")
+			os.Exit(1)
+		}
+		// the receiver reads through the manager's cache, which only holds the watched namespaces
+		if namespace != "" && !strings.Contains(","+namespace+",", ","+secretNamespace+",") {
+			setupLog.Error(nil, "webhook-secret must be in a watched namespace", "namespace", secretNamespace)
+			os.Exit(1)
+		}
+		if err := mgr.Add(&repository.Receiver{
+			Addr:             webhookAddr,
+			Client:           mgr.GetClient(),
+			Secret:           types.NamespacedName{Namespace: secretNamespace, Name: secretName},
+			GatewayPoller:    gatewayPoller,
+			RepositoryPoller: repositoryPoller,
+			Log:              ctrl.Log.WithName("repository").WithName("Receiver"),
+		}); err != nil {
+			setupLog.Error(err, "unable to add repository webhook receiver")
+			os.Exit(1)
+		}
+	}
+
 	if err = (&gateway.GatewayReconciler{
+		Client:           mgr.GetClient(),
+		Log:              ctrl.Log.WithName("controllers").WithName("Gateway"),
+		Scheme:           mgr.GetScheme(),
+		RepositoryPoller: gatewayPoller,
+		RepositoryCache:  repositoryCache,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Gateway")
+		os.Exit(1)
+	}
+
+	if err = (&repositorycontroller.RepositoryReconciler{
 		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Gateway"),
+		Log:    ctrl.Log.WithName("controllers").WithName("Repository"),
 		Scheme: mgr.GetScheme(),
+		Poller: repositoryPoller,
+		Cache:  repositoryCache,
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Gateway")
+		setupLog.Error(err, "unable to create controller", "controller", "Repository")
 		os.Exit(1)
 	}
 
@@ -0,0 +1,175 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/config"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/secrets"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/util"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcileClusterProperties generates the cluster property bootstrap bundle, stored in a Secret rather than
+// a ConfigMap when a value is read from a Secret
+func reconcileClusterProperties(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) error {
+	props, err := resolveClusterProperties(r, ctx, gw)
+	if err != nil {
+		r.Log.Error(err, "Failed to resolve cluster properties", "Name", gw.Name, "Namespace", gw.Namespace)
+		return err
+	}
+
+	bundle, err := util.BuildCWPBundle(props)
+	if err != nil {
+		return err
+	}
+
+	if util.ClusterPropertiesFromSecrets(gw) {
+		return reconcileBundleSecret(r, ctx, gw, secrets.NewCWPSecret(gw, bundle))
+	}
+	return reconcileBundleConfigMap(r, ctx, gw, config.NewBundleConfigMap(gw, gw.Name+"-cwp-bundle", "cwp.bundle", bundle))
+}
+
+// resolveClusterProperties returns the value of each cluster property by name, reading values from
+// ConfigMap and Secret keys. Optional references that don't resolve leave the property out.
+func resolveClusterProperties(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) (map[string]string, error) {
+	props := map[string]string{}
+	for _, p := range gw.Spec.App.ClusterProperties.Properties {
+		if p.ValueFrom == nil {
+			props[p.Name] = p.Value
+			continue
+		}
+
+		cmRef, secretRef := p.ValueFrom.ConfigMapKeyRef, p.ValueFrom.SecretKeyRef
+		if (cmRef == nil) == (secretRef == nil) {
+			return nil, fmt.Errorf("cluster property %s needs one of valueFrom.configMapKeyRef or valueFrom.secretKeyRef", p.Name)
+		}
+
+		var value []byte
+		var found bool
+		var optional *bool
+		var err error
+		if cmRef != nil {
+			optional = cmRef.Optional
+			cm := &corev1.ConfigMap{}
+			err = r.Get(ctx, types.NamespacedName{Name: cmRef.Name, Namespace: gw.Namespace}, cm)
+			if v, ok := cm.Data[cmRef.Key]; ok {
+				value, found = []byte(v), true
+			} else {
+				value, found = cm.BinaryData[cmRef.Key]
+			}
+		} else {
+			optional = secretRef.Optional
+			secret := &corev1.Secret{}
+			err = r.Get(ctx, types.NamespacedName{Name: secretRef.Name, Namespace: gw.Namespace}, secret)
+			value, found = secret.Data[secretRef.Key]
+		}
+
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return nil, err
+		}
+		if !found {
+			if optional != nil && *optional {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if cmRef != nil {
+				return nil, fmt.Errorf("configmap %s has no key %s for cluster property %s", cmRef.Name, cmRef.Key, p.Name)
+			}
+			return nil, fmt.Errorf("secret %s has no key %s for cluster property %s", secretRef.Name, secretRef.Key, p.Name)
+		}
+		props[p.Name] = string(value)
+	}
+	return props, nil
+}
+
+// liveClusterProperties returns true if cluster property changes are applied to the running pods instead of rolling them
+func liveClusterProperties(gw *securityv1.Gateway) bool {
+	return gw.Spec.App.ClusterProperties.Enabled && gw.Spec.App.ClusterProperties.Live && restmanEnabled(gw)
+}
+
+// reconcileLiveClusterProperties applies the cluster properties that differ from the spec to each ready pod that
+// hasn't got them yet. Properties the operator managed before that are no longer in the spec are deleted.
+func reconcileLiveClusterProperties(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) error {
+	props, err := resolveClusterProperties(r, ctx, gw)
+	if err != nil {
+		r.Log.Error(err, "Failed to resolve cluster properties", "Name", gw.Name, "Namespace", gw.Namespace)
+		return err
+	}
+	data := map[string][]byte{}
+	names := []string{}
+	for name, value := range props {
+		data[name] = []byte(value)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checksum := util.Checksum(data)
+
+	status := &securityv1.ClusterPropertiesStatus{}
+	if gw.Status.ClusterProperties != nil {
+		status = gw.Status.ClusterProperties.DeepCopy()
+	}
+
+	podList := &corev1.PodList{}
+	if err := r.List(ctx, podList, client.InNamespace(gw.Namespace), client.MatchingLabels(util.DefaultLabels(gw))); err != nil {
+		r.Log.Error(err, "Failed to list pods", "Namespace", gw.Namespace, "Name", gw.Name)
+		return err
+	}
+	podIPs := map[string]string{}
+	for _, pod := range podList.Items {
+		podIPs[pod.Name] = pod.Status.PodIP
+	}
+
+	api, err := getManagementAPI(r, ctx, gw)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	applied := true
+	for i, state := range gw.Status.Gateway {
+		if !state.Ready || state.ClusterPropertiesChecksum == checksum {
+			continue
+		}
+		if podIPs[state.Name] == "" {
+			applied = false
+			continue
+		}
+		c, err := api.restman(podIPs[state.Name])
+		if err != nil {
+			return err
+		}
+		n, err := c.SyncClusterProperties(ctx, props, status.Names)
+		if err != nil {
+			r.Log.Error(err, "Failed to apply cluster properties", "Name", gw.Name, "Namespace", gw.Namespace, "Pod", state.Name)
+			applied = false
+			continue
+		}
+		if n > 0 {
+			r.Log.Info("Applied cluster properties", "Name", gw.Name, "Namespace", gw.Namespace, "Pod", state.Name, "Changed", n)
+		}
+		gw.Status.Gateway[i].ClusterPropertiesChecksum = checksum
+		changed = true
+	}
+
+	// removed properties are forgotten once every ready pod has deleted them
+	if applied && (status.Checksum != checksum || status.Generation != gw.Generation) {
+		gw.Status.ClusterProperties = &securityv1.ClusterPropertiesStatus{Generation: gw.Generation, Checksum: checksum, Names: names}
+		changed = true
+	}
+
+	if changed {
+		if err := r.Client.Status().Update(ctx, gw); err != nil {
+			r.Log.Error(err, "Failed to update cluster property status", "Name", gw.Name, "Namespace", gw.Namespace)
+			return err
+		}
+	}
+	return nil
+}
@@ -19,7 +19,11 @@ package gateway
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
@@ -27,6 +31,7 @@ import (
 	"github.com/Layer7-Community/layer7-operator/pkg/gateway/config"
 	"github.com/Layer7-Community/layer7-operator/pkg/gateway/hpa"
 	"github.com/Layer7-Community/layer7-operator/pkg/gateway/ingress"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/repository"
 	"github.com/Layer7-Community/layer7-operator/pkg/gateway/secrets"
 	"github.com/Layer7-Community/layer7-operator/pkg/gateway/service"
 	"github.com/Layer7-Community/layer7-operator/pkg/gateway/util"
@@ -36,17 +41,24 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // GatewayReconciler reconciles a Gateway object
 type GatewayReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log              logr.Logger
+	Scheme           *runtime.Scheme
+	RepositoryPoller *repository.Poller
+	RepositoryCache  *util.RepositoryCache
 }
 
 // //+kubebuilder:rbac:groups=security.brcmlabs.com,namespace=default,resources=gateways,verbs=get;list;watch;create;update;patch;delete
@@ -80,6 +92,7 @@ func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	err := r.Get(ctx, req.NamespacedName, gw)
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
+			r.RepositoryPoller.Forget(req.NamespacedName)
 			return ctrl.Result{}, nil
 		}
 		return ctrl.Result{}, err
@@ -108,19 +121,47 @@ func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	}
 
 	if gw.Spec.App.ClusterProperties.Enabled {
-		err = reconcileConfigMap(r, gw.Name+"-cwp-bundle", ctx, gw)
+		err = reconcileClusterProperties(r, ctx, gw)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
 	}
 
-	if gw.Spec.App.ListenPorts.Harden {
+	if gw.Spec.App.ListenPorts.Harden || len(gw.Spec.App.ListenPorts.Custom) > 0 {
 		err = reconcileConfigMap(r, gw.Name+"-listen-port-bundle", ctx, gw)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
 	}
 
+	if len(util.SecurePasswords(gw)) > 0 {
+		err = reconcileSecurePasswords(r, ctx, gw)
+		if err != nil {
+			return ctrl.Result{RequeueAfter: time.Second * 10}, err
+		}
+	}
+
+	if len(gw.Spec.App.PrivateKeys) > 0 {
+		err = reconcilePrivateKeys(r, ctx, gw)
+		if err != nil {
+			return ctrl.Result{RequeueAfter: time.Second * 10}, err
+		}
+	}
+
+	if len(gw.Spec.App.TrustedCerts) > 0 {
+		err = reconcileTrustedCerts(r, ctx, gw)
+		if err != nil {
+			return ctrl.Result{RequeueAfter: time.Second * 10}, err
+		}
+	}
+
+	if len(gw.Spec.App.JDBCConnections) > 0 {
+		err = reconcileJDBCConnections(r, ctx, gw)
+		if err != nil {
+			return ctrl.Result{RequeueAfter: time.Second * 10}, err
+		}
+	}
+
 	if gw.Spec.App.Management.SecretName != "" {
 		gatewaySecret := &corev1.Secret{}
 		err = r.Get(ctx, types.NamespacedName{Name: gw.Spec.App.Management.SecretName, Namespace: gw.Namespace}, gatewaySecret)
@@ -173,9 +214,23 @@ func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		}
 	}
 
-	err = reconcileDeployment(r, ctx, gw)
+	valid, err := validateConfigMapBundles(r, ctx, gw)
 	if err != nil {
-		return ctrl.Result{}, err
+		return ctrl.Result{RequeueAfter: time.Second * 10}, err
+	}
+
+	if valid {
+		if gw.Spec.App.Templating.Enabled {
+			err = reconcileResolvedBundles(r, ctx, gw)
+			if err != nil {
+				return ctrl.Result{RequeueAfter: time.Second * 10}, err
+			}
+		}
+
+		err = reconcileDeployment(r, ctx, gw)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
 	}
 
 	err = updateGatewayStatus(r, ctx, gw)
@@ -183,34 +238,392 @@ func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{RequeueAfter: time.Second * 10}, err
 	}
 
+	err = updateHeldBackCondition(r, ctx, gw)
+	if err != nil {
+		return ctrl.Result{RequeueAfter: time.Second * 10}, err
+	}
+
+	if managementAPIUsed(gw) {
+		err = updateManagementCertificateCondition(r, ctx, gw)
+		if err != nil {
+			return ctrl.Result{RequeueAfter: time.Second * 10}, err
+		}
+	}
+
+	if len(gw.Spec.App.JDBCConnections) > 0 {
+		err = updateJDBCConnectionStatus(r, ctx, gw)
+		if err != nil {
+			return ctrl.Result{RequeueAfter: time.Second * 10}, err
+		}
+	}
+
+	if liveClusterProperties(gw) {
+		err = reconcileLiveClusterProperties(r, ctx, gw)
+		if err != nil {
+			return ctrl.Result{RequeueAfter: time.Second * 10}, err
+		}
+	}
+
+	if gw.Spec.App.Templating.Enabled {
+		err = reconcileTemplateValues(r, ctx, gw)
+		if err != nil {
+			return ctrl.Result{RequeueAfter: time.Second * 10}, err
+		}
+	}
+
 	if gw.Spec.App.Repository.Enabled {
 		err = reconcileBundles(r, ctx, gw)
 		if err != nil {
 			return ctrl.Result{RequeueAfter: time.Second * 10}, err
 		}
+	} else {
+		r.RepositoryPoller.Forget(req.NamespacedName)
+	}
+
+	if (gw.Spec.App.Repository.Enabled && gw.Spec.App.Repository.Method == "graphman") || (gw.Spec.App.Management.Graphman.Enabled && len(gw.Spec.App.Management.Graphman.ConfigMaps) > 0) {
+		err = reconcileGraphman(r, ctx, gw)
+		if err != nil {
+			return ctrl.Result{RequeueAfter: time.Second * 10}, err
+		}
+	}
+
+	if len(gw.Spec.App.RepositoryReferences) > 0 {
+		err = reconcileRepositoryReferences(r, ctx, gw)
+		if err != nil {
+			return ctrl.Result{RequeueAfter: time.Second * 10}, err
+		}
 	}
 
 	return ctrl.Result{RequeueAfter: time.Second * 30}, nil
 }
 
 func reconcileBundles(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) error {
-	commit, err := util.GetLatestCommit(gw.Spec.App.Repository.URL)
+	creds, err := getRepositoryCredentials(r, ctx, gw)
 	if err != nil {
-		return err
+		return repositoryNotReady(r, ctx, gw, "CredentialsUnavailable", err)
+	}
+	// the init container fetches bundles itself, their placeholders aren't resolved
+	var t *bundleTemplate
+	if gw.Spec.App.Repository.Method != "init" {
+		t, err = getBundleTemplate(r, ctx, gw)
+		if err != nil {
+			return err
+		}
+	}
+	ref := repositoryReference(gw)
+	if err := ref.Validate(); err != nil {
+		return repositoryNotReady(r, ctx, gw, "CommitInvalid", err)
+	}
+	target := repository.Target{URL: gw.Spec.App.Repository.URL, Ref: ref}
+	if gw.Spec.App.Repository.SecretName != "" {
+		target.Secret = gw.Namespace + "/" + gw.Spec.App.Repository.SecretName
+	}
+	commitId, err := r.RepositoryPoller.Watch(types.NamespacedName{Name: gw.Name, Namespace: gw.Namespace}, target, creds)
+	if err != nil {
+		if util.IsGitAuthError(err) {
+			return repositoryNotReady(r, ctx, gw, "AuthenticationFailed", err)
+		}
+		return repositoryNotReady(r, ctx, gw, "FetchFailed", err)
+	}
+
+	// the repository content is only needed when the ref has moved. Commits that don't touch the bundle
+	// directory are recorded but don't change what the Gateway runs.
+	// bundles that fail validation aren't delivered, the Gateway stays on the last valid commit.
+	// In dry run mode commits that change bundles are held back until approved.
+	bundleChanged := false
+	validated := false
+	pendingChanged := false
+	if commitId != gw.Status.CommitID || gw.Status.BundleCommitID == "" || gw.Status.PendingChanges != nil {
+		repo, commit, err := r.RepositoryCache.Checkout(gw.Spec.App.Repository.URL, creds, commitId)
+		if err != nil {
+			return repositoryNotReady(r, ctx, gw, "FetchFailed", err)
+		}
+		bundleChanged, err = util.BundleDirectoryChanged(repo, commit, gw.Spec.App.Repository.BundleDirectory, gw.Status.BundleCommitID)
+		if err != nil {
+			return repositoryNotReady(r, ctx, gw, "BundleDirectoryInvalid", err)
+		}
+		if bundleChanged {
+			files, err := repositoryBundleFiles(repo, commit, gw.Spec.App.Repository.BundleDirectory, gw.Spec.App.Repository.Method)
+			if err != nil {
+				return repositoryNotReady(r, ctx, gw, "BundleDirectoryInvalid", err)
+			}
+			errs := validateBundleFiles("repository", files, t)
+			validated = setBundleErrors(gw, "repository", errs)
+			if len(errs) > 0 {
+				r.Log.Info("Bundles are invalid and won't be applied", "Name", gw.Name, "Namespace", gw.Namespace, "Commit", commitId, "Errors", len(errs))
+				bundleChanged = false
+			}
+		}
+		if bundleChanged && dryRun(gw) && !commitApproved(gw, commitId) {
+			bundleChanged = false
+			if pendingChangesStale(gw.Status.PendingChanges, commitId) {
+				gw.Status.PendingChanges = diffBundles(r, ctx, gw, commitId, func(api *managementAPI, pod *corev1.Pod) ([]securityv1.EntityChange, error) {
+					return bundleChanges(ctx, api, pod, gw.Spec.App.Repository.Method, repo, commit, gw.Spec.App.Repository.BundleDirectory, gw.Status.BundleCommitID, t)
+				})
+				pendingChanged = true
+				r.Log.Info("Commit is waiting for approval", "Name", gw.Name, "Namespace", gw.Namespace, "Commit", commitId,
+					"Created", gw.Status.PendingChanges.Created, "Updated", gw.Status.PendingChanges.Updated, "Deleted", gw.Status.PendingChanges.Deleted)
+			}
+		} else if gw.Status.PendingChanges != nil {
+			gw.Status.PendingChanges = nil
+			pendingChanged = true
+		}
+	}
+
+	ready := setGatewayCondition(gw, repositoryReadyCondition, corev1.ConditionTrue, "Fetched", "fetched commit "+commitId+" from "+ref.Name())
+	if gw.Status.CommitID != commitId || gw.Status.RepositoryRef != ref.Name() || bundleChanged || ready || validated || pendingChanged {
+		gw.Status.CommitID = commitId
+		gw.Status.RepositoryRef = ref.Name()
+		if bundleChanged {
+			r.Log.Info("Bundle directory changed", "Name", gw.Name, "Namespace", gw.Namespace, "Commit", commitId)
+			gw.Status.BundleCommitID = commitId
+		}
+		if err := r.Client.Status().Update(ctx, gw); err != nil {
+			r.Log.Error(err, "Failed to update commit id", "Namespace", gw.Namespace, "Name", gw.Name)
+			return err
+		}
+	}
+
+	if gw.Spec.App.Repository.Method == "restman" {
+		return applyRestmanBundles(r, ctx, gw, creds, t)
 	}
-	if gw.Status.CommitID == commit {
+
+	return nil
+}
+
+// applyRestmanBundles brings every ready Gateway pod up to the latest bundle commit. Pods receive the bundles
+// that changed since the commit they last applied, or every bundle if they haven't applied one yet.
+// If a pod fails to apply a commit, even one applying every bundle, the Deployment is rolled once for that
+// commit so replacement pods start from a clean state.
+func applyRestmanBundles(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway, creds *util.GitCredentials, t *bundleTemplate) error {
+	commitId := gw.Status.BundleCommitID
+	behind := false
+	for _, state := range gw.Status.Gateway {
+		if state.Ready && state.CommitID != commitId {
+			behind = true
+		}
+	}
+	if !behind {
 		return nil
 	}
 
-	gw.Status.CommitID = commit
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{
+		client.InNamespace(gw.Namespace),
+		client.MatchingLabels(util.DefaultLabels(gw)),
+	}
+	if err := r.List(ctx, podList, listOpts...); err != nil {
+		r.Log.Error(err, "Failed to list pods", "Namespace", gw.Namespace, "Name", gw.Name)
+		return err
+	}
+
+	api, err := getManagementAPI(r, ctx, gw)
+	if err != nil {
+		return err
+	}
+
+	repo, commit, err := r.RepositoryCache.Checkout(gw.Spec.App.Repository.URL, creds, commitId)
+	if err != nil {
+		return err
+	}
+
+	bundleSets := map[string]map[string][]byte{}
+	failed := []string{}
+	for i, state := range gw.Status.Gateway {
+		if !state.Ready || state.CommitID == commitId {
+			continue
+		}
+
+		podIP := ""
+		for _, pod := range podList.Items {
+			if pod.Name == state.Name {
+				podIP = pod.Status.PodIP
+			}
+		}
+		if podIP == "" {
+			continue
+		}
+
+		bundles, ok := bundleSets[state.CommitID]
+		if !ok {
+			bundles, err = util.GetBundles(repo, commit, gw.Spec.App.Repository.BundleDirectory, state.CommitID)
+			if err != nil {
+				return err
+			}
+			bundles = t.resolveBundles(bundles)
+			bundleSets[state.CommitID] = bundles
+		}
+
+		r.Log.Info("Applying bundles", "Name", gw.Name, "Namespace", gw.Namespace, "Pod", state.Name, "Commit", commitId, "Bundles", len(bundles))
+		err = restmanApplyBundles(ctx, api, podIP, bundles)
+		if err != nil {
+			r.Log.Error(err, "Failed to apply bundles", "Name", gw.Name, "Namespace", gw.Namespace, "Pod", state.Name)
+			gw.Status.Gateway[i].SyncStatus = "failed"
+			gw.Status.Gateway[i].SyncError = err.Error()
+			failed = append(failed, state.Name)
+			continue
+		}
+
+		gw.Status.Gateway[i].CommitID = commitId
+		gw.Status.Gateway[i].SyncStatus = "applied"
+		gw.Status.Gateway[i].SyncError = ""
+	}
+
+	// pods that fail to apply a commit, including new pods applying it for the first time, are rolled once
+	// per commit. Pods that still fail afterwards are retried on each reconcile and reported in the
+	// BundlesApplied condition.
+	if len(failed) > 0 {
+		if gw.Status.RolloutCommitID != commitId {
+			r.Log.Info("Rolling Gateway pods after failed bundle update", "Name", gw.Name, "Namespace", gw.Namespace, "Commit", commitId)
+			gw.Status.RolloutCommitID = commitId
+			setGatewayCondition(gw, bundlesAppliedCondition, corev1.ConditionFalse, "RollingPods", fmt.Sprintf("commit %s failed to apply to %s, rolling the Gateway pods", commitId, strings.Join(failed, ", ")))
+		} else {
+			setGatewayCondition(gw, bundlesAppliedCondition, corev1.ConditionFalse, "ApplyFailed", fmt.Sprintf("commit %s failed to apply to %s after rolling the Gateway pods, see status.gateway", commitId, strings.Join(failed, ", ")))
+		}
+	} else if hasGatewayCondition(gw, bundlesAppliedCondition) {
+		setGatewayCondition(gw, bundlesAppliedCondition, corev1.ConditionTrue, "Applied", "commit "+commitId+" is applied to the ready Gateway pods")
+	}
+
 	if err := r.Client.Status().Update(ctx, gw); err != nil {
-		r.Log.Error(err, "Failed to update commit id", "Namespace", gw.Namespace, "Name", gw.Name)
+		r.Log.Error(err, "Failed to update bundle status", "Namespace", gw.Namespace, "Name", gw.Name)
 		return err
 	}
 
 	return nil
 }
 
+// bundlesAppliedCondition reports Gateway pods that fail to apply the repository commit through Restman
+const bundlesAppliedCondition = "BundlesApplied"
+
+// repositoryReadyCondition reports whether the Gateway repository could be fetched
+const repositoryReadyCondition = "RepositoryReady"
+
+// repositoryNotReady records why the repository couldn't be fetched on the Gateway status and returns err
+func repositoryNotReady(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway, reason string, err error) error {
+	r.Log.Error(err, "Failed to fetch repository", "Name", gw.Name, "Namespace", gw.Namespace, "Reason", reason)
+	if setGatewayCondition(gw, repositoryReadyCondition, corev1.ConditionFalse, reason, err.Error()) {
+		if updateErr := r.Client.Status().Update(ctx, gw); updateErr != nil {
+			r.Log.Error(updateErr, "Failed to update repository status", "Namespace", gw.Namespace, "Name", gw.Name)
+		}
+	}
+	return err
+}
+
+// hasGatewayCondition returns true if the Gateway status has a condition of the type
+func hasGatewayCondition(gw *securityv1.Gateway, conditionType string) bool {
+	for _, c := range gw.Status.Conditions {
+		if string(c.Type) == conditionType {
+			return true
+		}
+	}
+	return false
+}
+
+// setGatewayCondition sets a condition on the Gateway status and returns true if it changed
+func setGatewayCondition(gw *securityv1.Gateway, conditionType string, status corev1.ConditionStatus, reason string, message string) bool {
+	now := metav1.Now()
+	for i, c := range gw.Status.Conditions {
+		if string(c.Type) != conditionType {
+			continue
+		}
+		if c.Status == status && c.Reason == reason && c.Message == message {
+			return false
+		}
+		if c.Status != status {
+			gw.Status.Conditions[i].LastTransitionTime = now
+		}
+		gw.Status.Conditions[i].Status = status
+		gw.Status.Conditions[i].Reason = reason
+		gw.Status.Conditions[i].Message = message
+		gw.Status.Conditions[i].LastUpdateTime = now
+		return true
+	}
+	gw.Status.Conditions = append(gw.Status.Conditions, appsv1.DeploymentCondition{
+		Type:               appsv1.DeploymentConditionType(conditionType),
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastUpdateTime:     now,
+		LastTransitionTime: now,
+	})
+	return true
+}
+
+// repositoryReference returns the branch, tag or commit of the Gateway repository to sync
+func repositoryReference(gw *securityv1.Gateway) util.GitReference {
+	return util.GitReference{
+		Branch: gw.Spec.App.Repository.Branch,
+		Tag:    gw.Spec.App.Repository.Tag,
+		Commit: gw.Spec.App.Repository.Commit,
+	}
+}
+
+// getRepositoryCredentials returns the git credentials from the repository secret, or nil for public repositories
+func getRepositoryCredentials(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) (*util.GitCredentials, error) {
+	if gw.Spec.App.Repository.SecretName == "" {
+		return nil, nil
+	}
+
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: gw.Spec.App.Repository.SecretName, Namespace: gw.Namespace}, secret)
+	if err != nil {
+		return nil, err
+	}
+	return util.NewGitCredentials(secret.Data), nil
+}
+
+// restmanApplyBundles imports each bundle through the Gateway Restman API in path order
+func restmanApplyBundles(ctx context.Context, api *managementAPI, podIP string, bundles map[string][]byte) error {
+	names := make([]string, 0, len(bundles))
+	for name := range bundles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c, err := api.restman(podIP)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		_, err := c.ImportBundle(ctx, bundles[name])
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// getManagementCredentials returns the Gateway admin credentials from the management secret or Gateway spec
+func getManagementCredentials(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) (string, string, error) {
+	if gw.Spec.App.Management.SecretName == "" {
+		return gw.Spec.App.Management.Username, gw.Spec.App.Management.Password, nil
+	}
+
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: gw.Spec.App.Management.SecretName, Namespace: gw.Namespace}, secret)
+	if err != nil {
+		r.Log.Error(err, "Failed to retrieve management secret", "Name", gw.Name, "Namespace", gw.Namespace)
+		return "", "", err
+	}
+
+	return string(secret.Data["SSG_ADMIN_USERNAME"]), string(secret.Data["SSG_ADMIN_PASSWORD"]), nil
+}
+
+// restmanEnabled returns true if the Gateway pods serve the Restman API
+func restmanEnabled(gw *securityv1.Gateway) bool {
+	return gw.Spec.App.Management.Restman.Enabled || (gw.Spec.App.Repository.Enabled && gw.Spec.App.Repository.Method == "restman")
+}
+
+// managementPort returns the container port that serves the Gateway management APIs
+func managementPort(gw *securityv1.Gateway) string {
+	for _, p := range gw.Spec.App.Management.Service.Ports {
+		if p.Port != 0 {
+			return strconv.Itoa(int(p.Port))
+		}
+	}
+	return "9443"
+}
+
 func reconcileHPA(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) error {
 	currHPA := &autoscalingv2.HorizontalPodAutoscaler{}
 
@@ -251,8 +664,10 @@ func reconcileConfigMap(r *GatewayReconciler, name string, ctx context.Context,
 		return nil
 	}
 
-	if !reflect.DeepEqual(currMap.Data, cm.Data) {
+	if (len(currMap.Data) != 0 || len(cm.Data) != 0) && !reflect.DeepEqual(currMap.Data, cm.Data) {
+		r.Log.Info("Updating ConfigMap", "Name", name, "Namespace", gw.Namespace)
 		ctrl.SetControllerReference(gw, cm, r.Scheme)
+		cm.ResourceVersion = currMap.ResourceVersion
 		return r.Update(ctx, cm)
 	}
 
@@ -282,6 +697,153 @@ func reconcileSecret(r *GatewayReconciler, ctx context.Context, gw *securityv1.G
 	return nil
 }
 
+// reconcileBundleSecret creates or updates a Secret holding a bootstrap bundle generated by the operator
+func reconcileBundleSecret(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway, secret *corev1.Secret) error {
+	currSecret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: gw.Namespace}, currSecret)
+	if err != nil && k8serrors.IsNotFound(err) {
+		r.Log.Info("Creating Secret", "Name", secret.Name, "Namespace", gw.Namespace)
+		ctrl.SetControllerReference(gw, secret, r.Scheme)
+		err = r.Create(ctx, secret)
+		if err != nil {
+			r.Log.Error(err, "Failed creating Secret", "Name", gw.Name, "Namespace", gw.Namespace)
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(currSecret.Data, secret.Data) {
+		r.Log.Info("Updating Secret", "Name", secret.Name, "Namespace", gw.Namespace)
+		ctrl.SetControllerReference(gw, secret, r.Scheme)
+		secret.ResourceVersion = currSecret.ResourceVersion
+		return r.Update(ctx, secret)
+	}
+	return nil
+}
+
+// reconcileBundleConfigMap creates or updates a ConfigMap holding a bootstrap bundle generated by the operator
+func reconcileBundleConfigMap(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway, cm *corev1.ConfigMap) error {
+	currMap := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Name: cm.Name, Namespace: gw.Namespace}, currMap)
+	if err != nil && k8serrors.IsNotFound(err) {
+		r.Log.Info("Creating ConfigMap", "Name", cm.Name, "Namespace", gw.Namespace)
+		ctrl.SetControllerReference(gw, cm, r.Scheme)
+		err = r.Create(ctx, cm)
+		if err != nil {
+			r.Log.Error(err, "Failed creating ConfigMap", "Name", gw.Name, "Namespace", gw.Namespace)
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(currMap.Data, cm.Data) || !reflect.DeepEqual(currMap.BinaryData, cm.BinaryData) {
+		r.Log.Info("Updating ConfigMap", "Name", cm.Name, "Namespace", gw.Namespace)
+		ctrl.SetControllerReference(gw, cm, r.Scheme)
+		cm.ResourceVersion = currMap.ResourceVersion
+		return r.Update(ctx, cm)
+	}
+	return nil
+}
+
+// gatewaysForSecret returns a request for each Gateway reading secure passwords, JDBC credentials,
+// private keys, trusted certificates, cluster property or template values from the Secret, or mounting
+// the bundles of a Repository from it
+func (r *GatewayReconciler) gatewaysForSecret(obj client.Object) []reconcile.Request {
+	return r.gatewaysReferencing(obj, func(gw *securityv1.Gateway) []string {
+		names := []string{}
+		for _, sp := range util.SecurePasswords(gw) {
+			names = append(names, sp.SecretName)
+		}
+		for _, pk := range gw.Spec.App.PrivateKeys {
+			names = append(names, pk.SecretName)
+		}
+		for _, tc := range gw.Spec.App.TrustedCerts {
+			names = append(names, tc.SecretName)
+		}
+		for _, p := range gw.Spec.App.ClusterProperties.Properties {
+			if p.ValueFrom != nil && p.ValueFrom.SecretKeyRef != nil {
+				names = append(names, p.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+		if gw.Spec.App.Templating.Enabled {
+			for _, v := range gw.Spec.App.Templating.Values {
+				names = append(names, v.SecretName)
+			}
+		}
+		return append(names, mountedRepositoryBundles(gw)...)
+	})
+}
+
+// gatewaysForConfigMap returns a request for each Gateway reading trusted certificates, cluster property or
+// template values from the ConfigMap, validating, resolving or applying the bundles it holds or mounting the
+// bundles of a Repository from it
+func (r *GatewayReconciler) gatewaysForConfigMap(obj client.Object) []reconcile.Request {
+	return r.gatewaysReferencing(obj, func(gw *securityv1.Gateway) []string {
+		names := []string{}
+		for _, tc := range gw.Spec.App.TrustedCerts {
+			names = append(names, tc.ConfigMapName)
+		}
+		for _, p := range gw.Spec.App.ClusterProperties.Properties {
+			if p.ValueFrom != nil && p.ValueFrom.ConfigMapKeyRef != nil {
+				names = append(names, p.ValueFrom.ConfigMapKeyRef.Name)
+			}
+		}
+		// bundle ConfigMaps are validated, and resolved when templating is enabled
+		for _, b := range gw.Spec.App.Bundle {
+			if b.Type == "configMap" {
+				names = append(names, b.Name)
+			}
+		}
+		if gw.Spec.App.Management.Graphman.Enabled {
+			names = append(names, gw.Spec.App.Management.Graphman.ConfigMaps...)
+		}
+		if gw.Spec.App.Templating.Enabled {
+			for _, v := range gw.Spec.App.Templating.Values {
+				names = append(names, v.ConfigMapName)
+			}
+		}
+		return append(names, mountedRepositoryBundles(gw)...)
+	})
+}
+
+// mountedRepositoryBundles returns the ConfigMaps or Secrets generated for Repositories referenced with the
+// mount method, the Deployment is updated with their checksum when they change
+func mountedRepositoryBundles(gw *securityv1.Gateway) []string {
+	names := []string{}
+	for _, ref := range gw.Spec.App.RepositoryReferences {
+		if ref.Method == "mount" {
+			names = append(names, util.RepositoryBundleName(gw, ref.Name))
+		}
+	}
+	return names
+}
+
+// gatewaysReferencing returns a request for each Gateway in the namespace of obj whose references include its name
+func (r *GatewayReconciler) gatewaysReferencing(obj client.Object, references func(gw *securityv1.Gateway) []string) []reconcile.Request {
+	gwList := &securityv1.GatewayList{}
+	if err := r.List(context.Background(), gwList, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "Failed to list Gateways", "Namespace", obj.GetNamespace())
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for i := range gwList.Items {
+		gw := &gwList.Items[i]
+		for _, name := range references(gw) {
+			if name == obj.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: gw.Name, Namespace: gw.Namespace}})
+				break
+			}
+		}
+	}
+	return requests
+}
+
 func reconcileService(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) error {
 	currService := &corev1.Service{}
 	svc := service.NewService(gw)
@@ -296,9 +858,70 @@ func reconcileService(r *GatewayReconciler, ctx context.Context, gw *securityv1.
 		}
 		return nil
 	}
+	if err != nil {
+		return err
+	}
+
+	if serviceChanged(currService, svc) {
+		r.Log.Info("Updating Service", "Name", gw.Name, "Namespace", gw.Namespace)
+		return updateService(r, ctx, currService, svc)
+	}
 	return nil
 }
 
+// serviceChanged returns true if the ports, type or annotations of the desired Service differ from the current one.
+// Fields the API server fills in, like node ports and the cluster IP, are ignored as are annotations added by others.
+func serviceChanged(curr *corev1.Service, desired *corev1.Service) bool {
+	if curr.Spec.Type != desired.Spec.Type && desired.Spec.Type != "" {
+		return true
+	}
+	for k, v := range desired.Annotations {
+		if curr.Annotations[k] != v {
+			return true
+		}
+	}
+	if len(curr.Spec.Ports) != len(desired.Spec.Ports) {
+		return true
+	}
+	for i, p := range desired.Spec.Ports {
+		c := curr.Spec.Ports[i]
+		protocol := p.Protocol
+		if protocol == "" {
+			protocol = corev1.ProtocolTCP
+		}
+		if c.Name != p.Name || c.Port != p.Port || c.TargetPort != p.TargetPort || c.Protocol != protocol {
+			return true
+		}
+	}
+	return false
+}
+
+// updateService applies the ports, type and annotations of the desired Service to the current one, keeping
+// the node ports already allocated to ports that remain
+func updateService(r *GatewayReconciler, ctx context.Context, curr *corev1.Service, desired *corev1.Service) error {
+	nodePorts := map[string]int32{}
+	for _, p := range curr.Spec.Ports {
+		nodePorts[p.Name] = p.NodePort
+	}
+	ports := append([]corev1.ServicePort{}, desired.Spec.Ports...)
+	if desired.Spec.Type == corev1.ServiceTypeNodePort || desired.Spec.Type == corev1.ServiceTypeLoadBalancer {
+		for i := range ports {
+			ports[i].NodePort = nodePorts[ports[i].Name]
+		}
+	}
+	curr.Spec.Ports = ports
+	if desired.Spec.Type != "" {
+		curr.Spec.Type = desired.Spec.Type
+	}
+	if len(desired.Annotations) > 0 && curr.Annotations == nil {
+		curr.Annotations = map[string]string{}
+	}
+	for k, v := range desired.Annotations {
+		curr.Annotations[k] = v
+	}
+	return r.Update(ctx, curr)
+}
+
 func reconcileManagementService(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) error {
 	currService := &corev1.Service{}
 	svc := service.NewManagementService(gw)
@@ -313,6 +936,14 @@ func reconcileManagementService(r *GatewayReconciler, ctx context.Context, gw *s
 		}
 		return nil
 	}
+	if err != nil {
+		return err
+	}
+
+	if serviceChanged(currService, svc) {
+		r.Log.Info("Updating Management Service", "Name", gw.Name, "Namespace", gw.Namespace)
+		return updateService(r, ctx, currService, svc)
+	}
 	return nil
 }
 
@@ -342,7 +973,11 @@ func reconcileIngress(r *GatewayReconciler, ctx context.Context, gw *securityv1.
 func reconcileDeployment(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) error {
 	currDeployment := &appsv1.Deployment{}
 	dep := gateway.NewDeployment(gw)
-	err := r.Get(ctx, types.NamespacedName{Name: gw.Name, Namespace: gw.Namespace}, currDeployment)
+	err := setChecksumAnnotations(r, ctx, gw, dep)
+	if err != nil {
+		return err
+	}
+	err = r.Get(ctx, types.NamespacedName{Name: gw.Name, Namespace: gw.Namespace}, currDeployment)
 	if err != nil && k8serrors.IsNotFound(err) {
 		r.Log.Info("Creating Deployment", "Name", gw.Name, "Namespace", gw.Namespace)
 		ctrl.SetControllerReference(gw, dep, r.Scheme)
@@ -372,14 +1007,129 @@ func reconcileDeployment(r *GatewayReconciler, ctx context.Context, gw *security
 		update = true
 	}
 
+	// annotations the operator doesn't manage (e.g. kubectl rollout restart) are carried over
+	for k, v := range currDeployment.Spec.Template.Annotations {
+		if _, ok := dep.Spec.Template.Annotations[k]; !ok && k != "commitId" && !strings.HasPrefix(k, checksumAnnotationPrefix) {
+			if dep.Spec.Template.Annotations == nil {
+				dep.Spec.Template.Annotations = map[string]string{}
+			}
+			dep.Spec.Template.Annotations[k] = v
+		}
+	}
+
+	if !reflect.DeepEqual(currDeployment.Spec.Template.Annotations, dep.Spec.Template.Annotations) {
+		update = true
+	}
+
 	if update {
 		r.Log.Info("Updating Deployment", "Name", gw.Name, "Namespace", gw.Namespace)
 		ctrl.SetControllerReference(gw, dep, r.Scheme)
+		dep.ResourceVersion = currDeployment.ResourceVersion
 		return r.Update(ctx, dep)
 	}
 	return nil
 }
 
+// checksumAnnotationPrefix prefixes the pod template annotations that hold the checksum of each
+// ConfigMap and Secret used by the Gateway pod
+const checksumAnnotationPrefix = "checksum.security.brcmlabs.com/"
+
+// setChecksumAnnotations annotates the pod template with a checksum of every ConfigMap and Secret the
+// Gateway pod mounts or reads env from, so the Deployment rolls exactly when one of them changes.
+// Template values aren't included, templated bundles are mounted from resolved copies that only change
+// when a placeholder they use does, and bundles applied live are applied again by reconcileTemplateValues.
+func setChecksumAnnotations(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway, dep *appsv1.Deployment) error {
+	refs := map[string]bool{}
+	podSpec := dep.Spec.Template.Spec
+	for _, v := range podSpec.Volumes {
+		if v.ConfigMap != nil {
+			refs["configmap/"+v.ConfigMap.Name] = true
+		}
+		if v.Secret != nil {
+			refs["secret/"+v.Secret.SecretName] = true
+		}
+	}
+
+	containers := append(append([]corev1.Container{}, podSpec.InitContainers...), podSpec.Containers...)
+	for _, c := range containers {
+		for _, e := range c.EnvFrom {
+			if e.ConfigMapRef != nil {
+				refs["configmap/"+e.ConfigMapRef.Name] = true
+			}
+			if e.SecretRef != nil {
+				refs["secret/"+e.SecretRef.Name] = true
+			}
+		}
+		for _, e := range c.Env {
+			if e.ValueFrom == nil {
+				continue
+			}
+			if e.ValueFrom.ConfigMapKeyRef != nil {
+				refs["configmap/"+e.ValueFrom.ConfigMapKeyRef.Name] = true
+			}
+			if e.ValueFrom.SecretKeyRef != nil {
+				refs["secret/"+e.ValueFrom.SecretKeyRef.Name] = true
+			}
+		}
+	}
+
+	// cluster properties applied live don't need the pods to restart
+	if liveClusterProperties(gw) {
+		delete(refs, "configmap/"+gw.Name+"-cwp-bundle")
+		delete(refs, "secret/"+gw.Name+"-cwp-bundle")
+	}
+
+	for _, exclusion := range gw.Spec.App.ChecksumExclusions {
+		kind, name, _ := strings.Cut(exclusion, "/")
+		delete(refs, strings.ToLower(kind)+"/"+name)
+	}
+
+	for ref := range refs {
+		kind, name, _ := strings.Cut(ref, "/")
+		data := map[string][]byte{}
+		var err error
+		switch kind {
+		case "configmap":
+			cm := &corev1.ConfigMap{}
+			err = r.Get(ctx, types.NamespacedName{Name: name, Namespace: gw.Namespace}, cm)
+			for k, v := range cm.Data {
+				data[k] = []byte(v)
+			}
+			for k, v := range cm.BinaryData {
+				data[k] = v
+			}
+		case "secret":
+			secret := &corev1.Secret{}
+			err = r.Get(ctx, types.NamespacedName{Name: name, Namespace: gw.Namespace}, secret)
+			data = secret.Data
+		}
+		if err != nil {
+			// missing inputs are either optional or will stop the pod from starting until they're created
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			r.Log.Error(err, "Failed to retrieve "+kind+" for checksum", "Name", gw.Name, "Namespace", gw.Namespace, kind, name)
+			return err
+		}
+
+		if dep.Spec.Template.Annotations == nil {
+			dep.Spec.Template.Annotations = map[string]string{}
+		}
+		dep.Spec.Template.Annotations[checksumAnnotationKey(kind, name)] = util.Checksum(data)
+	}
+	return nil
+}
+
+// checksumAnnotationKey returns the annotation for a ConfigMap or Secret, hashing names that are
+// too long for an annotation name
+func checksumAnnotationKey(kind string, name string) string {
+	key := kind + "." + name
+	if len(key) > 63 {
+		key = kind + "." + util.Checksum(map[string][]byte{"name": []byte(name)})[:16]
+	}
+	return checksumAnnotationPrefix + key
+}
+
 func updateGatewayStatus(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) error {
 	gatewayStatus := gw.Status
 
@@ -401,7 +1151,15 @@ func updateGatewayStatus(r *GatewayReconciler, ctx context.Context, gw *security
 		gatewayStatus.State = "ready"
 	}
 
-	gatewayStatus.Conditions = dep.Status.Conditions
+	// conditions set by the operator are kept alongside the Deployment conditions
+	gatewayStatus.Conditions = append([]appsv1.DeploymentCondition{}, dep.Status.Conditions...)
+	for _, c := range gw.Status.Conditions {
+		switch c.Type {
+		case appsv1.DeploymentAvailable, appsv1.DeploymentProgressing, appsv1.DeploymentReplicaFailure:
+		default:
+			gatewayStatus.Conditions = append(gatewayStatus.Conditions, c)
+		}
+	}
 
 	podList := &corev1.PodList{}
 	listOpts := []client.ListOption{
@@ -425,12 +1183,27 @@ func updateGatewayStatus(r *GatewayReconciler, ctx context.Context, gw *security
 			}
 		}
 
-		gatewayStatus.Gateway = append(gatewayStatus.Gateway, securityv1.GatewayState{
+		state := securityv1.GatewayState{
 			Name:      podList.Items[p].Name,
 			Phase:     podList.Items[p].Status.Phase,
 			Ready:     ready,
 			StartTime: podList.Items[p].Status.StartTime.String(),
-		})
+		}
+
+		for _, prev := range gw.Status.Gateway {
+			if prev.Name == state.Name {
+				state.CommitID = prev.CommitID
+				state.SyncStatus = prev.SyncStatus
+				state.SyncError = prev.SyncError
+				state.BundleChecksum = prev.BundleChecksum
+				state.EntitiesApplied = prev.EntitiesApplied
+				state.EntitiesFailed = prev.EntitiesFailed
+				state.RepositoryCommits = prev.RepositoryCommits
+				state.ClusterPropertiesChecksum = prev.ClusterPropertiesChecksum
+			}
+		}
+
+		gatewayStatus.Gateway = append(gatewayStatus.Gateway, state)
 	}
 
 	if !reflect.DeepEqual(gatewayStatus, gw.Status) {
@@ -491,5 +1264,9 @@ func (r *GatewayReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		//Watches(&source.Kind{Type: &securityv1.Gateway{}}, &handler.EnqueueRequestForObject{}).
 		For(&securityv1.Gateway{}).
+		Watches(r.RepositoryPoller.Source(), &handler.EnqueueRequestForObject{}).
+		Watches(&source.Kind{Type: &securityv1.Repository{}}, handler.EnqueueRequestsFromMapFunc(r.gatewaysForRepository), builder.WithPredicates(repositoryCommitChanged)).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.gatewaysForSecret)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.gatewaysForConfigMap)).
 		Complete(r)
 }
@@ -0,0 +1,975 @@
+package gateway
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/graphman"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/repository"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/restman"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/restman/restmantest"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitclient "github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/file"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newTestGateway returns a Gateway with a single ready pod whose management APIs are served by the fake Gateway at
+// serverURL, verified with its certificate caCert
+func newTestGateway(t *testing.T, serverURL string, caCert []byte) (*securityv1.Gateway, []client.Object) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.Atoi(u.Port())
+
+	gw := &securityv1.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "ssg", Namespace: "default", Generation: 1}}
+	gw.Spec.App.Management.Username = "admin"
+	gw.Spec.App.Management.Password = "7layer"
+	gw.Spec.App.Management.Service.Ports = []securityv1.Ports{{Name: "management", Port: int32(port)}}
+	gw.Spec.App.Management.CA = securityv1.ManagementCA{SecretName: "ssg-management-ca"}
+	gw.Status.Gateway = []securityv1.GatewayState{{Name: "ssg-0", Ready: true}}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "ssg-0", Namespace: gw.Namespace, Labels: util.DefaultLabels(gw)},
+		Status:     corev1.PodStatus{PodIP: u.Hostname()},
+	}
+	ca := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "ssg-management-ca", Namespace: gw.Namespace},
+		Data:       map[string][]byte{"ca.crt": caCert},
+	}
+	return gw, []client.Object{pod, ca}
+}
+
+// newTestReconciler returns a reconciler backed by a fake client holding gw and objs. gw is refreshed from
+// the client so it can be updated.
+func newTestReconciler(t *testing.T, gw *securityv1.Gateway, objs ...client.Object) *GatewayReconciler {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := securityv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(objs, gw)...).Build()
+	if err := c.Get(context.Background(), types.NamespacedName{Name: gw.Name, Namespace: gw.Namespace}, gw); err != nil {
+		t.Fatal(err)
+	}
+	return &GatewayReconciler{Client: c, Log: logr.Discard(), Scheme: scheme}
+}
+
+// conditionStatus returns the status of the Gateway condition of the type, or an empty status if it isn't set
+func conditionStatus(gw *securityv1.Gateway, conditionType string) corev1.ConditionStatus {
+	for _, c := range gw.Status.Conditions {
+		if string(c.Type) == conditionType {
+			return c.Status
+		}
+	}
+	return ""
+}
+
+func TestRestmanApplyBundles(t *testing.T) {
+	s := restmantest.NewServer("admin", "7layer")
+	defer s.Close()
+	gw, objs := newTestGateway(t, s.URL, s.CACert())
+	r := newTestReconciler(t, gw, objs...)
+	ctx := context.Background()
+
+	api, err := getManagementAPI(r, ctx, gw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := util.BuildCWPBundle(map[string]string{"cwp.one": "1"})
+	second, _ := util.BuildCWPBundle(map[string]string{"cwp.two": "2"})
+	if err := restmanApplyBundles(ctx, api, "127.0.0.1", map[string][]byte{"b/second.bundle": second, "a/first.bundle": first}); err != nil {
+		t.Fatal(err)
+	}
+	bundles := s.Bundles()
+	if len(bundles) != 2 || string(bundles[0]) != string(first) || string(bundles[1]) != string(second) {
+		t.Fatalf("expected bundles to be imported in path order, got %d bundles", len(bundles))
+	}
+
+	s.BundleErrors = []restman.Mapping{{Type: "CLUSTER_PROPERTY", SrcID: "1", ErrorType: "InvalidResource"}}
+	err = restmanApplyBundles(ctx, api, "127.0.0.1", map[string][]byte{"a/first.bundle": first})
+	if err == nil || !strings.HasPrefix(err.Error(), "a/first.bundle: ") {
+		t.Fatalf("expected the failing bundle to be named, got %v", err)
+	}
+
+	// the pods are reached by IP, a certificate that doesn't name the server isn't trusted
+	gw.Spec.App.Management.CA.ServerName = "gateway.invalid"
+	api, err = getManagementAPI(r, ctx, gw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.opts.RetryWait = time.Millisecond
+	s.BundleErrors = nil
+	if err := restmanApplyBundles(ctx, api, "127.0.0.1", map[string][]byte{"a/first.bundle": first}); err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Fatalf("expected certificate verification to fail, got %v", err)
+	}
+}
+
+func TestReconcileLiveClusterProperties(t *testing.T) {
+	s := restmantest.NewServer("admin", "7layer")
+	defer s.Close()
+	gw, objs := newTestGateway(t, s.URL, s.CACert())
+	gw.Spec.App.Management.Restman.Enabled = true
+	gw.Spec.App.ClusterProperties = securityv1.ClusterProperties{
+		Enabled:    true,
+		Live:       true,
+		Properties: []securityv1.ClusterProperty{{Name: "cwp.one", Value: "1"}, {Name: "cwp.two", Value: "2"}},
+	}
+	r := newTestReconciler(t, gw, objs...)
+	ctx := context.Background()
+
+	if err := reconcileLiveClusterProperties(r, ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := s.ClusterProperty("cwp.two"); !ok || value != "2" {
+		t.Fatalf("expected cwp.two to be applied, got %s", value)
+	}
+	if gw.Status.ClusterProperties == nil || len(gw.Status.ClusterProperties.Names) != 2 || gw.Status.Gateway[0].ClusterPropertiesChecksum != gw.Status.ClusterProperties.Checksum {
+		t.Fatalf("unexpected status %+v %+v", gw.Status.ClusterProperties, gw.Status.Gateway[0])
+	}
+
+	gw.Spec.App.ClusterProperties.Properties = []securityv1.ClusterProperty{{Name: "cwp.one", Value: "one"}}
+	if err := reconcileLiveClusterProperties(r, ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := s.ClusterProperty("cwp.one"); value != "one" {
+		t.Fatalf("expected cwp.one to be updated, got %s", value)
+	}
+	if _, ok := s.ClusterProperty("cwp.two"); ok {
+		t.Fatal("expected cwp.two to be deleted once it's removed from the spec")
+	}
+
+	stored := &securityv1.Gateway{}
+	if err := r.Get(ctx, types.NamespacedName{Name: gw.Name, Namespace: gw.Namespace}, stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status.ClusterProperties == nil || len(stored.Status.ClusterProperties.Names) != 1 {
+		t.Fatalf("expected the status to be stored, got %+v", stored.Status.ClusterProperties)
+	}
+}
+
+func TestUpdateManagementCertificateCondition(t *testing.T) {
+	gw, objs := newTestGateway(t, "https://127.0.0.1:9443", nil)
+	gw.Spec.App.Management.CA = securityv1.ManagementCA{}
+	r := newTestReconciler(t, gw, objs...)
+	ctx := context.Background()
+
+	condition := func() (corev1.ConditionStatus, string) {
+		for _, c := range gw.Status.Conditions {
+			if string(c.Type) == managementCertificateCondition {
+				return c.Status, c.Reason
+			}
+		}
+		return "", ""
+	}
+	if err := updateManagementCertificateCondition(r, ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	if status, reason := condition(); status != corev1.ConditionFalse || reason != "NoCA" {
+		t.Fatalf("expected unverified certificates to be reported, got %s %s", status, reason)
+	}
+
+	gw.Spec.App.Management.CA = securityv1.ManagementCA{SecretName: "ssg-management-ca"}
+	if err := updateManagementCertificateCondition(r, ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	if status, reason := condition(); status != corev1.ConditionTrue || reason != "CAConfigured" {
+		t.Fatalf("expected verified certificates to be reported, got %s %s", status, reason)
+	}
+}
+
+func TestApplyRestmanBundlesRollout(t *testing.T) {
+	s := restmantest.NewServer("admin", "7layer")
+	defer s.Close()
+	gw, objs := newTestGateway(t, s.URL, s.CACert())
+	r := newTestReconciler(t, gw, objs...)
+	r.RepositoryCache = util.NewRepositoryCache(t.TempDir())
+	ctx := context.Background()
+
+	url := "https://git.example.com/bundles.git"
+	bundle, _ := util.BuildCWPBundle(map[string]string{"cwp.one": "1"})
+	_, commit, err := r.RepositoryCache.CommitFiles(url, map[string][]byte{"bundles/cwp.bundle": bundle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw.Spec.App.Repository = securityv1.GatewayRepository{Enabled: true, Method: "restman", URL: url, BundleDirectory: "bundles"}
+	gw.Status.BundleCommitID = commit.Hash.String()
+
+	condition := func() string {
+		for _, c := range gw.Status.Conditions {
+			if string(c.Type) == bundlesAppliedCondition {
+				return c.Reason
+			}
+		}
+		return ""
+	}
+
+	// a new pod that fails to apply its first commit rolls the Deployment
+	s.BundleErrors = []restman.Mapping{{Type: "CLUSTER_PROPERTY", SrcID: "1", ErrorType: "InvalidResource"}}
+	if err := applyRestmanBundles(r, ctx, gw, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if gw.Status.Gateway[0].SyncStatus != "failed" || gw.Status.RolloutCommitID != gw.Status.BundleCommitID || condition() != "RollingPods" {
+		t.Fatalf("expected a rollout after the first apply failed, got %+v %s %s", gw.Status.Gateway[0], gw.Status.RolloutCommitID, condition())
+	}
+
+	// the replacement pods failing again don't roll the Deployment twice for the same commit
+	if err := applyRestmanBundles(r, ctx, gw, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if gw.Status.RolloutCommitID != gw.Status.BundleCommitID || condition() != "ApplyFailed" {
+		t.Fatalf("expected the failure to be reported without another rollout, got %s %s", gw.Status.RolloutCommitID, condition())
+	}
+
+	s.BundleErrors = nil
+	if err := applyRestmanBundles(r, ctx, gw, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if gw.Status.Gateway[0].SyncStatus != "applied" || gw.Status.Gateway[0].CommitID != gw.Status.BundleCommitID || condition() != "Applied" {
+		t.Fatalf("expected the commit to be applied, got %+v %s", gw.Status.Gateway[0], condition())
+	}
+}
+
+func TestReconcileTemplateValues(t *testing.T) {
+	s := restmantest.NewServer("admin", "7layer")
+	defer s.Close()
+	gw, objs := newTestGateway(t, s.URL, s.CACert())
+	gw.Spec.App.Templating = securityv1.Templating{Enabled: true, Values: []securityv1.TemplateValues{{ConfigMapName: "ssg-values"}}}
+	values := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "ssg-values", Namespace: gw.Namespace},
+		Data:       map[string]string{"greeting": "hello"},
+	}
+	r := newTestReconciler(t, gw, append(objs, values)...)
+	r.RepositoryCache = util.NewRepositoryCache(t.TempDir())
+	ctx := context.Background()
+
+	url := "https://git.example.com/bundles.git"
+	bundle, _ := util.BuildCWPBundle(map[string]string{"cwp.greeting": "${env.greeting}"})
+	_, commit, err := r.RepositoryCache.CommitFiles(url, map[string][]byte{"cwp.bundle": bundle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw.Spec.App.Repository = securityv1.GatewayRepository{Enabled: true, Method: "restman", URL: url}
+	gw.Status.BundleCommitID = commit.Hash.String()
+
+	apply := func() {
+		if err := reconcileTemplateValues(r, ctx, gw); err != nil {
+			t.Fatal(err)
+		}
+		tmpl, err := getBundleTemplate(r, ctx, gw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := applyRestmanBundles(r, ctx, gw, nil, tmpl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	apply()
+	if len(s.Bundles()) != 1 || !strings.Contains(string(s.Bundles()[0]), "hello") || gw.Status.TemplateChecksum == "" {
+		t.Fatalf("expected the resolved bundle to be applied, got %q", s.Bundles())
+	}
+
+	// unchanged values don't apply the commit again
+	apply()
+	if len(s.Bundles()) != 1 {
+		t.Fatalf("expected no bundle to be applied, got %d", len(s.Bundles()))
+	}
+
+	values.Data["greeting"] = "goodbye"
+	if err := r.Update(ctx, values); err != nil {
+		t.Fatal(err)
+	}
+	apply()
+	if len(s.Bundles()) != 2 || !strings.Contains(string(s.Bundles()[1]), "goodbye") || gw.Status.Gateway[0].CommitID != commit.Hash.String() {
+		t.Fatalf("expected the bundle to be applied again with the new value, got %q", s.Bundles())
+	}
+
+	// template values don't roll the pods
+	dep := &appsv1.Deployment{}
+	if err := setChecksumAnnotations(r, ctx, gw, dep); err != nil {
+		t.Fatal(err)
+	}
+	for k := range dep.Spec.Template.Annotations {
+		if strings.Contains(k, "ssg-values") {
+			t.Fatalf("expected no checksum of the template values, got %s", k)
+		}
+	}
+}
+
+func TestReconcileInvalidBundleConfigMap(t *testing.T) {
+	gw := &securityv1.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "portal", Namespace: "apps"}}
+	gw.Spec.License.SecretName = "portal-license"
+	gw.Spec.App.Image = "caapim/gateway:10.1.00"
+	gw.Spec.App.Bundle = []securityv1.Bundle{{Type: "configMap", Name: "portal-bundles"}}
+	license := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "portal-license", Namespace: gw.Namespace},
+		Data:       map[string][]byte{"license.xml": []byte("<license/>")},
+	}
+	valid, _ := util.BuildCWPBundle(map[string]string{"portal.enabled": "true"})
+	bundles := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "portal-bundles", Namespace: gw.Namespace},
+		Data:       map[string]string{"cwp.bundle": string(valid)},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "portal-0", Namespace: gw.Namespace, Labels: util.DefaultLabels(gw)},
+		Status:     corev1.PodStatus{StartTime: &metav1.Time{Time: time.Now()}},
+	}
+	r := newTestReconciler(t, gw, license, bundles, pod)
+	r.RepositoryPoller = repository.NewPoller(time.Minute, 0, gw, logr.Discard())
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: gw.Name, Namespace: gw.Namespace}}
+
+	reconcile := func() (*securityv1.Gateway, *appsv1.Deployment) {
+		if _, err := r.Reconcile(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+		latest := &securityv1.Gateway{}
+		if err := r.Get(ctx, req.NamespacedName, latest); err != nil {
+			t.Fatal(err)
+		}
+		dep := &appsv1.Deployment{}
+		if err := r.Get(ctx, req.NamespacedName, dep); err != nil {
+			t.Fatal(err)
+		}
+		return latest, dep
+	}
+
+	latest, dep := reconcile()
+	checksum := dep.Spec.Template.Annotations[checksumAnnotationKey("configmap", "portal-bundles")]
+	if checksum == "" || conditionStatus(latest, bundlesValidCondition) != corev1.ConditionTrue {
+		t.Fatalf("expected the valid bundle to be delivered, got %v", dep.Spec.Template.Annotations)
+	}
+
+	bundles.Data["cwp.bundle"] = "<l7:Bundle"
+	if err := r.Update(ctx, bundles); err != nil {
+		t.Fatal(err)
+	}
+	latest, dep = reconcile()
+	if conditionStatus(latest, bundlesValidCondition) != corev1.ConditionFalse || len(latest.Status.BundleErrors) != 1 || latest.Status.BundleErrors[0].Source != "configmaps/portal-bundles" {
+		t.Fatalf("expected the broken bundle to be reported, got %v", latest.Status.BundleErrors)
+	}
+	if dep.Spec.Template.Annotations[checksumAnnotationKey("configmap", "portal-bundles")] != checksum {
+		t.Fatal("expected the Deployment to keep the last valid bundle")
+	}
+
+	bundles.Data["cwp.bundle"] = string(valid) + "\n"
+	if err := r.Update(ctx, bundles); err != nil {
+		t.Fatal(err)
+	}
+	latest, dep = reconcile()
+	if conditionStatus(latest, bundlesValidCondition) != corev1.ConditionTrue || latest.Status.BundleErrors != nil || dep.Spec.Template.Annotations[checksumAnnotationKey("configmap", "portal-bundles")] == checksum {
+		t.Fatalf("expected the fixed bundle to be delivered, got %v", latest.Status.BundleErrors)
+	}
+}
+
+// newOriginRepository returns the file URL of a git repository that commit adds commits to. The repository
+// is served in process for the duration of the test so no git binary is needed.
+func newOriginRepository(t *testing.T) (string, func(files map[string][]byte) string) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "file://" + dir
+	ep, err := transport.NewEndpoint(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gitclient.InstallProtocol("file", server.NewServer(server.MapLoader{ep.String(): repo.Storer}))
+	t.Cleanup(func() { gitclient.InstallProtocol("file", file.DefaultClient) })
+
+	commit := func(files map[string][]byte) string {
+		for name, data := range files {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := wt.Add(name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		sig := &object.Signature{Name: "bundles", Email: "bundles@example.com", When: time.Now()}
+		hash, err := wt.Commit("update bundles", &git.CommitOptions{Author: sig})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash.String()
+	}
+	return url, commit
+}
+
+func TestReconcileBundlesDryRun(t *testing.T) {
+	s := restmantest.NewServer("admin", "7layer")
+	defer s.Close()
+	gw, objs := newTestGateway(t, s.URL, s.CACert())
+	url, commit := newOriginRepository(t)
+	bundle := func(props map[string]string) map[string][]byte {
+		data, _ := util.BuildCWPBundle(props)
+		return map[string][]byte{"bundles/cwp.bundle": data, "README.md": []byte("bundles for " + gw.Name)}
+	}
+	first := commit(bundle(map[string]string{"cwp.timeout": "30"}))
+	gw.Spec.App.Repository = securityv1.GatewayRepository{Enabled: true, Method: "restman", URL: url, BundleDirectory: "bundles"}
+	r := newTestReconciler(t, gw, objs...)
+	r.RepositoryCache = util.NewRepositoryCache(t.TempDir())
+	ctx := context.Background()
+
+	reconcile := func() {
+		// a new poller looks the repository up straight away
+		r.RepositoryPoller = repository.NewPoller(time.Minute, 0, gw, logr.Discard())
+		if err := reconcileBundles(r, ctx, gw); err != nil {
+			t.Fatal(err)
+		}
+		if err := updateHeldBackCondition(r, ctx, gw); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reconcile()
+	if len(s.Bundles()) != 1 || gw.Status.Gateway[0].CommitID != first {
+		t.Fatalf("expected the commit to be applied before dry run is enabled, got %+v", gw.Status.Gateway[0])
+	}
+
+	gw.Spec.App.Repository.DryRun = true
+	if err := r.Update(ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	second := commit(bundle(map[string]string{"cwp.timeout": "60", "cwp.retries": "3"}))
+	reconcile()
+	pending := gw.Status.PendingChanges
+	if len(s.Bundles()) != 1 || gw.Status.BundleCommitID != first || pending == nil || pending.CommitID != second || pending.Created != 1 || pending.Updated != 1 || pending.Pod != "ssg-0" {
+		t.Fatalf("expected the commit to wait for approval with its changes, got %+v", pending)
+	}
+	if conditionStatus(gw, heldBackCondition) != corev1.ConditionTrue {
+		t.Fatalf("expected the %s condition, got %v", heldBackCondition, gw.Status.Conditions)
+	}
+
+	// the pending changes follow the branch, a later commit is approved with the changes of both
+	third := commit(map[string][]byte{"README.md": []byte("bundles for the ssg Gateway")})
+	reconcile()
+	pending = gw.Status.PendingChanges
+	if gw.Status.CommitID != third || pending == nil || pending.CommitID != third || pending.Created != 1 || pending.Updated != 1 {
+		t.Fatalf("expected the changes to wait for approval of the latest commit, got %s %+v", gw.Status.CommitID, pending)
+	}
+
+	gw.Annotations = map[string]string{approveCommitAnnotation: "0000000000000000000000000000000000000000, " + third}
+	if err := r.Update(ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+	if len(s.Bundles()) != 2 || gw.Status.BundleCommitID != third || gw.Status.PendingChanges != nil || gw.Status.Gateway[0].CommitID != third {
+		t.Fatalf("expected the approved commit to be applied, got %+v %+v", gw.Status.Gateway[0], gw.Status.PendingChanges)
+	}
+	if conditionStatus(gw, heldBackCondition) != corev1.ConditionFalse {
+		t.Fatalf("expected the %s condition to be cleared, got %v", heldBackCondition, gw.Status.Conditions)
+	}
+}
+
+// mountedBundle reconciles the Deployment of gw and returns the checksum it records for the bootstrap bundle
+// Secret or ConfigMap kind/name, failing the test unless the gateway container mounts it
+func mountedBundle(t *testing.T, r *GatewayReconciler, gw *securityv1.Gateway, kind string, name string) string {
+	ctx := context.Background()
+	if err := reconcileDeployment(r, ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	dep := &appsv1.Deployment{}
+	if err := r.Get(ctx, types.NamespacedName{Name: gw.Name, Namespace: gw.Namespace}, dep); err != nil {
+		t.Fatal(err)
+	}
+	mounted := false
+	for _, c := range dep.Spec.Template.Spec.Containers {
+		for _, m := range c.VolumeMounts {
+			mounted = mounted || (c.Name == "gateway" && m.Name == name && strings.HasPrefix(m.MountPath, "/opt/SecureSpan/Gateway/node/default/etc/bootstrap/bundle/"))
+		}
+	}
+	for _, v := range dep.Spec.Template.Spec.Volumes {
+		if v.Name != name || !mounted {
+			continue
+		}
+		if (kind == "secret" && v.Secret != nil && v.Secret.SecretName == name) || (kind == "configmap" && v.ConfigMap != nil && v.ConfigMap.Name == name) {
+			return dep.Spec.Template.Annotations[checksumAnnotationKey(kind, name)]
+		}
+	}
+	t.Fatalf("expected the gateway container to mount %s %s, got %v", kind, name, dep.Spec.Template.Spec.Volumes)
+	return ""
+}
+
+func TestReconcileSecurePasswords(t *testing.T) {
+	gw := &securityv1.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "edge", Namespace: "payments"}}
+	gw.Spec.App.SecurePasswords = []securityv1.SecurePassword{
+		{Name: "ldap-bind", SecretName: "edge-credentials", Key: "ldap", Description: "directory bind user"},
+		{Name: "backend-api", SecretName: "edge-credentials", Key: "api-key"},
+	}
+	credentials := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "edge-credentials", Namespace: gw.Namespace},
+		Data:       map[string][]byte{"ldap": []byte("b1nd"), "api-key": []byte("k3y-1")},
+	}
+	r := newTestReconciler(t, gw, credentials)
+	ctx := context.Background()
+
+	bundle := func() *util.Bundle {
+		if err := reconcileSecurePasswords(r, ctx, gw); err != nil {
+			t.Fatal(err)
+		}
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Name: "edge-secure-password-bundle", Namespace: gw.Namespace}, secret); err != nil {
+			t.Fatal(err)
+		}
+		b, err := util.ParseBundle(secret.Data["secure-passwords.bundle"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	b := bundle()
+	items := b.References.Item
+	if len(items) != 2 || items[0].Name != "backend-api" || items[0].Resource.StoredPassword.Password.Value != "k3y-1" || items[1].Name != "ldap-bind" {
+		t.Fatalf("unexpected secure passwords %+v", items)
+	}
+	checksum := mountedBundle(t, r, gw, "secret", "edge-secure-password-bundle")
+	if checksum == "" {
+		t.Fatal("expected the pod template to record a checksum of the bundle")
+	}
+
+	// a rotated password rolls the pods
+	credentials.Data["api-key"] = []byte("k3y-2")
+	if err := r.Update(ctx, credentials); err != nil {
+		t.Fatal(err)
+	}
+	if b := bundle(); b.References.Item[0].Resource.StoredPassword.Password.Value != "k3y-2" {
+		t.Fatalf("expected the rotated password, got %+v", b.References.Item[0].Resource.StoredPassword)
+	}
+	if mountedBundle(t, r, gw, "secret", "edge-secure-password-bundle") == checksum {
+		t.Fatal("expected the rotated password to change the pod template")
+	}
+
+	gw.Spec.App.SecurePasswords = append(gw.Spec.App.SecurePasswords, securityv1.SecurePassword{Name: "smtp", SecretName: "edge-credentials", Key: "smtp"})
+	if err := reconcileSecurePasswords(r, ctx, gw); err == nil || !strings.Contains(err.Error(), "no key smtp") {
+		t.Fatalf("expected a missing key to be reported, got %v", err)
+	}
+}
+
+// selfSignedCert returns a PEM certificate for cn that expires at notAfter and its PKCS #8 private key
+func selfSignedCert(t *testing.T, cn string, notAfter time.Time) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestReconcilePrivateKeys(t *testing.T) {
+	gw := &securityv1.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "api-gw", Namespace: "edge"}}
+	gw.Spec.App.PrivateKeys = []securityv1.PrivateKey{{Alias: "ssl", SecretName: "api-gw-tls", DefaultSSLKey: true}}
+	// issue returns the data of a kubernetes.io/tls Secret like cert-manager writes it
+	issue := func(cn string) map[string][]byte {
+		cert, key := selfSignedCert(t, cn, time.Now().Add(24*time.Hour))
+		return map[string][]byte{corev1.TLSCertKey: cert, corev1.TLSPrivateKeyKey: key}
+	}
+	tls := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "api-gw-tls", Namespace: gw.Namespace},
+		Type:       corev1.SecretTypeTLS,
+		Data:       issue("api.example.com"),
+	}
+	r := newTestReconciler(t, gw, tls)
+	ctx := context.Background()
+
+	keys := func() []graphman.Key {
+		if err := reconcilePrivateKeys(r, ctx, gw); err != nil {
+			t.Fatal(err)
+		}
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Name: "api-gw-private-key-bundle", Namespace: gw.Namespace}, secret); err != nil {
+			t.Fatal(err)
+		}
+		bundle := map[string][]graphman.Key{}
+		if err := json.Unmarshal(secret.Data["private-keys.json"], &bundle); err != nil {
+			t.Fatal(err)
+		}
+		return bundle["keys"]
+	}
+
+	k := keys()
+	if len(k) != 1 || k[0].Alias != "ssl" || k[0].SubjectDn != "CN=api.example.com" || len(k[0].UsageTypes) != 1 || k[0].UsageTypes[0] != "SSL" {
+		t.Fatalf("unexpected private keys %+v", k)
+	}
+	checksum := mountedBundle(t, r, gw, "secret", "api-gw-private-key-bundle")
+
+	// a renewed certificate rolls the pods
+	tls.Data = issue("api.example.com")
+	if err := r.Update(ctx, tls); err != nil {
+		t.Fatal(err)
+	}
+	if k := keys(); len(k) != 1 || k[0].SubjectDn != "CN=api.example.com" {
+		t.Fatalf("unexpected private keys %+v", k)
+	}
+	if mountedBundle(t, r, gw, "secret", "api-gw-private-key-bundle") == checksum {
+		t.Fatal("expected the renewed certificate to change the pod template")
+	}
+
+	gw.Spec.App.PrivateKeys = append(gw.Spec.App.PrivateKeys, securityv1.PrivateKey{Alias: "client", SecretName: "api-gw-client-tls"})
+	if err := reconcilePrivateKeys(r, ctx, gw); err == nil {
+		t.Fatal("expected an error for a missing TLS Secret")
+	}
+}
+
+func TestReconcileTrustedCerts(t *testing.T) {
+	gw := &securityv1.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "partner-gw", Namespace: "b2b"}}
+	gw.Spec.App.TrustedCerts = []securityv1.TrustedCert{
+		{Name: "partner-ca", ConfigMapName: "partner-ca", Key: "ca.crt", TrustAnchor: true},
+		{Name: "bank", SecretName: "bank-cert", Key: corev1.TLSCertKey, VerifyHostname: true},
+	}
+	partnerCA, _ := selfSignedCert(t, "Partner Root CA", time.Now().AddDate(5, 0, 0))
+	bankCert, _ := selfSignedCert(t, "api.bank.example.com", time.Now().AddDate(0, 0, 7))
+	ca := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "partner-ca", Namespace: gw.Namespace},
+		Data:       map[string]string{"ca.crt": string(partnerCA)},
+	}
+	bank := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "bank-cert", Namespace: gw.Namespace},
+		Data:       map[string][]byte{corev1.TLSCertKey: bankCert},
+	}
+	r := newTestReconciler(t, gw, ca, bank)
+	ctx := context.Background()
+
+	if err := reconcileTrustedCerts(r, ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	cm := &corev1.ConfigMap{}
+	if err := r.Get(ctx, types.NamespacedName{Name: "partner-gw-trusted-cert-bundle", Namespace: gw.Namespace}, cm); err != nil {
+		t.Fatal(err)
+	}
+	b, err := util.ParseBundle([]byte(cm.Data["trusted-certs.bundle"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items := b.References.Item; len(items) != 2 || items[0].Name != "bank" || items[1].Name != "partner-ca" || items[0].Type != "TRUSTED_CERT" {
+		t.Fatalf("unexpected trusted certificates %+v", items)
+	}
+	statuses := gw.Status.TrustedCerts
+	if len(statuses) != 2 || statuses[0].Name != "partner-ca" || statuses[0].Expiring || !statuses[1].Expiring || statuses[1].Subject != "CN=api.bank.example.com" {
+		t.Fatalf("unexpected trusted certificate status %+v", statuses)
+	}
+	if conditionStatus(gw, trustedCertsExpiringCondition) != corev1.ConditionTrue {
+		t.Fatalf("expected the %s condition, got %v", trustedCertsExpiringCondition, gw.Status.Conditions)
+	}
+	checksum := mountedBundle(t, r, gw, "configmap", "partner-gw-trusted-cert-bundle")
+
+	// a renewed certificate clears the condition and rolls the pods
+	bank.Data[corev1.TLSCertKey], _ = selfSignedCert(t, "api.bank.example.com", time.Now().AddDate(1, 0, 0))
+	if err := r.Update(ctx, bank); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileTrustedCerts(r, ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	if gw.Status.TrustedCerts[1].Expiring || conditionStatus(gw, trustedCertsExpiringCondition) != corev1.ConditionFalse {
+		t.Fatalf("expected the renewed certificate not to be expiring, got %+v", gw.Status.TrustedCerts[1])
+	}
+	if mountedBundle(t, r, gw, "configmap", "partner-gw-trusted-cert-bundle") == checksum {
+		t.Fatal("expected the renewed certificate to change the pod template")
+	}
+
+	ca.Data["ca.crt"] = "not a certificate"
+	if err := r.Update(ctx, ca); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileTrustedCerts(r, ctx, gw); err == nil || !strings.Contains(err.Error(), "partner-ca") {
+		t.Fatalf("expected an invalid certificate to be reported, got %v", err)
+	}
+}
+
+func TestReconcileJDBCConnections(t *testing.T) {
+	s := restmantest.NewServer("admin", "7layer")
+	defer s.Close()
+	s.JDBCConnections = []restman.JDBCConnection{{ID: "1", Name: "orders", Enabled: true}}
+	gw, objs := newTestGateway(t, s.URL, s.CACert())
+	gw.Spec.App.Management.Restman.Enabled = true
+	gw.Spec.App.JDBCConnections = []securityv1.JDBCConnection{
+		{Name: "orders", DriverClass: "com.mysql.cj.jdbc.Driver", JdbcURL: "jdbc:mysql://mysql:3306/orders", SecretName: "orders-db", UsernameKey: "db-user", PasswordKey: "db-password"},
+		{Name: "audit", DriverClass: "org.postgresql.Driver", JdbcURL: "jdbc:postgresql://postgres:5432/audit", SecretName: "audit-db"},
+	}
+	orders := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders-db", Namespace: gw.Namespace},
+		Data:       map[string][]byte{"db-user": []byte("gateway"), "db-password": []byte("0rders")},
+	}
+	audit := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "audit-db", Namespace: gw.Namespace},
+		Data:       map[string][]byte{"username": []byte("auditor"), "password": []byte("aud1t")},
+	}
+	r := newTestReconciler(t, gw, append(objs, orders, audit)...)
+	ctx := context.Background()
+
+	reconcile := func() {
+		if err := reconcileSecurePasswords(r, ctx, gw); err != nil {
+			t.Fatal(err)
+		}
+		if err := reconcileJDBCConnections(r, ctx, gw); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bundle := func(name string, key string) *util.Bundle {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: gw.Namespace}, secret); err != nil {
+			t.Fatal(err)
+		}
+		b, err := util.ParseBundle(secret.Data[key])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	reconcile()
+	connections := bundle("ssg-jdbc-bundle", "jdbc-connections.bundle").References.Item
+	if len(connections) != 2 || connections[1].Name != "orders" || connections[1].Resource.JDBCConnection.Extension.JdbcUrl != "jdbc:mysql://mysql:3306/orders" {
+		t.Fatalf("unexpected JDBC connections %+v", connections)
+	}
+	properties := connections[1].Resource.JDBCConnection.Extension.ConnectionProperties.Property
+	if properties[0].StringValue != "${secpass.jdbc-orders.plaintext}" || properties[1].StringValue != "gateway" {
+		t.Fatalf("expected the password to refer to its secure password, got %+v", properties)
+	}
+	passwords := bundle("ssg-secure-password-bundle", "secure-passwords.bundle").References.Item
+	if len(passwords) != 2 || passwords[1].Name != "jdbc-orders" || passwords[1].Resource.StoredPassword.Password.Value != "0rders" {
+		t.Fatalf("expected the connection passwords to be secure passwords, got %+v", passwords)
+	}
+	connectionsChecksum := mountedBundle(t, r, gw, "secret", "ssg-jdbc-bundle")
+	passwordsChecksum := mountedBundle(t, r, gw, "secret", "ssg-secure-password-bundle")
+
+	// a rotated password only changes the secure password bundle
+	orders.Data["db-password"] = []byte("0rders-2")
+	if err := r.Update(ctx, orders); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+	if mountedBundle(t, r, gw, "secret", "ssg-jdbc-bundle") != connectionsChecksum || mountedBundle(t, r, gw, "secret", "ssg-secure-password-bundle") == passwordsChecksum {
+		t.Fatal("expected the rotated password to change the secure password bundle only")
+	}
+
+	if err := updateJDBCConnectionStatus(r, ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	statuses := gw.Status.JDBCConnections
+	if len(statuses) != 2 || statuses[0].State != "created" || statuses[1].State != "missing" || statuses[1].Pod != "ssg-0" {
+		t.Fatalf("unexpected JDBC connection status %+v", statuses)
+	}
+}
+
+func TestReconcileClusterPropertiesValueFrom(t *testing.T) {
+	optional := true
+	gw := &securityv1.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "ssg-dev", Namespace: "team-a"}}
+	gw.Spec.App.ClusterProperties = securityv1.ClusterProperties{Enabled: true, Properties: []securityv1.ClusterProperty{
+		{Name: "io.httpsHostAllowWildcard", Value: "true"},
+		{Name: "log.level", ValueFrom: &securityv1.ClusterPropertySource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "team-a-settings"}, Key: "log-level"}}},
+		{Name: "partner.apiKey", ValueFrom: &securityv1.ClusterPropertySource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "team-a-partner"}, Key: "api-key"}}},
+		{Name: "partner.sandboxKey", ValueFrom: &securityv1.ClusterPropertySource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "team-a-sandbox"}, Key: "api-key", Optional: &optional}}},
+	}}
+	settings := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-a-settings", Namespace: gw.Namespace},
+		Data:       map[string]string{"log-level": "INFO"},
+	}
+	partner := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-a-partner", Namespace: gw.Namespace},
+		Data:       map[string][]byte{"api-key": []byte("pk-123")},
+	}
+	r := newTestReconciler(t, gw, settings, partner)
+	ctx := context.Background()
+
+	// values read from a Secret keep the bundle in a Secret
+	props := func() map[string]string {
+		if err := reconcileClusterProperties(r, ctx, gw); err != nil {
+			t.Fatal(err)
+		}
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Name: "ssg-dev-cwp-bundle", Namespace: gw.Namespace}, secret); err != nil {
+			t.Fatal(err)
+		}
+		b, err := util.ParseBundle(secret.Data["cwp.bundle"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		props := map[string]string{}
+		for _, item := range b.References.Item {
+			props[item.Resource.ClusterProperty.Name] = item.Resource.ClusterProperty.Value
+		}
+		return props
+	}
+
+	expected := map[string]string{"io.httpsHostAllowWildcard": "true", "log.level": "INFO", "partner.apiKey": "pk-123"}
+	if p := props(); !reflect.DeepEqual(p, expected) {
+		t.Fatalf("expected %v, got %v", expected, p)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: "ssg-dev-cwp-bundle", Namespace: gw.Namespace}, &corev1.ConfigMap{}); err == nil {
+		t.Fatal("expected no cluster property ConfigMap")
+	}
+	checksum := mountedBundle(t, r, gw, "secret", "ssg-dev-cwp-bundle")
+
+	// a changed ConfigMap value rolls the pods
+	settings.Data["log-level"] = "FINE"
+	if err := r.Update(ctx, settings); err != nil {
+		t.Fatal(err)
+	}
+	if p := props(); p["log.level"] != "FINE" {
+		t.Fatalf("expected the new ConfigMap value, got %v", p)
+	}
+	if mountedBundle(t, r, gw, "secret", "ssg-dev-cwp-bundle") == checksum {
+		t.Fatal("expected the new value to change the pod template")
+	}
+
+	// the optional Secret is picked up once it exists
+	sandbox := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-a-sandbox", Namespace: gw.Namespace},
+		Data:       map[string][]byte{"api-key": []byte("sk-456")},
+	}
+	if err := r.Create(ctx, sandbox); err != nil {
+		t.Fatal(err)
+	}
+	if p := props(); p["partner.sandboxKey"] != "sk-456" {
+		t.Fatalf("expected the optional value, got %v", p)
+	}
+
+	delete(partner.Data, "api-key")
+	if err := r.Update(ctx, partner); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileClusterProperties(r, ctx, gw); err == nil || !strings.Contains(err.Error(), "partner.apiKey") {
+		t.Fatalf("expected a missing required value to be reported, got %v", err)
+	}
+}
+
+func TestReconcileResolvedBundlesFromSecrets(t *testing.T) {
+	gw := &securityv1.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "orders-gw", Namespace: "shop"}}
+	gw.Spec.App.Bundle = []securityv1.Bundle{{Type: "configMap", Name: "orders-api", ConfigMap: securityv1.ConfigMap{Name: "orders-api"}}}
+	gw.Spec.App.Templating = securityv1.Templating{Enabled: true, Values: []securityv1.TemplateValues{
+		{ConfigMapName: "orders-settings"},
+		{SecretName: "orders-credentials"},
+	}}
+	bundle, _ := util.BuildCWPBundle(map[string]string{"orders.backend": "${env.backend}", "orders.apiKey": "${env.apiKey}"})
+	bundles := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders-api", Namespace: gw.Namespace},
+		Data:       map[string]string{"orders.bundle": string(bundle)},
+	}
+	settings := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders-settings", Namespace: gw.Namespace},
+		Data:       map[string]string{"backend": "https://orders.shop.svc:8443", "apiKey": "overridden"},
+	}
+	credentials := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders-credentials", Namespace: gw.Namespace},
+		Data:       map[string][]byte{"apiKey": []byte("k&y-1")},
+	}
+	r := newTestReconciler(t, gw, bundles, settings, credentials)
+	ctx := context.Background()
+
+	resolved := func() (map[string]string, string) {
+		if err := reconcileResolvedBundles(r, ctx, gw); err != nil {
+			t.Fatal(err)
+		}
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Name: "orders-gw-orders-api-resolved", Namespace: gw.Namespace}, secret); err != nil {
+			t.Fatal(err)
+		}
+		b, err := util.ParseBundle(secret.Data["orders.bundle"])
+		if err != nil {
+			t.Fatalf("expected the resolved bundle to stay valid: %v", err)
+		}
+		props := map[string]string{}
+		for _, item := range b.References.Item {
+			props[item.Resource.ClusterProperty.Name] = item.Resource.ClusterProperty.Value
+		}
+
+		if err := reconcileDeployment(r, ctx, gw); err != nil {
+			t.Fatal(err)
+		}
+		dep := &appsv1.Deployment{}
+		if err := r.Get(ctx, types.NamespacedName{Name: gw.Name, Namespace: gw.Namespace}, dep); err != nil {
+			t.Fatal(err)
+		}
+		mounted := false
+		for _, v := range dep.Spec.Template.Spec.Volumes {
+			mounted = mounted || (v.Name == "orders-api" && v.Secret != nil && v.Secret.SecretName == secret.Name)
+		}
+		if !mounted {
+			t.Fatalf("expected the resolved Secret to be mounted, got %v", dep.Spec.Template.Spec.Volumes)
+		}
+		if _, ok := dep.Spec.Template.Annotations[checksumAnnotationKey("configmap", "orders-api")]; ok {
+			t.Fatal("expected the pods not to mount the bundle ConfigMap with its placeholders")
+		}
+		return props, dep.Spec.Template.Annotations[checksumAnnotationKey("secret", secret.Name)]
+	}
+
+	// later values override earlier ones and are escaped for the bundle
+	props, checksum := resolved()
+	if props["orders.backend"] != "https://orders.shop.svc:8443" || props["orders.apiKey"] != "k&y-1" || checksum == "" {
+		t.Fatalf("unexpected resolved properties %v", props)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: "orders-gw-orders-api-resolved", Namespace: gw.Namespace}, &corev1.ConfigMap{}); err == nil {
+		t.Fatal("expected values read from a Secret not to be written to a ConfigMap")
+	}
+
+	// a rotated Secret value rolls the pods
+	credentials.Data["apiKey"] = []byte("k&y-2")
+	if err := r.Update(ctx, credentials); err != nil {
+		t.Fatal(err)
+	}
+	props, rotated := resolved()
+	if props["orders.apiKey"] != "k&y-2" || rotated == checksum {
+		t.Fatalf("expected the rotated value to change the pod template, got %v", props)
+	}
+}
@@ -0,0 +1,250 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/graphman"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// dryRunAnnotation holds back repository commits like spec.app.repository.dryRun
+	dryRunAnnotation = "security.brcmlabs.com/dry-run"
+	// approveCommitAnnotation releases the held back commits it names, a comma separated list of repository
+	// or Repository commit ids and Graphman ConfigMap checksums
+	approveCommitAnnotation = "security.brcmlabs.com/approve-commit"
+)
+
+// maxPendingChanges limits the entities listed in status.pendingChanges
+const maxPendingChanges = 200
+
+// pendingChangesInterval is how often the pending changes are recomputed, pods can drift on the same commit
+const pendingChangesInterval = time.Minute
+
+// heldBackCondition reports bundle sources whose changes are waiting for approval in dry run mode
+const heldBackCondition = "BundlesHeldBack"
+
+// dryRun returns true if commits that change bundles wait for approval before being applied
+func dryRun(gw *securityv1.Gateway) bool {
+	return gw.Spec.App.Repository.DryRun || gw.Annotations[dryRunAnnotation] == "true"
+}
+
+// commitApproved returns true if the approve annotation of the Gateway lists the commit id or checksum
+func commitApproved(gw *securityv1.Gateway, id string) bool {
+	for _, approved := range strings.Split(gw.Annotations[approveCommitAnnotation], ",") {
+		if id != "" && strings.TrimSpace(approved) == id {
+			return true
+		}
+	}
+	return false
+}
+
+// dryRunHeldBack returns the bundle sources with changes waiting for approval, along with the commit id or
+// checksum that approves them
+func dryRunHeldBack(gw *securityv1.Gateway) []string {
+	sources := []string{}
+	if p := gw.Status.PendingChanges; p != nil {
+		sources = append(sources, "repository ("+p.CommitID+")")
+	}
+	for _, s := range gw.Status.Repositories {
+		if p := s.PendingChanges; p != nil {
+			sources = append(sources, "repositories/"+s.Name+" ("+p.CommitID+")")
+		}
+	}
+	if p := gw.Status.PendingGraphmanChanges; p != nil {
+		sources = append(sources, "graphman configMaps ("+p.CommitID+")")
+	}
+	return sources
+}
+
+// updateHeldBackCondition reports the bundle sources waiting for approval in the BundlesHeldBack condition
+func updateHeldBackCondition(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) error {
+	changed := false
+	if heldBack := dryRunHeldBack(gw); len(heldBack) > 0 {
+		changed = setGatewayCondition(gw, heldBackCondition, corev1.ConditionTrue, "WaitingForApproval", strings.Join(heldBack, ", ")+" wait for approval, see status.pendingChanges")
+	} else if hasGatewayCondition(gw, heldBackCondition) {
+		changed = setGatewayCondition(gw, heldBackCondition, corev1.ConditionFalse, "Applied", "no bundles are held back")
+	}
+	if !changed {
+		return nil
+	}
+	if err := r.Client.Status().Update(ctx, gw); err != nil {
+		r.Log.Error(err, "Failed to update dry run status", "Name", gw.Name, "Namespace", gw.Namespace)
+		return err
+	}
+	return nil
+}
+
+// diffBundles computes the changes held back bundles, identified by a commit id or checksum, would make to a
+// ready Gateway pod, preferring the management pod. Failures are reported in the diff so they show up in
+// the status.
+func diffBundles(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway, id string, entityChanges func(api *managementAPI, pod *corev1.Pod) ([]securityv1.EntityChange, error)) *securityv1.BundleDiff {
+	diff := &securityv1.BundleDiff{CommitID: id, ComputedAt: metav1.Now()}
+	changes, err := func() ([]securityv1.EntityChange, error) {
+		pod, err := readyPod(r, ctx, gw)
+		if err != nil {
+			return nil, err
+		}
+		diff.Pod = pod.Name
+		api, err := getManagementAPI(r, ctx, gw)
+		if err != nil {
+			return nil, err
+		}
+		return entityChanges(api, pod)
+	}()
+	if err != nil {
+		r.Log.Error(err, "Failed to compute pending changes", "Name", gw.Name, "Namespace", gw.Namespace, "Commit", diff.CommitID)
+		diff.Error = err.Error()
+		return diff
+	}
+
+	for _, c := range changes {
+		switch c.Action {
+		case "create":
+			diff.Created++
+		case "update":
+			diff.Updated++
+		case "delete":
+			diff.Deleted++
+		}
+		if len(diff.Changes) < maxPendingChanges {
+			diff.Changes = append(diff.Changes, c)
+		}
+	}
+	return diff
+}
+
+// bundleChanges returns the changes applying the bundles in dir that changed between since and commit would
+// make to pod. method is graphman for Graphman bundles or restman for Restman bundles. Bundles are compared
+// from the commit the pods run, every bundle if they haven't applied one yet.
+func bundleChanges(ctx context.Context, api *managementAPI, pod *corev1.Pod, method string, repo *git.Repository, commit *object.Commit, dir string, since string, t *bundleTemplate) ([]securityv1.EntityChange, error) {
+	if method == "graphman" {
+		repoChanges, err := getGraphmanRepositoryChanges(repo, commit, dir, since, t)
+		if err != nil {
+			return nil, err
+		}
+		return graphmanBundleChanges(ctx, api, pod, repoChanges)
+	}
+
+	bundles, err := util.GetBundles(repo, commit, dir, since)
+	if err != nil {
+		return nil, err
+	}
+	bundles = t.resolveBundles(bundles)
+	names := make([]string, 0, len(bundles))
+	for name := range bundles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c, err := api.restman(pod.Status.PodIP)
+	if err != nil {
+		return nil, err
+	}
+	changes := []securityv1.EntityChange{}
+	for _, name := range names {
+		b, err := util.ParseBundle(bundles[name])
+		if err != nil {
+			return nil, err
+		}
+		itemNames := map[string]string{}
+		for _, item := range b.Entities() {
+			itemNames[util.ItemKey(item)] = item.Name
+		}
+		mappings, err := c.TestBundle(ctx, bundles[name])
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range mappings {
+			change := securityv1.EntityChange{Type: m.Type, Name: itemNames[m.Type+"/"+m.SrcID]}
+			if change.Name == "" {
+				change.Name = m.SrcID
+			}
+			switch m.ActionTaken {
+			case "CreatedNew":
+				change.Action = "create"
+			case "UpdatedExisting":
+				change.Action = "update"
+			case "Deleted":
+				change.Action = "delete"
+			default:
+				continue
+			}
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// graphmanBundleChanges returns the changes installing and deleting the Graphman bundles of changes would
+// make to pod
+func graphmanBundleChanges(ctx context.Context, api *managementAPI, pod *corev1.Pod, changes graphmanChanges) ([]securityv1.EntityChange, error) {
+	c, err := api.graphman(pod.Status.PodIP)
+	if err != nil {
+		return nil, err
+	}
+	current, err := c.Export(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entityChanges := []securityv1.EntityChange{}
+	for _, change := range graphman.Diff(current, changes.install, changes.delete) {
+		entityChanges = append(entityChanges, securityv1.EntityChange{Action: change.Action, Type: change.Type, Name: change.Key})
+	}
+	return entityChanges, nil
+}
+
+// pendingChangesStale returns true if the pending changes of a held back commit id or checksum need to be
+// computed again
+func pendingChangesStale(p *securityv1.BundleDiff, id string) bool {
+	return p == nil || p.CommitID != id || p.Error != "" || time.Since(p.ComputedAt.Time) > pendingChangesInterval
+}
+
+// readyPod returns the management pod if it's ready, otherwise the first ready Gateway pod
+func readyPod(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) (*corev1.Pod, error) {
+	name := ""
+	for _, state := range gw.Status.Gateway {
+		if state.Ready && (name == "" || state.Name == gw.Status.ManagementPod) {
+			name = state.Name
+		}
+	}
+	if name == "" {
+		return nil, errors.New("no ready Gateway pod to compare bundles with")
+	}
+	pod := &corev1.Pod{}
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: gw.Namespace}, pod); err != nil {
+		return nil, err
+	}
+	if pod.Status.PodIP == "" {
+		return nil, errors.New("pod " + name + " has no IP")
+	}
+	return pod, nil
+}
+
+// diffRepositoryReference computes the changes the held back latest commit of a referenced Repository would
+// make, compared from the commit applied meanwhile. Other Repositories defining the same entities aren't
+// taken into account.
+func diffRepositoryReference(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway, ref securityv1.RepositoryReference, repo *securityv1.Repository, since string, t *bundleTemplate) *securityv1.BundleDiff {
+	commitId := repo.Status.CommitID
+	method := "restman"
+	if ref.Method == "" || ref.Method == "graphman" {
+		method = "graphman"
+	}
+	return diffBundles(r, ctx, gw, commitId, func(api *managementAPI, pod *corev1.Pod) ([]securityv1.EntityChange, error) {
+		gitRepo, commit, err := checkoutRepository(r, ctx, repo, commitId)
+		if err != nil {
+			return nil, err
+		}
+		return bundleChanges(ctx, api, pod, method, gitRepo, commit, ref.Directory, since, t)
+	})
+}
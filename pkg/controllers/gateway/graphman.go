@@ -0,0 +1,342 @@
+package gateway
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/config"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/graphman"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// graphmanChanges is an ordered set of Graphman bundles to install and delete on a Gateway pod
+type graphmanChanges struct {
+	install []graphman.Bundle
+	delete  []graphman.Bundle
+}
+
+// reconcileGraphman keeps every ready Gateway pod in sync with the Graphman bundles in the repository
+// (when using the graphman method) and the Graphman ConfigMaps listed in the Gateway spec. In dry run mode
+// changed ConfigMap bundles are held back until their checksum is approved, the changes they would make
+// are reported in status.pendingGraphmanChanges.
+func reconcileGraphman(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) error {
+	// repository bundles are synced once reconcileBundles has resolved a bundle commit
+	repositorySync := gw.Spec.App.Repository.Enabled && gw.Spec.App.Repository.Method == "graphman" && gw.Status.BundleCommitID != ""
+
+	t, err := getBundleTemplate(r, ctx, gw)
+	if err != nil {
+		return err
+	}
+
+	configMapChanges, checksum, err := getGraphmanConfigMapBundles(r, ctx, gw, t)
+	if err != nil {
+		return err
+	}
+	// entities of keys or ConfigMaps that have been removed since the pods last synced are deleted
+	applied, err := getAppliedGraphmanEntities(r, ctx, gw)
+	if err != nil {
+		return err
+	}
+	if removed := graphman.Removed(applied, graphman.Keys(configMapChanges.install...)); len(removed) > 0 {
+		configMapChanges.delete = append(configMapChanges.delete, removed)
+	}
+
+	configMapSync := checksum == gw.Status.GraphmanChecksum || !dryRun(gw) || commitApproved(gw, checksum)
+	statusChanged := false
+	if configMapSync && (checksum != gw.Status.GraphmanChecksum || gw.Status.PendingGraphmanChanges != nil) {
+		gw.Status.GraphmanChecksum = checksum
+		gw.Status.PendingGraphmanChanges = nil
+		statusChanged = true
+	}
+	if !configMapSync && pendingChangesStale(gw.Status.PendingGraphmanChanges, checksum) {
+		gw.Status.PendingGraphmanChanges = diffBundles(r, ctx, gw, checksum, func(api *managementAPI, pod *corev1.Pod) ([]securityv1.EntityChange, error) {
+			return graphmanBundleChanges(ctx, api, pod, configMapChanges)
+		})
+		statusChanged = true
+		r.Log.Info("Graphman ConfigMap bundles are waiting for approval", "Name", gw.Name, "Namespace", gw.Namespace, "Checksum", checksum,
+			"Created", gw.Status.PendingGraphmanChanges.Created, "Updated", gw.Status.PendingGraphmanChanges.Updated, "Deleted", gw.Status.PendingGraphmanChanges.Deleted)
+	}
+
+	behind := false
+	for _, state := range gw.Status.Gateway {
+		if !state.Ready {
+			continue
+		}
+		if repositorySync && state.CommitID != gw.Status.BundleCommitID {
+			behind = true
+		}
+		if configMapSync && state.BundleChecksum != checksum {
+			behind = true
+		}
+	}
+	if !behind {
+		if statusChanged {
+			if err := r.Client.Status().Update(ctx, gw); err != nil {
+				r.Log.Error(err, "Failed to update graphman status", "Namespace", gw.Namespace, "Name", gw.Name)
+				return err
+			}
+		}
+		if configMapSync {
+			return reconcileAppliedGraphmanEntities(r, ctx, gw, configMapChanges)
+		}
+		return nil
+	}
+
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{
+		client.InNamespace(gw.Namespace),
+		client.MatchingLabels(util.DefaultLabels(gw)),
+	}
+	if err := r.List(ctx, podList, listOpts...); err != nil {
+		r.Log.Error(err, "Failed to list pods", "Namespace", gw.Namespace, "Name", gw.Name)
+		return err
+	}
+
+	api, err := getManagementAPI(r, ctx, gw)
+	if err != nil {
+		return err
+	}
+
+	var repo *git.Repository
+	var commit *object.Commit
+	if repositorySync {
+		creds, err := getRepositoryCredentials(r, ctx, gw)
+		if err != nil {
+			return err
+		}
+		repo, commit, err = r.RepositoryCache.Checkout(gw.Spec.App.Repository.URL, creds, gw.Status.BundleCommitID)
+		if err != nil {
+			return err
+		}
+	}
+
+	repositoryChanges := map[string]graphmanChanges{}
+	for i, state := range gw.Status.Gateway {
+		if !state.Ready {
+			continue
+		}
+
+		podIP := ""
+		for _, pod := range podList.Items {
+			if pod.Name == state.Name {
+				podIP = pod.Status.PodIP
+			}
+		}
+		if podIP == "" {
+			continue
+		}
+
+		changes := graphmanChanges{}
+		commitBehind := repositorySync && state.CommitID != gw.Status.BundleCommitID
+		if commitBehind {
+			repoChanges, ok := repositoryChanges[state.CommitID]
+			if !ok {
+				repoChanges, err = getGraphmanRepositoryChanges(repo, commit, gw.Spec.App.Repository.BundleDirectory, state.CommitID, t)
+				if err != nil {
+					return err
+				}
+				repositoryChanges[state.CommitID] = repoChanges
+			}
+			changes.install = append(changes.install, repoChanges.install...)
+			changes.delete = append(changes.delete, repoChanges.delete...)
+		}
+
+		checksumBehind := configMapSync && state.BundleChecksum != checksum
+		if checksumBehind {
+			changes.install = append(changes.install, configMapChanges.install...)
+			changes.delete = append(changes.delete, configMapChanges.delete...)
+		}
+
+		if !commitBehind && !checksumBehind {
+			continue
+		}
+
+		r.Log.Info("Applying graphman bundles", "Name", gw.Name, "Namespace", gw.Namespace, "Pod", state.Name, "Install", len(changes.install), "Delete", len(changes.delete))
+		result, err := applyGraphmanChanges(ctx, api, podIP, changes)
+		gw.Status.Gateway[i].EntitiesApplied = int32(result.Applied)
+		gw.Status.Gateway[i].EntitiesFailed = int32(result.Failed)
+		if err == nil {
+			err = result.Err()
+		}
+		if err != nil {
+			r.Log.Error(err, "Failed to apply graphman bundles", "Name", gw.Name, "Namespace", gw.Namespace, "Pod", state.Name)
+			gw.Status.Gateway[i].SyncStatus = "failed"
+			gw.Status.Gateway[i].SyncError = err.Error()
+			continue
+		}
+
+		if repositorySync {
+			gw.Status.Gateway[i].CommitID = gw.Status.BundleCommitID
+		}
+		if configMapSync {
+			gw.Status.Gateway[i].BundleChecksum = checksum
+		}
+		gw.Status.Gateway[i].SyncStatus = "applied"
+		gw.Status.Gateway[i].SyncError = ""
+	}
+
+	if err := r.Client.Status().Update(ctx, gw); err != nil {
+		r.Log.Error(err, "Failed to update graphman status", "Namespace", gw.Namespace, "Name", gw.Name)
+		return err
+	}
+
+	if configMapSync {
+		for _, state := range gw.Status.Gateway {
+			if state.Ready && state.BundleChecksum != checksum {
+				return nil
+			}
+		}
+		return reconcileAppliedGraphmanEntities(r, ctx, gw, configMapChanges)
+	}
+	return nil
+}
+
+// graphmanEntitiesKey is the key of the entities installed from Graphman ConfigMaps in the applied entities ConfigMap
+const graphmanEntitiesKey = "entities.json"
+
+// getAppliedGraphmanEntities returns the keys of the entities installed from Graphman ConfigMaps when the ready
+// pods were last in sync
+func getAppliedGraphmanEntities(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) (graphman.Bundle, error) {
+	cm := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Name: gw.Name + "-graphman-applied", Namespace: gw.Namespace}, cm)
+	if k8serrors.IsNotFound(err) || (err == nil && cm.Data[graphmanEntitiesKey] == "") {
+		return graphman.Bundle{}, nil
+	}
+	if err != nil {
+		r.Log.Error(err, "Failed to retrieve applied graphman entities", "Name", gw.Name, "Namespace", gw.Namespace)
+		return nil, err
+	}
+	return graphman.ParseBundle([]byte(cm.Data[graphmanEntitiesKey]))
+}
+
+// reconcileAppliedGraphmanEntities records the keys of the entities installed from Graphman ConfigMaps once
+// every ready pod has them, so the entities of removed keys can be deleted later
+func reconcileAppliedGraphmanEntities(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway, changes graphmanChanges) error {
+	data, err := json.Marshal(graphman.Keys(changes.install...))
+	if err != nil {
+		return err
+	}
+	return reconcileBundleConfigMap(r, ctx, gw, config.NewBundleConfigMap(gw, gw.Name+"-graphman-applied", graphmanEntitiesKey, data))
+}
+
+// applyGraphmanChanges installs bundles before deleting entities so replaced references are in place first
+func applyGraphmanChanges(ctx context.Context, api *managementAPI, podIP string, changes graphmanChanges) (graphman.Result, error) {
+	result := graphman.Result{}
+	c, err := api.graphman(podIP)
+	if err != nil {
+		return result, err
+	}
+	for _, bundle := range changes.install {
+		res, err := c.Install(ctx, bundle)
+		result.Add(res)
+		if err != nil {
+			return result, err
+		}
+	}
+	for _, bundle := range changes.delete {
+		res, err := c.Delete(ctx, bundle)
+		result.Add(res)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// getGraphmanRepositoryChanges returns the Graphman bundles to install and the entities to delete to bring
+// a Gateway pod from the since commit up to commit, with their placeholders resolved by t.
+func getGraphmanRepositoryChanges(repo *git.Repository, commit *object.Commit, bundleDirectory string, since string, t *bundleTemplate) (graphmanChanges, error) {
+	changes := graphmanChanges{}
+	bundleChanges, err := util.GetBundleChanges(repo, commit, bundleDirectory, since, ".json")
+	if err != nil {
+		return changes, err
+	}
+
+	sort.Slice(bundleChanges, func(i, j int) bool {
+		return bundleChanges[i].Name < bundleChanges[j].Name
+	})
+
+	for _, c := range bundleChanges {
+		c.From, _ = t.resolve(c.From, util.EscapeJSON)
+		c.To, _ = t.resolve(c.To, util.EscapeJSON)
+		from := graphman.Bundle{}
+		if c.From != nil {
+			from, err = graphman.ParseBundle(c.From)
+			if err != nil {
+				return changes, fmt.Errorf("%s@%s: %w", c.Name, since, err)
+			}
+		}
+		to := graphman.Bundle{}
+		if c.To != nil {
+			to, err = graphman.ParseBundle(c.To)
+			if err != nil {
+				return changes, fmt.Errorf("%s: %w", c.Name, err)
+			}
+			changes.install = append(changes.install, to)
+		}
+		if removed := graphman.Removed(from, to); len(removed) > 0 {
+			changes.delete = append(changes.delete, removed)
+		}
+	}
+	return changes, nil
+}
+
+// getGraphmanConfigMapBundles reads the Graphman ConfigMaps referenced by the Gateway and returns
+// their bundles, with placeholders resolved by t, along with a checksum of their contents.
+func getGraphmanConfigMapBundles(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway, t *bundleTemplate) (graphmanChanges, string, error) {
+	changes := graphmanChanges{}
+	if !gw.Spec.App.Management.Graphman.Enabled || len(gw.Spec.App.Management.Graphman.ConfigMaps) == 0 {
+		return changes, "", nil
+	}
+
+	h := sha1.New()
+	for _, name := range gw.Spec.App.Management.Graphman.ConfigMaps {
+		cm := &corev1.ConfigMap{}
+		err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: gw.Namespace}, cm)
+		if err != nil {
+			r.Log.Error(err, "Failed to retrieve graphman ConfigMap", "Name", gw.Name, "Namespace", gw.Namespace, "ConfigMap", name)
+			return changes, "", err
+		}
+
+		keys := make([]string, 0, len(cm.Data))
+		for k := range cm.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			data, unresolved := t.resolve([]byte(cm.Data[k]), util.EscapeJSON)
+			if len(unresolved) > 0 {
+				return changes, "", fmt.Errorf("%s/%s: unresolved placeholders %s", name, k, strings.Join(unresolved, ", "))
+			}
+			if problems := graphman.ValidateBundle(data); len(problems) > 0 {
+				return changes, "", fmt.Errorf("%s/%s: invalid bundle: %s", name, k, strings.Join(problems, "; "))
+			}
+			bundle, err := graphman.ParseBundle(data)
+			if err != nil {
+				return changes, "", fmt.Errorf("%s/%s: %w", name, k, err)
+			}
+			if strings.HasSuffix(k, ".delete.json") {
+				changes.delete = append(changes.delete, bundle)
+			} else {
+				changes.install = append(changes.install, bundle)
+			}
+			h.Write([]byte(name + "/" + k))
+			h.Write(data)
+		}
+	}
+
+	return changes, hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,156 @@
+package gateway
+
+import (
+	"context"
+	"testing"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/graphman/graphmantest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestReconcileGraphman(t *testing.T) {
+	s := graphmantest.NewServer("admin", "7layer")
+	defer s.Close()
+	gw, objs := newTestGateway(t, s.URL, s.CACert())
+	gw.Spec.App.Management.Graphman = securityv1.Graphman{Enabled: true, ConfigMaps: []string{"ssg-graphman"}}
+	gw.Spec.App.Templating = securityv1.Templating{Enabled: true, Values: []securityv1.TemplateValues{{ConfigMapName: "ssg-values"}}}
+	bundles := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "ssg-graphman", Namespace: gw.Namespace},
+		Data: map[string]string{
+			"cwp.json":      `{"clusterProperties":[{"name":"cwp.one","value":"1"},{"name":"cwp.two","value":"${env.greeting}"}]}`,
+			"services.json": `{"services":[{"name":"hello","resolutionPath":"/hello","enabled":true}]}`,
+		},
+	}
+	values := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "ssg-values", Namespace: gw.Namespace},
+		Data:       map[string]string{"greeting": "hello"},
+	}
+	r := newTestReconciler(t, gw, append(objs, bundles, values)...)
+	ctx := context.Background()
+
+	if err := reconcileGraphman(r, ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	props := s.Entities("clusterProperties")
+	if len(props) != 2 || props[1]["value"] != "hello" {
+		t.Fatalf("expected cluster properties with resolved placeholders, got %v", props)
+	}
+	if len(s.Entities("services")) != 1 {
+		t.Fatalf("expected the service to be installed, got %v", s.Entities("services"))
+	}
+	state := gw.Status.Gateway[0]
+	if state.SyncStatus != "applied" || state.BundleChecksum == "" || state.EntitiesApplied != 3 {
+		t.Fatalf("unexpected pod status %+v", state)
+	}
+	applied := &corev1.ConfigMap{}
+	if err := r.Get(ctx, types.NamespacedName{Name: gw.Name + "-graphman-applied", Namespace: gw.Namespace}, applied); err != nil {
+		t.Fatalf("expected the applied entities to be recorded: %v", err)
+	}
+
+	// pods that are in sync aren't called again
+	queries := len(s.Queries())
+	if err := reconcileGraphman(r, ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Queries()) != queries {
+		t.Fatalf("expected no queries for pods in sync, got %d more", len(s.Queries())-queries)
+	}
+
+	// entities of a removed key are deleted
+	if err := r.Get(ctx, types.NamespacedName{Name: bundles.Name, Namespace: bundles.Namespace}, bundles); err != nil {
+		t.Fatal(err)
+	}
+	delete(bundles.Data, "services.json")
+	if err := r.Update(ctx, bundles); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileGraphman(r, ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Entities("services")) != 0 || len(s.Entities("clusterProperties")) != 2 {
+		t.Fatalf("expected only the service to be deleted, got %v %v", s.Entities("services"), s.Entities("clusterProperties"))
+	}
+	checksum := gw.Status.Gateway[0].BundleChecksum
+
+	// a rejected entity fails the sync and leaves the pod behind
+	s.Reject("clusterProperties", "cwp.three", "invalid value")
+	if err := r.Get(ctx, types.NamespacedName{Name: bundles.Name, Namespace: bundles.Namespace}, bundles); err != nil {
+		t.Fatal(err)
+	}
+	bundles.Data["cwp.json"] = `{"clusterProperties":[{"name":"cwp.one","value":"1"},{"name":"cwp.three","value":"3"}]}`
+	if err := r.Update(ctx, bundles); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileGraphman(r, ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	state = gw.Status.Gateway[0]
+	if state.SyncStatus != "failed" || state.EntitiesFailed != 1 || state.BundleChecksum != checksum {
+		t.Fatalf("unexpected pod status %+v", state)
+	}
+
+	stored := &securityv1.Gateway{}
+	if err := r.Get(ctx, types.NamespacedName{Name: gw.Name, Namespace: gw.Namespace}, stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status.Gateway[0].SyncStatus != "failed" || stored.Status.Gateway[0].SyncError == "" {
+		t.Fatalf("expected the failure to be stored, got %+v", stored.Status.Gateway[0])
+	}
+}
+
+func TestReconcileGraphmanDryRun(t *testing.T) {
+	s := graphmantest.NewServer("admin", "7layer")
+	defer s.Close()
+	gw, objs := newTestGateway(t, s.URL, s.CACert())
+	gw.Spec.App.Management.Graphman = securityv1.Graphman{Enabled: true, ConfigMaps: []string{"ssg-graphman"}}
+	gw.Annotations = map[string]string{dryRunAnnotation: "true"}
+	bundles := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "ssg-graphman", Namespace: gw.Namespace},
+		Data:       map[string]string{"cwp.json": `{"clusterProperties":[{"name":"cwp.one","value":"1"}]}`},
+	}
+	r := newTestReconciler(t, gw, append(objs, bundles)...)
+	ctx := context.Background()
+
+	// the bundles wait for their checksum to be approved
+	if err := reconcileGraphman(r, ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	pending := gw.Status.PendingGraphmanChanges
+	if len(s.Entities("clusterProperties")) != 0 || pending == nil || pending.Created != 1 || pending.Pod != "ssg-0" {
+		t.Fatalf("expected the bundles to be held back with their pending changes, got %v %+v", s.Entities("clusterProperties"), pending)
+	}
+	if err := updateHeldBackCondition(r, ctx, gw); err != nil || !hasGatewayCondition(gw, heldBackCondition) {
+		t.Fatalf("expected the BundlesHeldBack condition to be set: %v", err)
+	}
+
+	gw.Annotations[approveCommitAnnotation] = "0000000000000000000000000000000000000000, " + pending.CommitID
+	if err := r.Update(ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileGraphman(r, ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Entities("clusterProperties")) != 1 || gw.Status.PendingGraphmanChanges != nil || gw.Status.GraphmanChecksum != pending.CommitID {
+		t.Fatalf("expected the approved bundles to be applied, got %v %+v", s.Entities("clusterProperties"), gw.Status)
+	}
+
+	// approved bundles stay applied once the annotation is removed, further changes wait again
+	delete(gw.Annotations, approveCommitAnnotation)
+	if err := r.Update(ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	bundles.Data["cwp.json"] = `{"clusterProperties":[{"name":"cwp.one","value":"2"}]}`
+	if err := r.Update(ctx, bundles); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileGraphman(r, ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	props := s.Entities("clusterProperties")
+	if len(props) != 1 || props[0]["value"] != "1" || gw.Status.PendingGraphmanChanges == nil || gw.Status.PendingGraphmanChanges.Updated != 1 {
+		t.Fatalf("expected the change to be held back, got %v %+v", props, gw.Status.PendingGraphmanChanges)
+	}
+}
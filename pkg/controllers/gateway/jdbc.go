@@ -0,0 +1,97 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/secrets"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// reconcileJDBCConnections generates the JDBC connection bootstrap bundle. Passwords are left to the secure
+// password bundle, the connections only carry their users.
+func reconcileJDBCConnections(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) error {
+	usernames := map[string]string{}
+	for _, c := range gw.Spec.App.JDBCConnections {
+		secret := &corev1.Secret{}
+		err := r.Get(ctx, types.NamespacedName{Name: c.SecretName, Namespace: gw.Namespace}, secret)
+		if err != nil {
+			r.Log.Error(err, "Failed to retrieve JDBC connection Secret", "Name", gw.Name, "Namespace", gw.Namespace, "Secret", c.SecretName)
+			return err
+		}
+		username, ok := secret.Data[util.JDBCUsernameKey(c)]
+		if !ok {
+			err := fmt.Errorf("secret %s has no key %s for jdbc connection %s", c.SecretName, util.JDBCUsernameKey(c), c.Name)
+			r.Log.Error(err, "JDBC connection user not found", "Name", gw.Name, "Namespace", gw.Namespace)
+			return err
+		}
+		usernames[c.Name] = string(username)
+	}
+
+	bundle, err := util.BuildJDBCBundle(gw.Spec.App.JDBCConnections, usernames)
+	if err != nil {
+		r.Log.Error(err, "Failed to build JDBC connection bundle", "Name", gw.Name, "Namespace", gw.Namespace)
+		return err
+	}
+
+	return reconcileBundleSecret(r, ctx, gw, secrets.NewJDBCSecret(gw, bundle))
+}
+
+// updateJDBCConnectionStatus reports whether each JDBC connection exists on a ready Gateway pod. Connections
+// are only checked through the Restman API, without it they're reported as unknown.
+func updateJDBCConnectionStatus(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) error {
+	statuses := []securityv1.JDBCConnectionStatus{}
+	for _, c := range gw.Spec.App.JDBCConnections {
+		statuses = append(statuses, securityv1.JDBCConnectionStatus{Name: c.Name, State: "unknown"})
+	}
+
+	pod, err := readyPod(r, ctx, gw)
+	if restmanEnabled(gw) && err == nil {
+		existing, err := listJDBCConnections(r, ctx, gw, pod)
+		if err != nil {
+			r.Log.Error(err, "Failed to list JDBC connections", "Name", gw.Name, "Namespace", gw.Namespace, "Pod", pod.Name)
+		} else {
+			for i := range statuses {
+				statuses[i].State = "missing"
+				statuses[i].Pod = pod.Name
+				if existing[statuses[i].Name] {
+					statuses[i].State = "created"
+				}
+			}
+		}
+	}
+
+	if reflect.DeepEqual(statuses, gw.Status.JDBCConnections) {
+		return nil
+	}
+	gw.Status.JDBCConnections = statuses
+	if err := r.Client.Status().Update(ctx, gw); err != nil {
+		r.Log.Error(err, "Failed to update JDBC connection status", "Name", gw.Name, "Namespace", gw.Namespace)
+		return err
+	}
+	return nil
+}
+
+func listJDBCConnections(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway, pod *corev1.Pod) (map[string]bool, error) {
+	api, err := getManagementAPI(r, ctx, gw)
+	if err != nil {
+		return nil, err
+	}
+	c, err := api.restman(pod.Status.PodIP)
+	if err != nil {
+		return nil, err
+	}
+	connections, err := c.ListJDBCConnections(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for _, conn := range connections {
+		names[conn.Name] = true
+	}
+	return names, nil
+}
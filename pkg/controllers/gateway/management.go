@@ -0,0 +1,122 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/graphman"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/management"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/restman"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// managementAPI connects to the Restman and Graphman APIs of the Gateway pods with the admin credentials,
+// verifying their certificate with spec.app.management.ca if it's set. The Gateway generates a self-signed
+// certificate by default, which can't be verified, so it isn't without a CA. Clients share their connections.
+type managementAPI struct {
+	username string
+	password string
+	port     string
+	opts     management.Options
+}
+
+// managementCertificateCondition reports whether the certificate of the management APIs is verified
+const managementCertificateCondition = "ManagementCertificateVerified"
+
+// managementAPIUsed returns true if the operator calls the Restman or Graphman APIs of the Gateway pods
+func managementAPIUsed(gw *securityv1.Gateway) bool {
+	return restmanEnabled(gw) || gw.Spec.App.Management.Graphman.Enabled || len(gw.Spec.App.RepositoryReferences) > 0 ||
+		len(gw.Spec.App.JDBCConnections) > 0 || (gw.Spec.App.Repository.Enabled && gw.Spec.App.Repository.Method == "graphman")
+}
+
+// updateManagementCertificateCondition reports that the certificate of the management APIs isn't verified
+// when spec.app.management.ca isn't set
+func updateManagementCertificateCondition(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) error {
+	changed := false
+	ca := gw.Spec.App.Management.CA
+	if ca.ConfigMapName != "" || ca.SecretName != "" {
+		changed = setGatewayCondition(gw, managementCertificateCondition, corev1.ConditionTrue, "CAConfigured", "the certificate of the management APIs is verified with spec.app.management.ca")
+	} else {
+		changed = setGatewayCondition(gw, managementCertificateCondition, corev1.ConditionFalse, "NoCA", "spec.app.management.ca isn't set, the certificate of the management APIs isn't verified")
+	}
+	if !changed {
+		return nil
+	}
+	if err := r.Client.Status().Update(ctx, gw); err != nil {
+		r.Log.Error(err, "Failed to update management certificate status", "Name", gw.Name, "Namespace", gw.Namespace)
+		return err
+	}
+	return nil
+}
+
+// getManagementAPI reads the admin credentials and management CA of the Gateway
+func getManagementAPI(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) (*managementAPI, error) {
+	username, password, err := getManagementCredentials(r, ctx, gw)
+	if err != nil {
+		return nil, err
+	}
+	api := &managementAPI{
+		username: username,
+		password: password,
+		port:     managementPort(gw),
+		opts:     management.Options{InsecureSkipVerify: true, Retries: 2},
+	}
+
+	ca := gw.Spec.App.Management.CA
+	if ca.ConfigMapName == "" && ca.SecretName == "" {
+		return api, nil
+	}
+	if ca.ConfigMapName != "" && ca.SecretName != "" {
+		return nil, errors.New("management CA needs one of configMapName or secretName")
+	}
+	key := ca.Key
+	if key == "" {
+		key = "ca.crt"
+	}
+	data, err := keyData(r, ctx, gw, ca.ConfigMapName, ca.SecretName, key)
+	if err != nil {
+		r.Log.Error(err, "Failed to retrieve management CA", "Name", gw.Name, "Namespace", gw.Namespace)
+		return nil, fmt.Errorf("management CA: %w", err)
+	}
+	api.opts = management.Options{CACert: data, ServerName: ca.ServerName, Retries: 2}
+	return api, nil
+}
+
+// restman returns a client for the Restman API of the pod at podIP
+func (m *managementAPI) restman(podIP string) (*restman.Client, error) {
+	return restman.NewClient("https://"+podIP+":"+m.port, m.username, m.password, m.opts)
+}
+
+// graphman returns a client for the Graphman API of the pod at podIP
+func (m *managementAPI) graphman(podIP string) (*graphman.Client, error) {
+	return graphman.NewClient("https://"+podIP+":"+m.port+"/graphman", m.username, m.password, m.opts)
+}
+
+// keyData reads key from the named Secret, or the named ConfigMap if secretName is empty
+func keyData(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway, configMapName string, secretName string, key string) ([]byte, error) {
+	if secretName != "" {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Name: secretName, Namespace: gw.Namespace}, secret); err != nil {
+			return nil, err
+		}
+		data, ok := secret.Data[key]
+		if !ok {
+			return nil, fmt.Errorf("secret %s has no key %s", secretName, key)
+		}
+		return data, nil
+	}
+	cm := &corev1.ConfigMap{}
+	if err := r.Get(ctx, types.NamespacedName{Name: configMapName, Namespace: gw.Namespace}, cm); err != nil {
+		return nil, err
+	}
+	if data, ok := cm.Data[key]; ok {
+		return []byte(data), nil
+	}
+	if data, ok := cm.BinaryData[key]; ok {
+		return data, nil
+	}
+	return nil, fmt.Errorf("configmap %s has no key %s", configMapName, key)
+}
@@ -0,0 +1,37 @@
+package gateway
+
+import (
+	"context"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/secrets"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// reconcilePrivateKeys generates the private key bootstrap bundle from the TLS Secrets referenced in
+// spec.app.privateKeys. Renewed Secrets change the bundle Secret the Gateway pods mount, which rolls them.
+func reconcilePrivateKeys(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) error {
+	tlsSecrets := map[string]map[string][]byte{}
+	for _, pk := range gw.Spec.App.PrivateKeys {
+		if _, ok := tlsSecrets[pk.SecretName]; ok {
+			continue
+		}
+		secret := &corev1.Secret{}
+		err := r.Get(ctx, types.NamespacedName{Name: pk.SecretName, Namespace: gw.Namespace}, secret)
+		if err != nil {
+			r.Log.Error(err, "Failed to retrieve private key Secret", "Name", gw.Name, "Namespace", gw.Namespace, "Secret", pk.SecretName)
+			return err
+		}
+		tlsSecrets[pk.SecretName] = secret.Data
+	}
+
+	bundle, err := util.BuildPrivateKeyBundle(gw.Spec.App.PrivateKeys, tlsSecrets)
+	if err != nil {
+		r.Log.Error(err, "Failed to build private key bundle", "Name", gw.Name, "Namespace", gw.Namespace)
+		return err
+	}
+
+	return reconcileBundleSecret(r, ctx, gw, secrets.NewPrivateKeySecret(gw, bundle))
+}
@@ -0,0 +1,548 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/config"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/graphman"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/secrets"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// repositorySource is a Repository referenced by a Gateway, at the commit being applied
+type repositorySource struct {
+	ref    securityv1.RepositoryReference
+	repo   *git.Repository
+	commit *object.Commit
+	// template resolves the placeholders of the bundles
+	template *bundleTemplate
+	// entities maps the key of each entity the source defines to a readable name. Graphman entities are
+	// keyed by entity type and key, Restman entities by type and id.
+	entities         map[string]string
+	graphmanEntities map[string]map[string]interface{}
+	restmanBundles   map[string][]byte
+	restmanEntities  map[string][]string
+	// changes since a commit are shared by pods that applied the same commit
+	graphmanChanges map[string]graphmanChanges
+	restmanChanges  map[string]map[string][]byte
+}
+
+// reconcileRepositoryReferences brings every ready Gateway pod up to the latest commit of each Repository
+// referenced by the Gateway. Pods receive the bundles that changed since the commit of the Repository they
+// last applied, or every bundle if they haven't applied one yet. Entities defined by more than one Repository
+// are reported in the Gateway status and taken from the Repository applied last.
+// In dry run mode a new Repository commit is held back until it's approved, the pods keep the commit
+// applied before it and the changes it would make are reported in the status of the Repository.
+func reconcileRepositoryReferences(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) error {
+	refs := util.RepositoryReferences(gw)
+
+	previous := map[string]securityv1.RepositoryReferenceStatus{}
+	for _, s := range gw.Status.Repositories {
+		previous[s.Name] = s
+	}
+	repos := map[string]*securityv1.Repository{}
+	status := []securityv1.RepositoryReferenceStatus{}
+	held := map[string]bool{}
+	behind, stale := false, false
+	for _, ref := range refs {
+		repo := &securityv1.Repository{}
+		err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: gw.Namespace}, repo)
+		if err != nil {
+			r.Log.Error(err, "Failed to retrieve Repository", "Name", gw.Name, "Namespace", gw.Namespace, "Repository", ref.Name)
+			return err
+		}
+		repos[ref.Name] = repo
+		commitId := repo.Status.CommitID
+		if prev := previous[ref.Name]; dryRun(gw) && commitId != "" && commitId != prev.CommitID && !commitApproved(gw, commitId) {
+			held[ref.Name] = true
+			stale = stale || pendingChangesStale(prev.PendingChanges, commitId)
+			commitId = prev.CommitID
+		}
+		status = append(status, securityv1.RepositoryReferenceStatus{Name: ref.Name, CommitID: commitId})
+		for _, state := range gw.Status.Gateway {
+			if ref.Method != "mount" && state.Ready && commitId != "" && appliedRepositoryCommit(state, repo.Name) != commitId {
+				behind = true
+			}
+		}
+	}
+	if !behind && !stale && reflect.DeepEqual(status, repositoryCommits(gw.Status.Repositories)) {
+		return nil
+	}
+
+	t, err := getBundleTemplate(r, ctx, gw)
+	if err != nil {
+		return err
+	}
+
+	// sources after one that isn't fetched yet or is invalid aren't applied as they may depend on it, the
+	// reason each source isn't applied is reported in its status
+	sources := []*repositorySource{}
+	bundleErrors := []securityv1.BundleError{}
+	blocked := false
+	for i, ref := range refs {
+		switch ref.Method {
+		case "", "graphman", "restman", "mount":
+		default:
+			bundleErrors = append(bundleErrors, securityv1.BundleError{
+				Source: "repositories/" + ref.Name,
+				Error:  fmt.Sprintf("unknown method %s, expected graphman, restman or mount", ref.Method),
+			})
+			status[i].Reason = "Invalid"
+			blocked = true
+			continue
+		}
+		repo := repos[ref.Name]
+		if held[ref.Name] {
+			status[i].PendingChanges = previous[ref.Name].PendingChanges
+			if pendingChangesStale(status[i].PendingChanges, repo.Status.CommitID) {
+				status[i].PendingChanges = diffRepositoryReference(r, ctx, gw, ref, repo, status[i].CommitID, t)
+				r.Log.Info("Repository commit is waiting for approval", "Name", gw.Name, "Namespace", gw.Namespace, "Repository", ref.Name, "Commit", repo.Status.CommitID,
+					"Created", status[i].PendingChanges.Created, "Updated", status[i].PendingChanges.Updated, "Deleted", status[i].PendingChanges.Deleted)
+			}
+		}
+		if status[i].CommitID == "" {
+			status[i].Reason = "NotFetched"
+			if held[ref.Name] {
+				status[i].Reason = "WaitingForApproval"
+			}
+			blocked = true
+			continue
+		}
+		gitRepo, commit, err := checkoutRepository(r, ctx, repo, status[i].CommitID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", ref.Name, err)
+		}
+		files, err := repositoryBundleFiles(gitRepo, commit, ref.Directory, ref.Method)
+		if err != nil {
+			return fmt.Errorf("%s: %w", ref.Name, err)
+		}
+		if errs := validateBundleFiles("repositories/"+ref.Name, files, t); len(errs) > 0 {
+			r.Log.Info("Repository bundles are invalid and won't be applied", "Name", gw.Name, "Namespace", gw.Namespace, "Repository", ref.Name, "Commit", status[i].CommitID, "Errors", len(errs))
+			bundleErrors = append(bundleErrors, errs...)
+			status[i].Reason = "Invalid"
+			blocked = true
+			continue
+		}
+		if blocked {
+			status[i].Reason = "WaitingForEarlier"
+			continue
+		}
+		src, err := loadRepositorySource(ref, gitRepo, commit, t)
+		if err != nil {
+			return fmt.Errorf("%s: %w", ref.Name, err)
+		}
+		sources = append(sources, src)
+	}
+	setBundleErrors(gw, "repositories", bundleErrors)
+
+	// owners lists the sources defining each entity in the order they're applied, the last one wins
+	owners := map[string][]string{}
+	names := map[string]string{}
+	for _, src := range sources {
+		for key, name := range src.entities {
+			owners[key] = append(owners[key], src.ref.Name)
+			names[key] = name
+		}
+	}
+	conflicts := []securityv1.RepositoryConflict{}
+	for key, sourceNames := range owners {
+		if len(sourceNames) > 1 {
+			conflicts = append(conflicts, securityv1.RepositoryConflict{Entity: names[key], Repositories: sourceNames})
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Entity < conflicts[j].Entity
+	})
+	if len(conflicts) > 0 && !reflect.DeepEqual(conflicts, gw.Status.RepositoryConflicts) {
+		r.Log.Info("Referenced repositories define the same entities", "Name", gw.Name, "Namespace", gw.Namespace, "Conflicts", len(conflicts))
+	}
+	gw.Status.Repositories = status
+	gw.Status.RepositoryConflicts = conflicts
+	if len(conflicts) == 0 {
+		gw.Status.RepositoryConflicts = nil
+	}
+
+	// mounted bundles reach the pods when the Deployment rolls for the changed ConfigMap or Secret
+	for _, src := range sources {
+		if src.ref.Method == "mount" {
+			if err := reconcileRepositoryBundle(r, ctx, gw, src); err != nil {
+				return err
+			}
+		}
+	}
+
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{
+		client.InNamespace(gw.Namespace),
+		client.MatchingLabels(util.DefaultLabels(gw)),
+	}
+	if err := r.List(ctx, podList, listOpts...); err != nil {
+		r.Log.Error(err, "Failed to list pods", "Namespace", gw.Namespace, "Name", gw.Name)
+		return err
+	}
+
+	api, err := getManagementAPI(r, ctx, gw)
+	if err != nil {
+		return err
+	}
+
+	for j, state := range gw.Status.Gateway {
+		if !state.Ready {
+			continue
+		}
+		podIP := ""
+		for _, pod := range podList.Items {
+			if pod.Name == state.Name {
+				podIP = pod.Status.PodIP
+			}
+		}
+		if podIP == "" {
+			continue
+		}
+
+		applied, failed, synced := int32(0), int32(0), false
+		for i, src := range sources {
+			if src.ref.Method == "mount" {
+				continue
+			}
+			commitId := src.commit.Hash.String()
+			since := appliedRepositoryCommit(state, src.ref.Name)
+			if since == commitId {
+				continue
+			}
+
+			r.Log.Info("Applying repository bundles", "Name", gw.Name, "Namespace", gw.Namespace, "Pod", state.Name, "Repository", src.ref.Name, "Commit", commitId)
+			if src.ref.Method == "restman" {
+				err = applyRestmanSource(ctx, api, podIP, sources, i, since)
+			} else {
+				var result graphman.Result
+				result, err = applyGraphmanSource(ctx, api, podIP, sources, i, since, owners)
+				applied += int32(result.Applied)
+				failed += int32(result.Failed)
+				synced = true
+				if err == nil {
+					err = result.Err()
+				}
+			}
+			if err != nil {
+				r.Log.Error(err, "Failed to apply repository bundles", "Name", gw.Name, "Namespace", gw.Namespace, "Pod", state.Name, "Repository", src.ref.Name)
+				gw.Status.Gateway[j].SyncStatus = "failed"
+				gw.Status.Gateway[j].SyncError = fmt.Sprintf("%s: %s", src.ref.Name, err)
+				// later sources may depend on this one, they're applied once it succeeds
+				break
+			}
+
+			setAppliedRepositoryCommit(&gw.Status.Gateway[j], src.ref.Name, commitId)
+			gw.Status.Gateway[j].SyncStatus = "applied"
+			gw.Status.Gateway[j].SyncError = ""
+		}
+		// entity counts cover every Graphman source applied to the pod in this round
+		if synced {
+			gw.Status.Gateway[j].EntitiesApplied = applied
+			gw.Status.Gateway[j].EntitiesFailed = failed
+		}
+	}
+
+	if err := r.Client.Status().Update(ctx, gw); err != nil {
+		r.Log.Error(err, "Failed to update repository status", "Namespace", gw.Namespace, "Name", gw.Name)
+		return err
+	}
+
+	return nil
+}
+
+// loadRepositorySource indexes the entities defined by the bundles of a Repository at commit
+func loadRepositorySource(ref securityv1.RepositoryReference, repo *git.Repository, commit *object.Commit, t *bundleTemplate) (*repositorySource, error) {
+	src := &repositorySource{
+		ref:              ref,
+		repo:             repo,
+		commit:           commit,
+		template:         t,
+		entities:         map[string]string{},
+		graphmanEntities: map[string]map[string]interface{}{},
+		restmanEntities:  map[string][]string{},
+		graphmanChanges:  map[string]graphmanChanges{},
+		restmanChanges:   map[string]map[string][]byte{},
+	}
+
+	if ref.Method == "restman" || ref.Method == "mount" {
+		bundles, err := util.GetBundles(repo, commit, ref.Directory, "")
+		if err != nil {
+			return nil, err
+		}
+		bundles = t.resolveBundles(bundles)
+		src.restmanBundles = bundles
+		for name, bundle := range bundles {
+			b, err := util.ParseBundle(bundle)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			for _, item := range b.Entities() {
+				key := util.ItemKey(item)
+				src.restmanEntities[name] = append(src.restmanEntities[name], key)
+				src.entities[key] = item.Type + "/" + item.Name
+			}
+		}
+		return src, nil
+	}
+
+	changes, err := getGraphmanRepositoryChanges(repo, commit, ref.Directory, "", t)
+	if err != nil {
+		return nil, err
+	}
+	for _, bundle := range changes.install {
+		for key, entity := range bundle.Entities() {
+			src.graphmanEntities[key] = entity
+			src.entities[key] = key
+		}
+	}
+	return src, nil
+}
+
+// reconcileRepositoryBundle writes the bundles of a mounted source to the ConfigMap, or Secret if template
+// values are read from Secrets, that the Gateway pods mount
+func reconcileRepositoryBundle(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway, src *repositorySource) error {
+	// keys can't contain the path separator of bundles in sub-directories
+	data := map[string][]byte{}
+	for name, bundle := range src.restmanBundles {
+		data[strings.ReplaceAll(name, "/", "_")] = bundle
+	}
+	if gw.Spec.App.Templating.Enabled && util.TemplateValuesFromSecrets(gw) {
+		return reconcileBundleSecret(r, ctx, gw, secrets.NewRepositoryBundleSecret(gw, src.ref.Name, data))
+	}
+	bundles := map[string]string{}
+	for k, v := range data {
+		bundles[k] = string(v)
+	}
+	return reconcileBundleConfigMap(r, ctx, gw, config.NewRepositoryBundleConfigMap(gw, src.ref.Name, bundles))
+}
+
+// applyGraphmanSource applies the Graphman bundles of sources[i] that changed since a commit to a Gateway pod.
+// Entities that a later source also defines are left alone, and entities the source no longer defines are
+// only deleted if no other source defines them, otherwise they're installed from the source that does.
+func applyGraphmanSource(ctx context.Context, api *managementAPI, podIP string, sources []*repositorySource, i int, since string, owners map[string][]string) (graphman.Result, error) {
+	src := sources[i]
+	changes, ok := src.graphmanChanges[since]
+	if !ok {
+		var err error
+		changes, err = getGraphmanRepositoryChanges(src.repo, src.commit, src.ref.Directory, since, src.template)
+		if err != nil {
+			return graphman.Result{}, err
+		}
+		src.graphmanChanges[since] = changes
+	}
+
+	owned := func(key string) bool {
+		o := owners[key]
+		return len(o) > 0 && o[len(o)-1] == src.ref.Name
+	}
+	undefined := func(key string) bool {
+		return len(owners[key]) == 0
+	}
+
+	filtered := graphmanChanges{}
+	for _, bundle := range changes.install {
+		if b := bundle.Filter(owned); len(b) > 0 {
+			filtered.install = append(filtered.install, b)
+		}
+	}
+	reinstall := graphman.Bundle{}
+	for _, bundle := range changes.delete {
+		if b := bundle.Filter(undefined); len(b) > 0 {
+			filtered.delete = append(filtered.delete, b)
+		}
+		for key := range bundle.Entities() {
+			o := owners[key]
+			if len(o) == 0 || o[len(o)-1] == src.ref.Name {
+				continue
+			}
+			for _, other := range sources {
+				if other.ref.Name == o[len(o)-1] {
+					reinstall.Add(key, other.graphmanEntities[key])
+				}
+			}
+		}
+	}
+	if len(reinstall) > 0 {
+		filtered.install = append(filtered.install, reinstall)
+	}
+
+	return applyGraphmanChanges(ctx, api, podIP, filtered)
+}
+
+// applyRestmanSource imports the Restman bundles of sources[i] that changed since a commit to a Gateway pod.
+// Bundles can't be applied in part, so bundles of later sources that define the same entities are imported
+// again afterwards.
+func applyRestmanSource(ctx context.Context, api *managementAPI, podIP string, sources []*repositorySource, i int, since string) error {
+	src := sources[i]
+	bundles, ok := src.restmanChanges[since]
+	if !ok {
+		var err error
+		bundles, err = util.GetBundles(src.repo, src.commit, src.ref.Directory, since)
+		if err != nil {
+			return err
+		}
+		bundles = src.template.resolveBundles(bundles)
+		src.restmanChanges[since] = bundles
+	}
+	if err := restmanApplyBundles(ctx, api, podIP, bundles); err != nil {
+		return err
+	}
+
+	overwritten := map[string]bool{}
+	for name := range bundles {
+		for _, key := range src.restmanEntities[name] {
+			overwritten[key] = true
+		}
+	}
+	for _, later := range sources[i+1:] {
+		if later.ref.Method != "restman" {
+			continue
+		}
+		reapply := map[string][]byte{}
+		for name, keys := range later.restmanEntities {
+			for _, key := range keys {
+				if overwritten[key] {
+					reapply[name] = later.restmanBundles[name]
+					break
+				}
+			}
+		}
+		if len(reapply) == 0 {
+			continue
+		}
+		if err := restmanApplyBundles(ctx, api, podIP, reapply); err != nil {
+			return fmt.Errorf("%s: %w", later.ref.Name, err)
+		}
+	}
+	return nil
+}
+
+// repositoryCommits returns the Repository commits of status without the reason they aren't applied or their
+// pending changes
+func repositoryCommits(status []securityv1.RepositoryReferenceStatus) []securityv1.RepositoryReferenceStatus {
+	if status == nil {
+		return nil
+	}
+	commits := []securityv1.RepositoryReferenceStatus{}
+	for _, s := range status {
+		commits = append(commits, securityv1.RepositoryReferenceStatus{Name: s.Name, CommitID: s.CommitID})
+	}
+	return commits
+}
+
+func appliedRepositoryCommit(state securityv1.GatewayState, name string) string {
+	for _, rc := range state.RepositoryCommits {
+		if rc.Name == name {
+			return rc.CommitID
+		}
+	}
+	return ""
+}
+
+func setAppliedRepositoryCommit(state *securityv1.GatewayState, name string, commitId string) {
+	for i, rc := range state.RepositoryCommits {
+		if rc.Name == name {
+			state.RepositoryCommits[i].CommitID = commitId
+			return
+		}
+	}
+	state.RepositoryCommits = append(state.RepositoryCommits, securityv1.RepositoryCommit{Name: name, CommitID: commitId})
+}
+
+// checkoutRepository returns the cached repository of a Repository at commitId. http, s3 and oci
+// Repositories can't be cloned, when the cache doesn't have their latest commit it's imported again from
+// the storage Secret of the Repository. Those too large for a Secret are imported again by the Repository
+// controller, an error is returned until it has. Earlier commits are only available while they're cached.
+func checkoutRepository(r *GatewayReconciler, ctx context.Context, repo *securityv1.Repository, commitId string) (*git.Repository, *object.Commit, error) {
+	switch repo.Spec.Type {
+	case "http", "s3", "oci":
+	default:
+		creds, err := getGitCredentials(r, ctx, repo.Namespace, repo.Spec.SecretName)
+		if err != nil {
+			return nil, nil, err
+		}
+		return r.RepositoryCache.Checkout(repo.Spec.URL, creds, commitId)
+	}
+
+	if r.RepositoryCache.Has(repo.Spec.URL, commitId) {
+		return r.RepositoryCache.Checkout(repo.Spec.URL, nil, commitId)
+	}
+	if commitId != repo.Status.CommitID {
+		return nil, nil, fmt.Errorf("commit %s of the %s repository isn't cached, only its latest commit can be restored", commitId, repo.Spec.Type)
+	}
+	if repo.Status.StorageSecretName == "" {
+		return nil, nil, fmt.Errorf("commit %s isn't cached and the %s repository has no storage Secret, waiting for the Repository to be imported again", commitId, repo.Spec.Type)
+	}
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: repo.Status.StorageSecretName, Namespace: repo.Namespace}, secret); err != nil {
+		return nil, nil, err
+	}
+	gitRepo, commit, err := r.RepositoryCache.ImportArchive(repo.Spec.URL, secret.Data[util.RepositoryStorageKey])
+	if err != nil {
+		return nil, nil, err
+	}
+	if commit.Hash.String() != commitId {
+		return nil, nil, fmt.Errorf("storage Secret %s holds commit %s rather than %s", secret.Name, commit.Hash.String(), commitId)
+	}
+	return gitRepo, commit, nil
+}
+
+// getGitCredentials reads git credentials from a Secret, returning nil if secretName is empty
+func getGitCredentials(r *GatewayReconciler, ctx context.Context, namespace string, secretName string) (*util.GitCredentials, error) {
+	if secretName == "" {
+		return nil, nil
+	}
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: secretName, Namespace: namespace}, secret); err != nil {
+		return nil, err
+	}
+	return util.NewGitCredentials(secret.Data), nil
+}
+
+// gatewaysForRepository returns a request for each Gateway referencing the Repository
+func (r *GatewayReconciler) gatewaysForRepository(obj client.Object) []reconcile.Request {
+	gwList := &securityv1.GatewayList{}
+	if err := r.List(context.Background(), gwList, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "Failed to list Gateways", "Namespace", obj.GetNamespace())
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, gw := range gwList.Items {
+		for _, ref := range gw.Spec.App.RepositoryReferences {
+			if ref.Name == obj.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: gw.Name, Namespace: gw.Namespace}})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// repositoryCommitChanged only passes Repository updates that change the commit Gateways should run
+var repositoryCommitChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldRepo, ok := e.ObjectOld.(*securityv1.Repository)
+		if !ok {
+			return false
+		}
+		newRepo, ok := e.ObjectNew.(*securityv1.Repository)
+		if !ok {
+			return false
+		}
+		return oldRepo.Status.CommitID != newRepo.Status.CommitID
+	},
+}
@@ -0,0 +1,177 @@
+package gateway
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/restman/restmantest"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/util"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReconcileRepositoryReferencesColdCache(t *testing.T) {
+	s := restmantest.NewServer("admin", "7layer")
+	defer s.Close()
+	gw, objs := newTestGateway(t, s.URL, s.CACert())
+	gw.Spec.App.RepositoryReferences = []securityv1.RepositoryReference{{Name: "bundles", Method: "restman"}}
+
+	// the Repository controller imported the archive into its cache and stored it, the Gateway
+	// controller's cache hasn't seen it
+	url := "https://artifacts.example.com/bundles.tar.gz"
+	bundle, _ := util.BuildCWPBundle(map[string]string{"cwp.archived": "1"})
+	_, commit, err := util.NewRepositoryCache(t.TempDir()).CommitFiles(url, map[string][]byte{"cwp.bundle": bundle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := util.ArchiveCommit(commit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &securityv1.Repository{
+		ObjectMeta: metav1.ObjectMeta{Name: "bundles", Namespace: gw.Namespace},
+		Spec:       securityv1.RepositorySpec{Type: "http", URL: url},
+		Status:     securityv1.RepositoryStatus{CommitID: commit.Hash.String(), StorageSecretName: "bundles-repository"},
+	}
+	storage := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "bundles-repository", Namespace: gw.Namespace},
+		Data:       map[string][]byte{util.RepositoryStorageKey: archive},
+	}
+	r := newTestReconciler(t, gw, append(objs, repo, storage)...)
+	r.RepositoryCache = util.NewRepositoryCache(t.TempDir())
+	ctx := context.Background()
+
+	if err := reconcileRepositoryReferences(r, ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Bundles()) != 1 || !strings.Contains(string(s.Bundles()[0]), "cwp.archived") {
+		t.Fatalf("expected the archived bundle to be applied, got %q", s.Bundles())
+	}
+	if appliedRepositoryCommit(gw.Status.Gateway[0], "bundles") != commit.Hash.String() {
+		t.Fatalf("expected the pod to record the archive commit, got %+v", gw.Status.Gateway[0])
+	}
+
+	// without a storage Secret the Gateway waits for the Repository controller to import it again
+	r.RepositoryCache = util.NewRepositoryCache(t.TempDir())
+	repo.Status.StorageSecretName = ""
+	if _, _, err := checkoutRepository(r, ctx, repo, repo.Status.CommitID); err == nil || !strings.Contains(err.Error(), "isn't cached") {
+		t.Fatalf("expected an error for an uncached archive, got %v", err)
+	}
+}
+
+func TestReconcileRepositoryReferencesWaitForEarlier(t *testing.T) {
+	s := restmantest.NewServer("admin", "7layer")
+	defer s.Close()
+	gw, objs := newTestGateway(t, s.URL, s.CACert())
+	gw.Spec.App.RepositoryReferences = []securityv1.RepositoryReference{{Name: "base", Method: "restman"}, {Name: "overrides", Method: "restman"}}
+	base := &securityv1.Repository{
+		ObjectMeta: metav1.ObjectMeta{Name: "base", Namespace: gw.Namespace},
+		Spec:       securityv1.RepositorySpec{URL: "https://git.example.com/base.git"},
+	}
+	overrides := &securityv1.Repository{
+		ObjectMeta: metav1.ObjectMeta{Name: "overrides", Namespace: gw.Namespace},
+		Spec:       securityv1.RepositorySpec{URL: "https://git.example.com/overrides.git"},
+	}
+	cache := util.NewRepositoryCache(t.TempDir())
+	for _, repo := range []*securityv1.Repository{base, overrides} {
+		bundle, _ := util.BuildCWPBundle(map[string]string{"cwp." + repo.Name: "1"})
+		_, commit, err := cache.CommitFiles(repo.Spec.URL, map[string][]byte{"cwp.bundle": bundle})
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo.Status.CommitID = commit.Hash.String()
+	}
+	baseCommit := base.Status.CommitID
+	base.Status.CommitID = ""
+	r := newTestReconciler(t, gw, append(objs, base, overrides)...)
+	r.RepositoryCache = cache
+	ctx := context.Background()
+
+	// overrides may depend on base, it isn't applied until base has been fetched
+	if err := reconcileRepositoryReferences(r, ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Bundles()) != 0 {
+		t.Fatalf("expected no bundles to be applied, got %d", len(s.Bundles()))
+	}
+	if status := gw.Status.Repositories; len(status) != 2 || status[0].Reason != "NotFetched" || status[1].Reason != "WaitingForEarlier" {
+		t.Fatalf("unexpected repository status %+v", status)
+	}
+
+	base.Status.CommitID = baseCommit
+	if err := r.Status().Update(ctx, base); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileRepositoryReferences(r, ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Bundles()) != 2 || !strings.Contains(string(s.Bundles()[0]), "cwp.base") || !strings.Contains(string(s.Bundles()[1]), "cwp.overrides") {
+		t.Fatalf("expected base to be applied before overrides, got %q", s.Bundles())
+	}
+	if status := gw.Status.Repositories; status[0].Reason != "" || status[1].Reason != "" {
+		t.Fatalf("expected both repositories to be applied, got %+v", status)
+	}
+}
+
+func TestReconcileRepositoryReferencesDryRun(t *testing.T) {
+	s := restmantest.NewServer("admin", "7layer")
+	defer s.Close()
+	gw, objs := newTestGateway(t, s.URL, s.CACert())
+	gw.Spec.App.RepositoryReferences = []securityv1.RepositoryReference{{Name: "bundles", Method: "restman"}}
+	gw.Annotations = map[string]string{dryRunAnnotation: "true"}
+	repo := &securityv1.Repository{
+		ObjectMeta: metav1.ObjectMeta{Name: "bundles", Namespace: gw.Namespace},
+		Spec:       securityv1.RepositorySpec{URL: "https://git.example.com/bundles.git"},
+	}
+	cache := util.NewRepositoryCache(t.TempDir())
+	commit := func(value string) string {
+		bundle, _ := util.BuildCWPBundle(map[string]string{"cwp.value": value})
+		_, c, err := cache.CommitFiles(repo.Spec.URL, map[string][]byte{"cwp.bundle": bundle})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.Hash.String()
+	}
+	first := commit("1")
+	repo.Status.CommitID = first
+	r := newTestReconciler(t, gw, append(objs, repo)...)
+	r.RepositoryCache = cache
+	ctx := context.Background()
+
+	// nothing is applied until the first commit is approved
+	if err := reconcileRepositoryReferences(r, ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	status := gw.Status.Repositories[0]
+	if len(s.Bundles()) != 0 || status.Reason != "WaitingForApproval" || status.PendingChanges == nil || status.PendingChanges.CommitID != first || status.PendingChanges.Created != 1 {
+		t.Fatalf("expected the commit to wait for approval, got %+v %+v", status, status.PendingChanges)
+	}
+
+	gw.Annotations[approveCommitAnnotation] = first
+	if err := r.Update(ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileRepositoryReferences(r, ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	status = gw.Status.Repositories[0]
+	if len(s.Bundles()) != 1 || status.CommitID != first || status.PendingChanges != nil || appliedRepositoryCommit(gw.Status.Gateway[0], "bundles") != first {
+		t.Fatalf("expected the approved commit to be applied, got %+v", status)
+	}
+
+	// a new commit is held back, the pods keep the approved one
+	second := commit("2")
+	repo.Status.CommitID = second
+	if err := r.Status().Update(ctx, repo); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileRepositoryReferences(r, ctx, gw); err != nil {
+		t.Fatal(err)
+	}
+	status = gw.Status.Repositories[0]
+	if len(s.Bundles()) != 1 || status.CommitID != first || status.PendingChanges == nil || status.PendingChanges.CommitID != second {
+		t.Fatalf("expected the new commit to wait for approval, got %+v %+v", status, status.PendingChanges)
+	}
+}
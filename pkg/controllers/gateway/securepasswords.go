@@ -0,0 +1,47 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/secrets"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// reconcileSecurePasswords generates the secure password bootstrap bundle from the Secrets referenced
+// in spec.app.securePasswords and spec.app.jdbcConnections. The bundle Secret is mounted by the Gateway
+// pods, so they roll when it changes.
+func reconcileSecurePasswords(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) error {
+	passwords := map[string]string{}
+	sources := map[string]*corev1.Secret{}
+	securePasswords := util.SecurePasswords(gw)
+	for _, sp := range securePasswords {
+		source, ok := sources[sp.SecretName]
+		if !ok {
+			source = &corev1.Secret{}
+			err := r.Get(ctx, types.NamespacedName{Name: sp.SecretName, Namespace: gw.Namespace}, source)
+			if err != nil {
+				r.Log.Error(err, "Failed to retrieve secure password Secret", "Name", gw.Name, "Namespace", gw.Namespace, "Secret", sp.SecretName)
+				return err
+			}
+			sources[sp.SecretName] = source
+		}
+		value, ok := source.Data[sp.Key]
+		if !ok {
+			err := fmt.Errorf("secret %s has no key %s for secure password %s", sp.SecretName, sp.Key, sp.Name)
+			r.Log.Error(err, "Secure password not found", "Name", gw.Name, "Namespace", gw.Namespace)
+			return err
+		}
+		passwords[sp.Name] = string(value)
+	}
+
+	bundle, err := util.BuildSecurePasswordBundle(securePasswords, passwords)
+	if err != nil {
+		return err
+	}
+
+	return reconcileBundleSecret(r, ctx, gw, secrets.NewSecurePasswordSecret(gw, bundle))
+}
@@ -0,0 +1,191 @@
+package gateway
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/config"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/secrets"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// bundleTemplate resolves the placeholders of repository and ConfigMap bundles from the values in
+// spec.app.templating. A nil bundleTemplate leaves bundles unchanged.
+type bundleTemplate struct {
+	placeholders *util.Placeholders
+	values       map[string]string
+}
+
+// getBundleTemplate reads the template values of the Gateway, it returns nil if templating isn't enabled
+func getBundleTemplate(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) (*bundleTemplate, error) {
+	if !gw.Spec.App.Templating.Enabled {
+		return nil, nil
+	}
+
+	prefix := gw.Spec.App.Templating.Prefix
+	if prefix == "" {
+		prefix = util.DefaultTemplatePrefix
+	}
+	t := &bundleTemplate{placeholders: util.NewPlaceholders(prefix), values: map[string]string{}}
+	for _, v := range gw.Spec.App.Templating.Values {
+		if (v.ConfigMapName == "") == (v.SecretName == "") {
+			return nil, fmt.Errorf("template values need one of configMapName or secretName")
+		}
+		if v.ConfigMapName != "" {
+			cm := &corev1.ConfigMap{}
+			err := r.Get(ctx, types.NamespacedName{Name: v.ConfigMapName, Namespace: gw.Namespace}, cm)
+			if err != nil {
+				r.Log.Error(err, "Failed to retrieve template values ConfigMap", "Name", gw.Name, "Namespace", gw.Namespace, "ConfigMap", v.ConfigMapName)
+				return nil, err
+			}
+			for k, value := range cm.Data {
+				t.values[k] = value
+			}
+			continue
+		}
+		secret := &corev1.Secret{}
+		err := r.Get(ctx, types.NamespacedName{Name: v.SecretName, Namespace: gw.Namespace}, secret)
+		if err != nil {
+			r.Log.Error(err, "Failed to retrieve template values Secret", "Name", gw.Name, "Namespace", gw.Namespace, "Secret", v.SecretName)
+			return nil, err
+		}
+		for k, value := range secret.Data {
+			t.values[k] = string(value)
+		}
+	}
+	return t, nil
+}
+
+// checksum identifies the prefix and values of the template
+func (t *bundleTemplate) checksum() string {
+	if t == nil {
+		return ""
+	}
+	keys := make([]string, 0, len(t.values))
+	for k := range t.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha1.New()
+	h.Write([]byte(t.placeholders.Placeholder("")))
+	for _, k := range keys {
+		h.Write([]byte("\x00" + k + "\x00" + t.values[k]))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// resolve replaces the placeholders in data, returning the names that have no value
+func (t *bundleTemplate) resolve(data []byte, escape func(string) string) ([]byte, []string) {
+	if t == nil || data == nil {
+		return data, nil
+	}
+	return t.placeholders.Resolve(data, t.values, escape)
+}
+
+// resolveBundles resolves the placeholders of Restman bundles by name
+func (t *bundleTemplate) resolveBundles(bundles map[string][]byte) map[string][]byte {
+	if t == nil {
+		return bundles
+	}
+	resolved := map[string][]byte{}
+	for name, data := range bundles {
+		resolved[name], _ = t.resolve(data, util.EscapeXML)
+	}
+	return resolved
+}
+
+// bundleErrors returns an error for each placeholder of file that has no value
+func (t *bundleTemplate) bundleErrors(source string, file string, unresolved []string) []securityv1.BundleError {
+	errs := []securityv1.BundleError{}
+	for _, name := range unresolved {
+		errs = append(errs, securityv1.BundleError{Source: source, File: file, Error: "unresolved placeholder " + t.placeholders.Placeholder(name)})
+	}
+	return errs
+}
+
+// reconcileResolvedBundles writes the ConfigMap bundles mounted into the Gateway pods with their placeholders
+// resolved. They're written to Secrets when a template value is read from a Secret.
+func reconcileResolvedBundles(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) error {
+	t, err := getBundleTemplate(r, ctx, gw)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range gw.Spec.App.Bundle {
+		if b.Type != "configMap" {
+			continue
+		}
+		cm := &corev1.ConfigMap{}
+		err := r.Get(ctx, types.NamespacedName{Name: b.Name, Namespace: gw.Namespace}, cm)
+		if err != nil {
+			r.Log.Error(err, "Failed to retrieve bundle ConfigMap", "Name", gw.Name, "Namespace", gw.Namespace, "ConfigMap", b.Name)
+			return err
+		}
+
+		data := map[string][]byte{}
+		for k, v := range cm.Data {
+			data[k], _ = t.resolve([]byte(v), util.BundleEscaper(k))
+		}
+
+		if util.TemplateValuesFromSecrets(gw) {
+			for k, v := range cm.BinaryData {
+				data[k] = v
+			}
+			err = reconcileBundleSecret(r, ctx, gw, secrets.NewResolvedBundleSecret(gw, b.Name, data))
+		} else {
+			resolved := map[string]string{}
+			for k, v := range data {
+				resolved[k] = string(v)
+			}
+			err = reconcileBundleConfigMap(r, ctx, gw, config.NewResolvedBundleConfigMap(gw, b.Name, resolved, cm.BinaryData))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reconcileTemplateValues applies the repository bundles delivered live to the ready Gateway pods again when
+// a template value changes, rather than rolling the pods. Pods are marked as not having applied a commit
+// so they receive every bundle resolved with the new values. Graphman ConfigMap bundles are applied again
+// as their checksum covers the resolved bundles.
+func reconcileTemplateValues(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) error {
+	t, err := getBundleTemplate(r, ctx, gw)
+	if err != nil {
+		return err
+	}
+	checksum := t.checksum()
+	if checksum == gw.Status.TemplateChecksum {
+		return nil
+	}
+
+	// pods applying bundles for the first time already resolve them with the current values
+	if gw.Status.TemplateChecksum != "" {
+		repositoryLive := gw.Spec.App.Repository.Enabled && (gw.Spec.App.Repository.Method == "restman" || gw.Spec.App.Repository.Method == "graphman")
+		for i, state := range gw.Status.Gateway {
+			if !state.Ready {
+				continue
+			}
+			if repositoryLive {
+				gw.Status.Gateway[i].CommitID = ""
+			}
+			gw.Status.Gateway[i].RepositoryCommits = nil
+		}
+		r.Log.Info("Template values changed, applying bundles again", "Name", gw.Name, "Namespace", gw.Namespace)
+	}
+
+	gw.Status.TemplateChecksum = checksum
+	if err := r.Client.Status().Update(ctx, gw); err != nil {
+		r.Log.Error(err, "Failed to update template checksum", "Namespace", gw.Namespace, "Name", gw.Name)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package gateway
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/config"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/util"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const trustedCertsExpiringCondition = "TrustedCertsExpiring"
+
+// trustedCertExpiryWarning is how long before it expires a trusted certificate is reported as expiring
+const trustedCertExpiryWarning = 30 * 24 * time.Hour
+
+// reconcileTrustedCerts generates the trusted certificate bootstrap bundle from the ConfigMaps and Secrets
+// referenced in spec.app.trustedCerts and reports when each certificate expires.
+func reconcileTrustedCerts(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) error {
+	certs := map[string]*x509.Certificate{}
+	for _, tc := range gw.Spec.App.TrustedCerts {
+		data, err := trustedCertData(r, ctx, gw, tc)
+		if err != nil {
+			r.Log.Error(err, "Failed to retrieve trusted certificate", "Name", gw.Name, "Namespace", gw.Namespace, "TrustedCert", tc.Name)
+			return err
+		}
+		cert, err := util.ParseCertificatePEM(data)
+		if err != nil {
+			err = fmt.Errorf("trusted certificate %s: %w", tc.Name, err)
+			r.Log.Error(err, "Failed to parse trusted certificate", "Name", gw.Name, "Namespace", gw.Namespace, "TrustedCert", tc.Name)
+			return err
+		}
+		certs[tc.Name] = cert
+	}
+
+	bundle, err := util.BuildTrustedCertBundle(gw.Spec.App.TrustedCerts, certs)
+	if err != nil {
+		r.Log.Error(err, "Failed to build trusted certificate bundle", "Name", gw.Name, "Namespace", gw.Namespace)
+		return err
+	}
+
+	err = reconcileBundleConfigMap(r, ctx, gw, config.NewBundleConfigMap(gw, gw.Name+"-trusted-cert-bundle", "trusted-certs.bundle", bundle))
+	if err != nil {
+		return err
+	}
+
+	statuses := []securityv1.TrustedCertStatus{}
+	expiring := []string{}
+	for _, tc := range gw.Spec.App.TrustedCerts {
+		cert := certs[tc.Name]
+		status := securityv1.TrustedCertStatus{
+			Name:     tc.Name,
+			Subject:  cert.Subject.String(),
+			NotAfter: metav1.NewTime(cert.NotAfter),
+			Expiring: time.Until(cert.NotAfter) < trustedCertExpiryWarning,
+		}
+		if status.Expiring {
+			expiring = append(expiring, tc.Name)
+		}
+		statuses = append(statuses, status)
+	}
+
+	changed := !reflect.DeepEqual(statuses, gw.Status.TrustedCerts)
+	gw.Status.TrustedCerts = statuses
+	if len(expiring) > 0 {
+		message := fmt.Sprintf("%s expire within %d days, see status.trustedCerts", strings.Join(expiring, ", "), int(trustedCertExpiryWarning.Hours()/24))
+		changed = setGatewayCondition(gw, trustedCertsExpiringCondition, corev1.ConditionTrue, "Expiring", message) || changed
+	} else {
+		changed = setGatewayCondition(gw, trustedCertsExpiringCondition, corev1.ConditionFalse, "Valid", "no trusted certificates expire soon") || changed
+	}
+	if changed {
+		if err := r.Client.Status().Update(ctx, gw); err != nil {
+			r.Log.Error(err, "Failed to update trusted certificate status", "Name", gw.Name, "Namespace", gw.Namespace)
+			return err
+		}
+	}
+	return nil
+}
+
+// trustedCertData reads the PEM certificate of a trusted certificate from its ConfigMap or Secret
+func trustedCertData(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway, tc securityv1.TrustedCert) ([]byte, error) {
+	if (tc.ConfigMapName == "") == (tc.SecretName == "") {
+		return nil, fmt.Errorf("trusted certificate %s needs one of configMapName or secretName", tc.Name)
+	}
+	return keyData(r, ctx, gw, tc.ConfigMapName, tc.SecretName, tc.Key)
+}
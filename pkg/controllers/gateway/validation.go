@@ -0,0 +1,156 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/graphman"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// bundlesValidCondition reports whether the bundles delivered to the Gateway passed validation
+const bundlesValidCondition = "BundlesValid"
+
+// validateBundleFiles validates Restman (.bundle) and Graphman (.json) bundles, with their placeholders resolved
+// by t, and returns an error for each problem found. Other files are only checked for unresolved placeholders.
+func validateBundleFiles(source string, files map[string][]byte, t *bundleTemplate) []securityv1.BundleError {
+	errs := []securityv1.BundleError{}
+	for name, data := range files {
+		data, unresolved := t.resolve(data, util.BundleEscaper(name))
+		errs = append(errs, t.bundleErrors(source, name, unresolved)...)
+		var problems []string
+		switch {
+		case strings.HasSuffix(name, ".bundle"):
+			problems = util.ValidateBundle(data)
+		case strings.HasSuffix(name, ".json"):
+			problems = graphman.ValidateBundle(data)
+		}
+		for _, p := range problems {
+			errs = append(errs, securityv1.BundleError{Source: source, File: name, Error: p})
+		}
+	}
+	sortBundleErrors(errs)
+	return errs
+}
+
+// repositoryBundleFiles returns the bundles under bundleDirectory at commit that the method delivers
+func repositoryBundleFiles(repo *git.Repository, commit *object.Commit, bundleDirectory string, method string) (map[string][]byte, error) {
+	suffix := ".bundle"
+	if method == "graphman" {
+		suffix = ".json"
+	}
+	changes, err := util.GetBundleChanges(repo, commit, bundleDirectory, "", suffix)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	for _, c := range changes {
+		files[c.Name] = c.To
+	}
+	return files, nil
+}
+
+// validateConfigMapBundles validates the bundles in the ConfigMaps mounted into the Gateway pods and the Graphman
+// ConfigMaps applied to them. It returns false if any are invalid, in which case the Deployment isn't updated.
+func validateConfigMapBundles(r *GatewayReconciler, ctx context.Context, gw *securityv1.Gateway) (bool, error) {
+	t, err := getBundleTemplate(r, ctx, gw)
+	if err != nil {
+		return false, err
+	}
+
+	errs := []securityv1.BundleError{}
+	for _, b := range gw.Spec.App.Bundle {
+		if b.Type != "configMap" {
+			continue
+		}
+		cm := &corev1.ConfigMap{}
+		err := r.Get(ctx, types.NamespacedName{Name: b.Name, Namespace: gw.Namespace}, cm)
+		if err != nil {
+			r.Log.Error(err, "Failed to retrieve bundle ConfigMap", "Name", gw.Name, "Namespace", gw.Namespace, "ConfigMap", b.Name)
+			return false, err
+		}
+		files := map[string][]byte{}
+		for k, v := range cm.Data {
+			files[k] = []byte(v)
+		}
+		for k, v := range cm.BinaryData {
+			files[k] = v
+		}
+		errs = append(errs, validateBundleFiles("configmaps/"+b.Name, files, t)...)
+	}
+
+	if gw.Spec.App.Management.Graphman.Enabled {
+		for _, name := range gw.Spec.App.Management.Graphman.ConfigMaps {
+			cm := &corev1.ConfigMap{}
+			err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: gw.Namespace}, cm)
+			if err != nil {
+				r.Log.Error(err, "Failed to retrieve graphman ConfigMap", "Name", gw.Name, "Namespace", gw.Namespace, "ConfigMap", name)
+				return false, err
+			}
+			for k, v := range cm.Data {
+				data, unresolved := t.resolve([]byte(v), util.EscapeJSON)
+				errs = append(errs, t.bundleErrors("configmaps/"+name, k, unresolved)...)
+				for _, p := range graphman.ValidateBundle(data) {
+					errs = append(errs, securityv1.BundleError{Source: "configmaps/" + name, File: k, Error: p})
+				}
+			}
+		}
+	}
+	sortBundleErrors(errs)
+
+	if setBundleErrors(gw, "configmaps", errs) {
+		if err := r.Client.Status().Update(ctx, gw); err != nil {
+			r.Log.Error(err, "Failed to update bundle validation status", "Name", gw.Name, "Namespace", gw.Namespace)
+			return false, err
+		}
+	}
+	if len(errs) > 0 {
+		r.Log.Info("Bundle ConfigMaps are invalid, the Deployment won't be updated", "Name", gw.Name, "Namespace", gw.Namespace, "Errors", len(errs))
+		return false, nil
+	}
+	return true, nil
+}
+
+// setBundleErrors replaces the errors of the sources validated by stage, the stage itself or stage/<name>,
+// and updates the BundlesValid condition. It returns true if the status changed.
+func setBundleErrors(gw *securityv1.Gateway, stage string, errs []securityv1.BundleError) bool {
+	bundleErrors := []securityv1.BundleError{}
+	for _, e := range gw.Status.BundleErrors {
+		if e.Source != stage && !strings.HasPrefix(e.Source, stage+"/") {
+			bundleErrors = append(bundleErrors, e)
+		}
+	}
+	bundleErrors = append(bundleErrors, errs...)
+	sortBundleErrors(bundleErrors)
+
+	changed := len(bundleErrors) != len(gw.Status.BundleErrors)
+	for i := 0; !changed && i < len(bundleErrors); i++ {
+		changed = bundleErrors[i] != gw.Status.BundleErrors[i]
+	}
+	gw.Status.BundleErrors = bundleErrors
+	if len(bundleErrors) == 0 {
+		gw.Status.BundleErrors = nil
+		return setGatewayCondition(gw, bundlesValidCondition, corev1.ConditionTrue, "Validated", "bundles are valid") || changed
+	}
+	message := fmt.Sprintf("%d problems found in %s, see status.bundleErrors", len(bundleErrors), bundleErrors[0].Source+"/"+bundleErrors[0].File)
+	if len(bundleErrors) == 1 {
+		message = fmt.Sprintf("%s/%s: %s", bundleErrors[0].Source, bundleErrors[0].File, bundleErrors[0].Error)
+	}
+	return setGatewayCondition(gw, bundlesValidCondition, corev1.ConditionFalse, "Invalid", message) || changed
+}
+
+func sortBundleErrors(errs []securityv1.BundleError) {
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Source != errs[j].Source {
+			return errs[i].Source < errs[j].Source
+		}
+		return errs[i].File < errs[j].File
+	})
+}
@@ -0,0 +1,291 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repository
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+	reposync "github.com/Layer7-Community/layer7-operator/pkg/gateway/repository"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/util"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// readyCondition reports whether the repository could be fetched
+const readyCondition = "Ready"
+
+// storageSecretKey is the key of the repository archive in the storage Secret
+const storageSecretKey = util.RepositoryStorageKey
+
+// maxStorageSize keeps the storage Secret under the 1MiB Secret limit
+const maxStorageSize = 900 << 10
+
+// RepositoryReconciler reconciles a Repository object
+type RepositoryReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	Poller *reposync.Poller
+	Cache  *util.RepositoryCache
+}
+
+// //+kubebuilder:rbac:groups=security.brcmlabs.com,namespace=default,resources=repositories,verbs=get;list;watch;create;update;patch;delete
+// //+kubebuilder:rbac:groups=security.brcmlabs.com,namespace=default,resources=repositories/status,verbs=get;update;patch
+// //+kubebuilder:rbac:groups=security.brcmlabs.com,namespace=default,resources=repositories/finalizers,verbs=update
+// //+kubebuilder:rbac:groups=core,namespace=default,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile fetches the commit the Repository ref resolves to and stores the repository at that commit.
+// Archives are downloaded and imported as a commit. Repositories are reconciled again when the Poller
+// sees their ref or archive change.
+func (r *RepositoryReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	repo := &securityv1.Repository{}
+	err := r.Get(ctx, req.NamespacedName, repo)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			r.Poller.Forget(req.NamespacedName)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	creds, err := getCredentials(r, ctx, repo)
+	if err != nil {
+		return notReady(r, ctx, repo, "CredentialsUnavailable", err)
+	}
+
+	ref := util.GitReference{Branch: repo.Spec.Branch, Tag: repo.Spec.Tag, Commit: repo.Spec.Commit}
+	if err := ref.Validate(); err != nil {
+		return notReady(r, ctx, repo, "CommitInvalid", err)
+	}
+	target := reposync.Target{URL: repo.Spec.URL, Ref: ref, Interval: time.Duration(repo.Spec.SyncInterval) * time.Second}
+	archive := repo.Spec.Type == "http" || repo.Spec.Type == "s3" || repo.Spec.Type == "oci"
+	if archive {
+		target.Ref = util.GitReference{}
+		target.Archive = util.ArchiveReference{Type: repo.Spec.Type, Region: repo.Spec.Region, SHA256: repo.Spec.SHA256}
+	}
+	if repo.Spec.Type == "oci" {
+		target.Archive.Tag = repo.Spec.Tag
+		target.Archive.Digest = repo.Spec.Digest
+	}
+	if repo.Spec.SecretName != "" {
+		target.Secret = repo.Namespace + "/" + repo.Spec.SecretName
+	}
+	revision, err := r.Poller.Watch(req.NamespacedName, target, creds)
+	if err != nil {
+		if util.IsGitAuthError(err) {
+			return notReady(r, ctx, repo, "AuthenticationFailed", err)
+		}
+		return notReady(r, ctx, repo, "FetchFailed", err)
+	}
+
+	status := repo.Status.DeepCopy()
+	status.ObservedGeneration = repo.Generation
+	status.Ref = ref.Name()
+	commitId := revision
+	if archive {
+		status.Ref = repo.Spec.URL
+		if repo.Spec.Type == "oci" {
+			status.Ref = ociReference(repo)
+		}
+		commitId = repo.Status.CommitID
+		// archives are imported into the cache as commits, again if the cache no longer has them
+		if revision != repo.Status.Revision || !r.Cache.Has(repo.Spec.URL, commitId) {
+			// artifacts are pulled by the digest the tag resolved to, so the content matches the revision recorded
+			pull := target.Archive
+			if repo.Spec.Type == "oci" {
+				pull.Digest = revision
+			}
+			files, err := util.GetArchiveFiles(repo.Spec.URL, creds, pull)
+			if err != nil {
+				if util.IsGitAuthError(err) {
+					return notReady(r, ctx, repo, "AuthenticationFailed", err)
+				}
+				if errors.Is(err, util.ErrArchiveTooLarge) {
+					return notReady(r, ctx, repo, "ArchiveTooLarge", err)
+				}
+				return notReady(r, ctx, repo, "DownloadFailed", err)
+			}
+			_, commit, err := r.Cache.CommitFiles(repo.Spec.URL, files)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			commitId = commit.Hash.String()
+		}
+		status.Revision = revision
+	}
+	// the storage Secret is recreated if it's been removed
+	storageMissing := false
+	if repo.Status.StorageSecretName != "" {
+		err := r.Get(ctx, types.NamespacedName{Name: repo.Status.StorageSecretName, Namespace: repo.Namespace}, &corev1.Secret{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		storageMissing = k8serrors.IsNotFound(err)
+	}
+	if commitId != repo.Status.CommitID || storageMissing {
+		_, commit, err := r.Cache.Checkout(repo.Spec.URL, creds, commitId)
+		if err != nil {
+			return notReady(r, ctx, repo, "FetchFailed", err)
+		}
+		status.StorageSecretName, err = reconcileStorage(r, ctx, repo, commit)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		r.Log.Info("Fetched repository", "Name", repo.Name, "Namespace", repo.Namespace, "Commit", commitId)
+		status.CommitID = commitId
+		status.LastSyncTime = time.Now().UTC().Format(time.RFC3339)
+	}
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               readyCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Fetched",
+		Message:            "fetched commit " + commitId + " from " + status.Ref,
+		ObservedGeneration: repo.Generation,
+	})
+
+	if !reflect.DeepEqual(status, &repo.Status) {
+		repo.Status = *status
+		if err := r.Client.Status().Update(ctx, repo); err != nil {
+			r.Log.Error(err, "Failed to update repository status", "Name", repo.Name, "Namespace", repo.Namespace)
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// ociReference returns the artifact reference of an oci Repository, e.g. registry.example.com/bundles:1.0
+func ociReference(repo *securityv1.Repository) string {
+	switch {
+	case repo.Spec.Digest != "":
+		return repo.Spec.URL + "@" + repo.Spec.Digest
+	case repo.Spec.Tag != "":
+		return repo.Spec.URL + ":" + repo.Spec.Tag
+	}
+	return repo.Spec.URL + ":latest"
+}
+
+// notReady records why the repository couldn't be fetched and retries after a delay
+func notReady(r *RepositoryReconciler, ctx context.Context, repo *securityv1.Repository, reason string, err error) (ctrl.Result, error) {
+	r.Log.Error(err, "Failed to fetch repository", "Name", repo.Name, "Namespace", repo.Namespace, "Reason", reason)
+	curr := meta.FindStatusCondition(repo.Status.Conditions, readyCondition)
+	if curr == nil || curr.Status != metav1.ConditionFalse || curr.Reason != reason || curr.Message != err.Error() {
+		meta.SetStatusCondition(&repo.Status.Conditions, metav1.Condition{
+			Type:               readyCondition,
+			Status:             metav1.ConditionFalse,
+			Reason:             reason,
+			Message:            err.Error(),
+			ObservedGeneration: repo.Generation,
+		})
+		if err := r.Client.Status().Update(ctx, repo); err != nil {
+			r.Log.Error(err, "Failed to update repository status", "Name", repo.Name, "Namespace", repo.Namespace)
+		}
+	}
+	return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+}
+
+// reconcileStorage stores an archive of the repository at commit in a Secret owned by the Repository and
+// returns its name. Repositories that are too large for a Secret aren't stored and an empty name is returned.
+func reconcileStorage(r *RepositoryReconciler, ctx context.Context, repo *securityv1.Repository, commit *object.Commit) (string, error) {
+	name := repo.Name + "-repository"
+	archive, err := util.ArchiveCommit(commit)
+	if err != nil {
+		return "", err
+	}
+
+	curr := &corev1.Secret{}
+	err = r.Get(ctx, types.NamespacedName{Name: name, Namespace: repo.Namespace}, curr)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return "", err
+	}
+	exists := err == nil
+
+	if len(archive) > maxStorageSize {
+		r.Log.Info("Repository is too large to store in a Secret", "Name", repo.Name, "Namespace", repo.Namespace, "Size", len(archive))
+		if exists {
+			if err := r.Delete(ctx, curr); err != nil && !k8serrors.IsNotFound(err) {
+				return "", err
+			}
+		}
+		return "", nil
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: repo.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       repo.Name,
+				"app.kubernetes.io/managed-by": "layer7-operator",
+				"app.kubernetes.io/created-by": "layer7-operator",
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{storageSecretKey: archive},
+	}
+	if err := controllerutil.SetControllerReference(repo, secret, r.Scheme); err != nil {
+		return "", err
+	}
+
+	if !exists {
+		r.Log.Info("Creating repository storage Secret", "Name", repo.Name, "Namespace", repo.Namespace)
+		return name, r.Create(ctx, secret)
+	}
+	if !reflect.DeepEqual(curr.Data, secret.Data) {
+		secret.ResourceVersion = curr.ResourceVersion
+		r.Log.Info("Updating repository storage Secret", "Name", repo.Name, "Namespace", repo.Namespace)
+		return name, r.Update(ctx, secret)
+	}
+	return name, nil
+}
+
+// getCredentials reads the git credentials of the Repository from its Secret
+func getCredentials(r *RepositoryReconciler, ctx context.Context, repo *securityv1.Repository) (*util.GitCredentials, error) {
+	if repo.Spec.SecretName == "" {
+		return nil, nil
+	}
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: repo.Spec.SecretName, Namespace: repo.Namespace}, secret); err != nil {
+		return nil, err
+	}
+	return util.NewGitCredentials(secret.Data), nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RepositoryReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&securityv1.Repository{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&corev1.Secret{}).
+		Watches(r.Poller.Source(), &handler.EnqueueRequestForObject{}).
+		Complete(r)
+}
@@ -33,16 +33,8 @@ func NewConfigMap(gw *securityv1.Gateway, name string) *corev1.ConfigMap {
 			data["hazelcast-client.xml"] = `<hazelcast-client xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.hazelcast.com/schema/client-config http://www.hazelcast.com/schema/client-config/hazelcast-client-config-3.10.xsd" xmlns="http://www.hazelcast.com/schema/client-config"><instance-name>` + gw.Name + `-hazelcast-client</instance-name><network><cluster-members><address>` + gw.Spec.App.Hazelcast.Endpoint + `</address></cluster-members><connection-attempt-limit>10</connection-attempt-limit><redo-operation>true</redo-operation></network><connection-strategy async-start="false" reconnect-mode="ON" /></hazelcast-client>`
 			data["EXTRA_JAVA_ARGS"] = javaArgs + " -Dcogw.l7tech.server.extension.sharedCounterProvider=externalhazelcast -Dcogw.l7tech.server.extension.sharedKeyValueStoreProvider=externalhazelcast -Dcogw.l7tech.server.extension.sharedClusterInfoProvider=externalhazelcast"
 		}
-	case gw.Name + "-cwp-bundle":
-		props := map[string]string{}
-
-		for _, p := range gw.Spec.App.ClusterProperties.Properties {
-			props[p.Name] = p.Value
-		}
-		bundle, _ := util.BuildCWPBundle(props)
-		data["cwp.bundle"] = string(bundle)
 	case gw.Name + "-listen-port-bundle":
-		bundle, _ := util.BuildListenPortBundle(gw.Spec.App.ListenPorts.CipherSuites, gw.Spec.App.ListenPorts.TlsVersions)
+		bundle, _ := util.BuildListenPortBundle(gw.Spec.App.ListenPorts)
 		data["listen-ports.bundle"] = string(bundle)
 	}
 
@@ -61,9 +53,38 @@ func NewConfigMap(gw *securityv1.Gateway, name string) *corev1.ConfigMap {
 	return cmap
 }
 
-// func NewBundleConfigMap(gw *securityv1.Gateway, name string) *corev1.ConfigMap {
+// NewBundleConfigMap holds a bootstrap bundle generated by the operator under key
+func NewBundleConfigMap(gw *securityv1.Gateway, name string, key string, bundle []byte) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: gw.Namespace,
+			Labels:    util.DefaultLabels(gw),
+		},
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		Data: map[string]string{key: string(bundle)},
+	}
+}
 
-// }
+// NewResolvedBundleConfigMap holds a ConfigMap bundle with its template placeholders resolved
+func NewResolvedBundleConfigMap(gw *securityv1.Gateway, bundle string, data map[string]string, binaryData map[string][]byte) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.ResolvedBundleName(gw, bundle),
+			Namespace: gw.Namespace,
+			Labels:    util.DefaultLabels(gw),
+		},
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		Data:       data,
+		BinaryData: binaryData,
+	}
+}
 
 func setJVMHeapSize(gw *securityv1.Gateway) string {
 	var jvmHeap string
@@ -86,3 +107,19 @@ func setJVMHeapSize(gw *securityv1.Gateway) string {
 
 	return jvmHeap
 }
+
+// NewRepositoryBundleConfigMap holds the bundles of a Repository mounted into the Gateway pods
+func NewRepositoryBundleConfigMap(gw *securityv1.Gateway, repository string, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.RepositoryBundleName(gw, repository),
+			Namespace: gw.Namespace,
+			Labels:    util.DefaultLabels(gw),
+		},
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		Data: data,
+	}
+}
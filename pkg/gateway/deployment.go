@@ -1,6 +1,8 @@
 package gateway
 
 import (
+	"fmt"
+
 	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
 
 	"github.com/Layer7-Community/layer7-operator/pkg/gateway/util"
@@ -32,6 +34,22 @@ func NewDeployment(gw *securityv1.Gateway) *appsv1.Deployment {
 		}
 	}
 
+	for _, lp := range gw.Spec.App.ListenPorts.Custom {
+		exists := false
+		for _, p := range ports {
+			if p.ContainerPort == lp.Port {
+				exists = true
+			}
+		}
+		if !exists {
+			ports = append(ports, corev1.ContainerPort{
+				Name:          util.ListenPortName(lp),
+				ContainerPort: lp.Port,
+				Protocol:      corev1.ProtocolTCP,
+			})
+		}
+	}
+
 	secretName := gw.Name
 	if gw.Spec.App.Management.SecretName != "" {
 		secretName = gw.Spec.App.Management.SecretName
@@ -89,11 +107,13 @@ func NewDeployment(gw *securityv1.Gateway) *appsv1.Deployment {
 			Optional:             &optional,
 		}}
 
-		vs = corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
-			LocalObjectReference: corev1.LocalObjectReference{Name: gw.Name + "-cwp-bundle"},
-			DefaultMode:          &defaultMode,
-			Optional:             &optional,
-		}}
+		if util.ClusterPropertiesFromSecrets(gw) {
+			vs = corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+				SecretName:  gw.Name + "-cwp-bundle",
+				DefaultMode: &defaultMode,
+				Optional:    &optional,
+			}}
+		}
 
 		volumes = append(volumes, corev1.Volume{
 			Name:         gw.Name + "-cwp-bundle",
@@ -101,7 +121,7 @@ func NewDeployment(gw *securityv1.Gateway) *appsv1.Deployment {
 		})
 	}
 
-	if gw.Spec.App.ListenPorts.Harden {
+	if gw.Spec.App.ListenPorts.Harden || len(gw.Spec.App.ListenPorts.Custom) > 0 {
 		volumeMounts = append(volumeMounts, corev1.VolumeMount{
 			Name:      gw.Name + "-listen-port-bundle",
 			MountPath: "/opt/SecureSpan/Gateway/node/default/etc/bootstrap/bundle/" + gw.Name + "-listen-port-bundle",
@@ -128,7 +148,72 @@ func NewDeployment(gw *securityv1.Gateway) *appsv1.Deployment {
 		})
 	}
 
-	if gw.Spec.App.Management.Restman.Enabled {
+	if len(util.SecurePasswords(gw)) > 0 {
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      gw.Name + "-secure-password-bundle",
+			MountPath: "/opt/SecureSpan/Gateway/node/default/etc/bootstrap/bundle/" + gw.Name + "-secure-password-bundle",
+		})
+
+		volumes = append(volumes, corev1.Volume{
+			Name: gw.Name + "-secure-password-bundle",
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+				SecretName:  gw.Name + "-secure-password-bundle",
+				DefaultMode: &defaultMode,
+				Optional:    &optional,
+			}},
+		})
+	}
+
+	if len(gw.Spec.App.PrivateKeys) > 0 {
+		// private keys are in a Graphman bundle, Restman bundles only carry them encrypted
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      gw.Name + "-private-key-bundle",
+			MountPath: "/opt/SecureSpan/Gateway/node/default/etc/bootstrap/bundle/graphman/" + gw.Name + "-private-key-bundle",
+		})
+
+		volumes = append(volumes, corev1.Volume{
+			Name: gw.Name + "-private-key-bundle",
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+				SecretName:  gw.Name + "-private-key-bundle",
+				DefaultMode: &defaultMode,
+				Optional:    &optional,
+			}},
+		})
+	}
+
+	if len(gw.Spec.App.TrustedCerts) > 0 {
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      gw.Name + "-trusted-cert-bundle",
+			MountPath: "/opt/SecureSpan/Gateway/node/default/etc/bootstrap/bundle/" + gw.Name + "-trusted-cert-bundle",
+		})
+
+		volumes = append(volumes, corev1.Volume{
+			Name: gw.Name + "-trusted-cert-bundle",
+			VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: gw.Name + "-trusted-cert-bundle"},
+				DefaultMode:          &defaultMode,
+				Optional:             &optional,
+			}},
+		})
+	}
+
+	if len(gw.Spec.App.JDBCConnections) > 0 {
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      gw.Name + "-jdbc-bundle",
+			MountPath: "/opt/SecureSpan/Gateway/node/default/etc/bootstrap/bundle/" + gw.Name + "-jdbc-bundle",
+		})
+
+		volumes = append(volumes, corev1.Volume{
+			Name: gw.Name + "-jdbc-bundle",
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+				SecretName:  gw.Name + "-jdbc-bundle",
+				DefaultMode: &defaultMode,
+				Optional:    &optional,
+			}},
+		})
+	}
+
+	if gw.Spec.App.Management.Restman.Enabled || (gw.Spec.App.Repository.Enabled && gw.Spec.App.Repository.Method == "restman") {
 		volumeMounts = append(volumeMounts, corev1.VolumeMount{
 			Name:      "restman",
 			MountPath: "/opt/SecureSpan/Gateway/node/default/etc/bootstrap/services/restman",
@@ -140,7 +225,7 @@ func NewDeployment(gw *securityv1.Gateway) *appsv1.Deployment {
 		})
 	}
 
-	if gw.Spec.App.Management.Graphman.Enabled {
+	if gw.Spec.App.Management.Graphman.Enabled || (gw.Spec.App.Repository.Enabled && gw.Spec.App.Repository.Method == "graphman") {
 		volumeMounts = append(volumeMounts, corev1.VolumeMount{
 			Name:      "graphman",
 			MountPath: "/opt/SecureSpan/Gateway/node/default/etc/bootstrap/services/graphman",
@@ -196,6 +281,18 @@ func NewDeployment(gw *securityv1.Gateway) *appsv1.Deployment {
 				}}
 			}
 
+			// templated bundles are mounted from the copy with their placeholders resolved
+			if gw.Spec.App.Templating.Enabled {
+				vs.ConfigMap.Name = util.ResolvedBundleName(gw, gw.Spec.App.Bundle[v].Name)
+				if util.TemplateValuesFromSecrets(gw) {
+					vs = corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+						SecretName:  util.ResolvedBundleName(gw, gw.Spec.App.Bundle[v].Name),
+						DefaultMode: vs.ConfigMap.DefaultMode,
+						Optional:    vs.ConfigMap.Optional,
+					}}
+				}
+			}
+
 			volumes = append(volumes, corev1.Volume{
 				Name:         gw.Spec.App.Bundle[v].Name,
 				VolumeSource: vs,
@@ -227,6 +324,39 @@ func NewDeployment(gw *securityv1.Gateway) *appsv1.Deployment {
 		}
 	}
 
+	// mounted repositories are numbered so the bootstrap loads them in the order they're referenced, the
+	// ConfigMap or Secret only exists once the Repository has a commit
+	mounted := 0
+	for _, ref := range util.RepositoryReferences(gw) {
+		if ref.Method != "mount" {
+			continue
+		}
+		volumeName := fmt.Sprintf("repository-%02d", mounted)
+		mounted++
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      volumeName,
+			MountPath: "/opt/SecureSpan/Gateway/node/default/etc/bootstrap/bundle/" + volumeName + "-" + ref.Name,
+		})
+		defaultMode := int32(420)
+		optional := true
+		vs := corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: util.RepositoryBundleName(gw, ref.Name)},
+			DefaultMode:          &defaultMode,
+			Optional:             &optional,
+		}}
+		if gw.Spec.App.Templating.Enabled && util.TemplateValuesFromSecrets(gw) {
+			vs = corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+				SecretName:  util.RepositoryBundleName(gw, ref.Name),
+				DefaultMode: &defaultMode,
+				Optional:    &optional,
+			}}
+		}
+		volumes = append(volumes, corev1.Volume{
+			Name:         volumeName,
+			VolumeSource: vs,
+		})
+	}
+
 	for vm := range gw.Spec.App.InitContainers {
 		volumeMounts = append(volumeMounts, gw.Spec.App.InitContainers[vm].VolumeMounts...)
 		for v := range gw.Spec.App.InitContainers[vm].VolumeMounts {
@@ -275,12 +405,47 @@ func NewDeployment(gw *securityv1.Gateway) *appsv1.Deployment {
 	if gw.Spec.App.Repository.Enabled && gw.Spec.App.Repository.Method == "init" {
 		init := gw.Spec.App.Repository.Init
 		env := []corev1.EnvVar{{Name: "GIT_REPO_URL", Value: gw.Spec.App.Repository.URL}, {Name: "BUNDLE_DIR", Value: gw.Spec.App.Repository.BundleDirectory}}
+		// pin new pods to the last commit that changed the bundle directory
+		if gw.Status.BundleCommitID != "" {
+			env = append(env, corev1.EnvVar{Name: "GIT_COMMIT", Value: gw.Status.BundleCommitID})
+		}
 		init.Env = append(init.Env, env...)
-		initContainers = append(initContainers, init)
 		volumeMounts = append(volumeMounts, init.VolumeMounts...)
 		for v := range init.VolumeMounts {
 			volumes = append(volumes, corev1.Volume{Name: init.VolumeMounts[v].Name, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}})
 		}
+
+		// credentials for private repositories are only given to the init container
+		if gw.Spec.App.Repository.SecretName != "" {
+			credentialsMode := int32(256)
+			optionalKey := true
+			secretKey := func(key string) *corev1.EnvVarSource {
+				return &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: gw.Spec.App.Repository.SecretName},
+					Key:                  key,
+					Optional:             &optionalKey,
+				}}
+			}
+			init.Env = append(init.Env, []corev1.EnvVar{
+				{Name: "GIT_USERNAME", ValueFrom: secretKey(util.GitUsernameKey)},
+				{Name: "GIT_PASSWORD", ValueFrom: secretKey(util.GitPasswordKey)},
+				{Name: "GIT_TOKEN", ValueFrom: secretKey(util.GitTokenKey)},
+				{Name: "GIT_SSH_COMMAND", Value: "ssh -i /etc/git-credentials/" + util.GitSSHPrivateKeyKey + " -o IdentitiesOnly=yes -o UserKnownHostsFile=/etc/git-credentials/" + util.GitKnownHostsKey + " -o StrictHostKeyChecking=yes"},
+			}...)
+			init.VolumeMounts = append(init.VolumeMounts, corev1.VolumeMount{
+				Name:      "git-credentials",
+				MountPath: "/etc/git-credentials",
+				ReadOnly:  true,
+			})
+			volumes = append(volumes, corev1.Volume{
+				Name: "git-credentials",
+				VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+					SecretName:  gw.Spec.App.Repository.SecretName,
+					DefaultMode: &credentialsMode,
+				}},
+			})
+		}
+		initContainers = append(initContainers, init)
 	}
 
 	gateway := corev1.Container{
@@ -368,7 +533,12 @@ func NewDeployment(gw *securityv1.Gateway) *appsv1.Deployment {
 	dep.Spec.Template.Labels = ls
 
 	if gw.Spec.App.Repository.Enabled {
-		dep.Spec.Template.Annotations = map[string]string{"commitId": gw.Status.CommitID}
+		commitId := gw.Status.BundleCommitID
+		switch gw.Spec.App.Repository.Method {
+		case "restman", "graphman":
+			commitId = gw.Status.RolloutCommitID
+		}
+		dep.Spec.Template.Annotations = map[string]string{"commitId": commitId}
 	}
 
 	if !gw.Spec.App.Autoscaling.Enabled {
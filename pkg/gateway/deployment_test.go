@@ -0,0 +1,91 @@
+package gateway
+
+import (
+	"testing"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// initContainer returns the repository init container of the Deployment for gw with its env by name, and
+// the pod volumes
+func initContainer(t *testing.T, gw *securityv1.Gateway) (corev1.Container, map[string]corev1.EnvVar, []corev1.Volume) {
+	dep := NewDeployment(gw)
+	for _, c := range dep.Spec.Template.Spec.InitContainers {
+		if c.Name != gw.Spec.App.Repository.Init.Name {
+			continue
+		}
+		env := map[string]corev1.EnvVar{}
+		for _, e := range c.Env {
+			env[e.Name] = e
+		}
+		return c, env, dep.Spec.Template.Spec.Volumes
+	}
+	t.Fatalf("expected the repository init container, got %v", dep.Spec.Template.Spec.InitContainers)
+	return corev1.Container{}, nil, nil
+}
+
+func TestRepositoryInitContainer(t *testing.T) {
+	gw := &securityv1.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "ssg", Namespace: "default"}}
+	gw.Spec.App.Repository = securityv1.GatewayRepository{
+		Enabled:         true,
+		Method:          "init",
+		URL:             "https://git.example.com/bundles.git",
+		BundleDirectory: "bundles",
+		Init:            corev1.Container{Name: "bundles", VolumeMounts: []corev1.VolumeMount{{Name: "bundles", MountPath: "/opt/bundles"}}},
+	}
+
+	c, env, _ := initContainer(t, gw)
+	if env["GIT_REPO_URL"].Value != gw.Spec.App.Repository.URL || env["BUNDLE_DIR"].Value != "bundles" {
+		t.Fatalf("unexpected env %v", c.Env)
+	}
+	if _, ok := env["GIT_TOKEN"]; ok || len(c.VolumeMounts) != 1 {
+		t.Fatalf("expected no credentials without a Secret, got %v %v", c.Env, c.VolumeMounts)
+	}
+	if _, ok := env["GIT_COMMIT"]; ok {
+		t.Fatalf("expected no commit before one is resolved, got %v", env["GIT_COMMIT"])
+	}
+
+	// new pods are pinned to the last commit that changed the bundle directory
+	gw.Status.BundleCommitID = "0123456789abcdef0123456789abcdef01234567"
+	if _, env, _ := initContainer(t, gw); env["GIT_COMMIT"].Value != gw.Status.BundleCommitID {
+		t.Fatalf("expected GIT_COMMIT to be the bundle commit, got %v", env["GIT_COMMIT"])
+	}
+
+	gw.Spec.App.Repository.SecretName = "git-credentials"
+	c, env, volumes := initContainer(t, gw)
+	for name, key := range map[string]string{"GIT_USERNAME": "username", "GIT_PASSWORD": "password", "GIT_TOKEN": "token"} {
+		ref := env[name].ValueFrom
+		if ref == nil || ref.SecretKeyRef == nil || ref.SecretKeyRef.Name != "git-credentials" || ref.SecretKeyRef.Key != key || !*ref.SecretKeyRef.Optional {
+			t.Fatalf("expected %s from the optional key %s, got %v", name, key, env[name])
+		}
+	}
+	if env["GIT_SSH_COMMAND"].Value == "" {
+		t.Fatal("expected GIT_SSH_COMMAND to be set")
+	}
+	mounted := false
+	for _, m := range c.VolumeMounts {
+		if m.Name == "git-credentials" && m.MountPath == "/etc/git-credentials" && m.ReadOnly {
+			mounted = true
+		}
+	}
+	secret := false
+	for _, v := range volumes {
+		if v.Name == "git-credentials" && v.Secret != nil && v.Secret.SecretName == "git-credentials" {
+			secret = true
+		}
+	}
+	if !mounted || !secret {
+		t.Fatalf("expected the credentials Secret to be mounted, got %v %v", c.VolumeMounts, volumes)
+	}
+
+	// the Gateway container doesn't receive the credentials
+	for _, c := range NewDeployment(gw).Spec.Template.Spec.Containers {
+		for _, m := range c.VolumeMounts {
+			if m.Name == "git-credentials" {
+				t.Fatalf("expected the credentials to only be mounted in the init container, got %v", c.VolumeMounts)
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package graphman
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/management"
+)
+
+// Options configures how a Client connects to a Gateway
+type Options = management.Options
+
+// Client calls the Graphman API of a single Gateway
+type Client struct {
+	url    string
+	client *management.Client
+}
+
+// Error holds the errors returned in a GraphQL response
+type Error struct {
+	Messages []string
+}
+
+func (e *Error) Error() string {
+	return "graphman: " + strings.Join(e.Messages, "; ")
+}
+
+// StatusError is a Graphman request that failed with an HTTP status other than 200
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("graphman: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+type request struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// NewClient returns a Client for the Graphman endpoint at url (e.g. https://10.0.0.1:9443/graphman)
+func NewClient(url string, username string, password string, opts Options) (*Client, error) {
+	c, err := management.NewClient(username, password, opts)
+	if err != nil {
+		return nil, fmt.Errorf("graphman: %w", err)
+	}
+	return &Client{url: url, client: c}, nil
+}
+
+// Query sends a GraphQL query or mutation and decodes the response data into data. Server errors are only
+// retried for queries. GraphQL errors are returned as an *Error after data has been decoded.
+func (c *Client) Query(ctx context.Context, query string, variables map[string]interface{}, data interface{}) error {
+	reqBytes, err := json.Marshal(request{Query: query, Variables: variables})
+	if err != nil {
+		return err
+	}
+
+	do := c.client.DoIdempotent
+	if strings.HasPrefix(strings.TrimSpace(query), "mutation") {
+		do = c.client.Do
+	}
+	status, respBytes, err := do(ctx, http.MethodPost, c.url, "application/json", reqBytes)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return &StatusError{StatusCode: status, Body: strings.TrimSpace(string(respBytes))}
+	}
+
+	resp := response{}
+	if err := json.Unmarshal(respBytes, &resp); err != nil {
+		return err
+	}
+	if len(resp.Data) > 0 && string(resp.Data) != "null" && data != nil {
+		if err := json.Unmarshal(resp.Data, data); err != nil {
+			return err
+		}
+	}
+	if len(resp.Errors) > 0 {
+		e := &Error{}
+		for _, m := range resp.Errors {
+			e.Messages = append(e.Messages, m.Message)
+		}
+		return e
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package graphman_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/graphman"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/graphman/graphmantest"
+)
+
+func newClient(t *testing.T, s *graphmantest.Server, password string, retries int) *graphman.Client {
+	c, err := graphman.NewClient(s.GraphmanURL(), "admin", password, graphman.Options{CACert: s.CACert(), Retries: retries, RetryWait: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestEntities(t *testing.T) {
+	s := graphmantest.NewServer("admin", "7layer")
+	defer s.Close()
+	c := newClient(t, s, "7layer", 0)
+	ctx := context.Background()
+
+	result, err := c.SetServices(ctx, graphman.Service{Name: "echo", ResolutionPath: "/echo", Enabled: true, Policy: &graphman.PolicyXML{Xml: "<wsp:Policy/>"}})
+	if err != nil || result.Err() != nil || result.Applied != 1 {
+		t.Fatalf("unexpected result %v: %v", result, err)
+	}
+	service, err := c.GetService(ctx, "/echo")
+	if err != nil || service == nil || service.Policy == nil || service.Policy.Xml != "<wsp:Policy/>" {
+		t.Fatalf("unexpected service %v: %v", service, err)
+	}
+
+	if _, err := c.SetSecrets(ctx, graphman.Secret{Name: "db", Secret: "s3cr3t", SecretType: "PASSWORD"}); err != nil {
+		t.Fatal(err)
+	}
+	secrets, err := c.ListSecrets(ctx)
+	if err != nil || len(secrets) != 1 || secrets[0].Secret != "" {
+		t.Fatalf("unexpected secrets %v: %v", secrets, err)
+	}
+
+	if _, err := c.SetClusterProperties(ctx, graphman.ClusterProperty{Name: "cwp.one", Value: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	summary, err := c.Summary(ctx)
+	if err != nil || len(summary["services"]) != 1 || len(summary["clusterProperties"]) != 1 || len(summary["secrets"]) != 1 {
+		t.Fatalf("unexpected summary %v: %v", summary, err)
+	}
+
+	export, err := c.Export(ctx)
+	if err != nil || len(export) != 3 {
+		t.Fatalf("unexpected export %v: %v", export, err)
+	}
+
+	result, err = c.DeleteServices(ctx, "/echo")
+	if err != nil || result.Applied != 1 {
+		t.Fatalf("unexpected result %v: %v", result, err)
+	}
+	service, err = c.GetService(ctx, "/echo")
+	if err != nil || service != nil {
+		t.Fatalf("expected service to be deleted, got %v: %v", service, err)
+	}
+}
+
+func TestInstallBundle(t *testing.T) {
+	s := graphmantest.NewServer("admin", "7layer")
+	defer s.Close()
+	s.Reject("policies", "broken", "invalid policy xml")
+	c := newClient(t, s, "7layer", 0)
+
+	bundle, err := graphman.ParseBundle([]byte(`{
+		"properties": {"meta": {}},
+		"clusterProperties": [{"name": "cwp.one", "value": "1"}],
+		"policies": [{"name": "ok", "policy": {"xml": "<wsp:Policy/>"}}, {"name": "broken", "policy": {"xml": "<"}}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := c.Install(context.Background(), bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Applied != 2 || result.Failed != 1 || result.Err() == nil {
+		t.Fatalf("unexpected result %v", result)
+	}
+	if len(s.Entities("policies")) != 1 {
+		t.Fatalf("expected 1 policy, got %v", s.Entities("policies"))
+	}
+}
+
+func TestErrors(t *testing.T) {
+	s := graphmantest.NewServer("admin", "7layer")
+	defer s.Close()
+	ctx := context.Background()
+
+	var statusErr *graphman.StatusError
+	_, err := newClient(t, s, "wrong", 0).ListPolicies(ctx)
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected authentication error, got %v", err)
+	}
+
+	s.FailRequests(1, http.StatusBadGateway)
+	if _, err := newClient(t, s, "7layer", 1).ListPolicies(ctx); err != nil {
+		t.Fatalf("expected retry to succeed, got %v", err)
+	}
+
+	s.FailRequests(1, http.StatusBadGateway)
+	_, err = newClient(t, s, "7layer", 1).Install(ctx, graphman.Bundle{"clusterProperties": {{"name": "cwp.one", "value": "1"}}})
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected mutations not to be retried after a server error, got %v", err)
+	}
+
+	var graphqlErr *graphman.Error
+	err = newClient(t, s, "7layer", 0).Query(ctx, "query { unknownThing { name } }", nil, nil)
+	if !errors.As(err, &graphqlErr) {
+		t.Fatalf("expected graphql error, got %v", err)
+	}
+}
+
+func TestBundleEntities(t *testing.T) {
+	bundle, err := graphman.ParseBundle([]byte(`{
+		"clusterProperties": [{"name": "cwp.one", "value": "1"}, {"name": "cwp.two", "value": "2"}],
+		"services": [{"name": "echo", "resolutionPath": "/echo"}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entities := bundle.Entities()
+	if len(entities) != 3 || entities["services//echo"]["name"] != "echo" {
+		t.Fatalf("unexpected entities %v", entities)
+	}
+
+	filtered := bundle.Filter(func(key string) bool { return key != "clusterProperties/cwp.two" })
+	filtered.Add("clusterProperties/cwp.three", map[string]interface{}{"name": "cwp.three", "value": "3"})
+	if len(filtered["clusterProperties"]) != 2 || len(filtered["services"]) != 1 || filtered["clusterProperties"][1]["name"] != "cwp.three" {
+		t.Fatalf("unexpected filtered bundle %v", filtered)
+	}
+}
+
+func TestValidateBundle(t *testing.T) {
+	if problems := graphman.ValidateBundle([]byte(`{"clusterProperties": [{"name": "cwp.one", "value": "1"}]}`)); len(problems) > 0 {
+		t.Fatalf("unexpected problems %v", problems)
+	}
+	problems := graphman.ValidateBundle([]byte(`{
+		"clusterProperties": [{"name": "cwp.one", "value": "1"}, {"name": "cwp.one", "value": "2"}],
+		"services": [{"name": "echo"}]
+	}`))
+	if len(problems) != 2 {
+		t.Fatalf("expected a duplicate and a missing key, got %v", problems)
+	}
+	if problems := graphman.ValidateBundle([]byte(`{"services": [`)); len(problems) != 1 {
+		t.Fatalf("expected a parse error, got %v", problems)
+	}
+	if problems := graphman.ValidateBundle([]byte(`{"trustedCerts": [{"name": "backend", "certBase64": "MIIB"}]}`)); len(problems) > 0 {
+		t.Fatalf("expected trusted certs to be keyed by name, got %v", problems)
+	}
+}
+
+func TestRemoved(t *testing.T) {
+	previous := graphman.Keys(graphman.Bundle{
+		"clusterProperties": {{"name": "cwp.one", "value": "1"}, {"name": "cwp.two", "value": "2"}},
+		"trustedCerts":      {{"name": "backend", "certBase64": "MIIB"}},
+	})
+	if !reflect.DeepEqual(previous["trustedCerts"], []map[string]interface{}{{"name": "backend"}}) {
+		t.Fatalf("unexpected keys %v", previous)
+	}
+
+	current := graphman.Keys(graphman.Bundle{"clusterProperties": {{"name": "cwp.one", "value": "1"}}})
+	removed := graphman.Removed(previous, current)
+	expected := graphman.Bundle{"clusterProperties": {{"name": "cwp.two"}}, "trustedCerts": {{"name": "backend"}}}
+	if !reflect.DeepEqual(removed, expected) {
+		t.Fatalf("unexpected removed entities %v", removed)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	current := graphman.Bundle{
+		"clusterProperties": {{"goid": "1", "name": "cwp.same", "value": "1"}, {"goid": "2", "name": "cwp.changed", "value": "1"}, {"goid": "3", "name": "cwp.removed", "value": "1"}},
+		"secrets":           {{"name": "password", "secretType": "PASSWORD"}},
+	}
+	install := []graphman.Bundle{{
+		"clusterProperties": {{"name": "cwp.same", "value": "1"}, {"name": "cwp.changed", "value": "2"}, {"name": "cwp.new", "value": "1"}},
+		"secrets":           {{"name": "password", "secretType": "PASSWORD", "secret": "s3cr3t"}},
+	}}
+	remove := []graphman.Bundle{{"clusterProperties": {{"name": "cwp.removed"}, {"name": "cwp.missing"}}}}
+
+	changes := graphman.Diff(current, install, remove)
+	expected := []graphman.Change{
+		{Action: "update", Type: "clusterProperties", Key: "cwp.changed"},
+		{Action: "create", Type: "clusterProperties", Key: "cwp.new"},
+		{Action: "delete", Type: "clusterProperties", Key: "cwp.removed"},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("unexpected changes %v", changes)
+	}
+}
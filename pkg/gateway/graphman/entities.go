@@ -0,0 +1,302 @@
+package graphman
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// PolicyXML is the policy attached to a policy or service
+type PolicyXML struct {
+	Xml string `json:"xml"`
+}
+
+// Service is a published Gateway service
+type Service struct {
+	Goid           string     `json:"goid,omitempty"`
+	Guid           string     `json:"guid,omitempty"`
+	Name           string     `json:"name"`
+	ResolutionPath string     `json:"resolutionPath"`
+	Enabled        bool       `json:"enabled"`
+	FolderPath     string     `json:"folderPath,omitempty"`
+	MethodsAllowed []string   `json:"methodsAllowed,omitempty"`
+	ServiceType    string     `json:"serviceType,omitempty"`
+	Policy         *PolicyXML `json:"policy,omitempty"`
+	Checksum       string     `json:"checksum,omitempty"`
+}
+
+// Policy is a Gateway policy fragment or global policy
+type Policy struct {
+	Goid       string     `json:"goid,omitempty"`
+	Guid       string     `json:"guid,omitempty"`
+	Name       string     `json:"name"`
+	FolderPath string     `json:"folderPath,omitempty"`
+	PolicyType string     `json:"policyType,omitempty"`
+	Policy     *PolicyXML `json:"policy,omitempty"`
+	Checksum   string     `json:"checksum,omitempty"`
+}
+
+// ClusterProperty is a Gateway cluster-wide property
+type ClusterProperty struct {
+	Goid           string `json:"goid,omitempty"`
+	Name           string `json:"name"`
+	Value          string `json:"value"`
+	Description    string `json:"description,omitempty"`
+	HiddenProperty bool   `json:"hiddenProperty,omitempty"`
+	Checksum       string `json:"checksum,omitempty"`
+}
+
+// Key is a private key and certificate chain in the Gateway keystore
+type Key struct {
+	Alias      string   `json:"alias"`
+	KeystoreId string   `json:"keystoreId,omitempty"`
+	KeyType    string   `json:"keyType,omitempty"`
+	SubjectDn  string   `json:"subjectDn,omitempty"`
+	Pem        string   `json:"pem,omitempty"`
+	CertChain  []string `json:"certChain,omitempty"`
+	UsageTypes []string `json:"usageTypes,omitempty"`
+	Checksum   string   `json:"checksum,omitempty"`
+}
+
+// TrustedCert is a certificate trusted by the Gateway
+type TrustedCert struct {
+	Name                      string   `json:"name"`
+	CertBase64                string   `json:"certBase64"`
+	ThumbprintSha1            string   `json:"thumbprintSha1,omitempty"`
+	VerifyHostname            bool     `json:"verifyHostname"`
+	TrustAnchor               bool     `json:"trustAnchor"`
+	TrustedFor                []string `json:"trustedFor,omitempty"`
+	RevocationCheckPolicyType string   `json:"revocationCheckPolicyType,omitempty"`
+	Checksum                  string   `json:"checksum,omitempty"`
+}
+
+// Secret is a Gateway secure password. The secret value is only sent, it is never returned by queries.
+type Secret struct {
+	Name                 string `json:"name"`
+	Description          string `json:"description,omitempty"`
+	Secret               string `json:"secret,omitempty"`
+	SecretType           string `json:"secretType,omitempty"`
+	VariableReferencable bool   `json:"variableReferencable"`
+	Checksum             string `json:"checksum,omitempty"`
+}
+
+// ListServices returns every published service
+func (c *Client) ListServices(ctx context.Context) ([]Service, error) {
+	services := []Service{}
+	if err := c.list(ctx, "services", &services); err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+// GetService returns the service published at resolutionPath, or nil if there isn't one
+func (c *Client) GetService(ctx context.Context, resolutionPath string) (*Service, error) {
+	service := &Service{}
+	if found, err := c.get(ctx, "services", resolutionPath, service); !found {
+		return nil, err
+	}
+	return service, nil
+}
+
+// SetServices creates or updates services
+func (c *Client) SetServices(ctx context.Context, services ...Service) (Result, error) {
+	return c.set(ctx, "services", services)
+}
+
+// DeleteServices deletes the services published at resolutionPaths
+func (c *Client) DeleteServices(ctx context.Context, resolutionPaths ...string) (Result, error) {
+	return c.delete(ctx, "services", resolutionPaths)
+}
+
+// ListPolicies returns every policy
+func (c *Client) ListPolicies(ctx context.Context) ([]Policy, error) {
+	policies := []Policy{}
+	if err := c.list(ctx, "policies", &policies); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// GetPolicy returns the named policy, or nil if it doesn't exist
+func (c *Client) GetPolicy(ctx context.Context, name string) (*Policy, error) {
+	policy := &Policy{}
+	if found, err := c.get(ctx, "policies", name, policy); !found {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// SetPolicies creates or updates policies
+func (c *Client) SetPolicies(ctx context.Context, policies ...Policy) (Result, error) {
+	return c.set(ctx, "policies", policies)
+}
+
+// DeletePolicies deletes the named policies
+func (c *Client) DeletePolicies(ctx context.Context, names ...string) (Result, error) {
+	return c.delete(ctx, "policies", names)
+}
+
+// ListClusterProperties returns every cluster property
+func (c *Client) ListClusterProperties(ctx context.Context) ([]ClusterProperty, error) {
+	cps := []ClusterProperty{}
+	if err := c.list(ctx, "clusterProperties", &cps); err != nil {
+		return nil, err
+	}
+	return cps, nil
+}
+
+// GetClusterProperty returns the named cluster property, or nil if it doesn't exist
+func (c *Client) GetClusterProperty(ctx context.Context, name string) (*ClusterProperty, error) {
+	cp := &ClusterProperty{}
+	if found, err := c.get(ctx, "clusterProperties", name, cp); !found {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// SetClusterProperties creates or updates cluster properties
+func (c *Client) SetClusterProperties(ctx context.Context, cps ...ClusterProperty) (Result, error) {
+	return c.set(ctx, "clusterProperties", cps)
+}
+
+// DeleteClusterProperties deletes the named cluster properties
+func (c *Client) DeleteClusterProperties(ctx context.Context, names ...string) (Result, error) {
+	return c.delete(ctx, "clusterProperties", names)
+}
+
+// ListKeys returns every private key
+func (c *Client) ListKeys(ctx context.Context) ([]Key, error) {
+	keys := []Key{}
+	if err := c.list(ctx, "keys", &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// GetKey returns the private key with alias, or nil if it doesn't exist
+func (c *Client) GetKey(ctx context.Context, alias string) (*Key, error) {
+	key := &Key{}
+	if found, err := c.get(ctx, "keys", alias, key); !found {
+		return nil, err
+	}
+	return key, nil
+}
+
+// SetKeys creates or updates private keys
+func (c *Client) SetKeys(ctx context.Context, keys ...Key) (Result, error) {
+	return c.set(ctx, "keys", keys)
+}
+
+// DeleteKeys deletes the private keys with aliases
+func (c *Client) DeleteKeys(ctx context.Context, aliases ...string) (Result, error) {
+	return c.delete(ctx, "keys", aliases)
+}
+
+// ListTrustedCerts returns every trusted certificate
+func (c *Client) ListTrustedCerts(ctx context.Context) ([]TrustedCert, error) {
+	certs := []TrustedCert{}
+	if err := c.list(ctx, "trustedCerts", &certs); err != nil {
+		return nil, err
+	}
+	return certs, nil
+}
+
+// GetTrustedCert returns the named trusted certificate, or nil if it doesn't exist
+func (c *Client) GetTrustedCert(ctx context.Context, name string) (*TrustedCert, error) {
+	cert := &TrustedCert{}
+	if found, err := c.get(ctx, "trustedCerts", name, cert); !found {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// SetTrustedCerts creates or updates trusted certificates
+func (c *Client) SetTrustedCerts(ctx context.Context, certs ...TrustedCert) (Result, error) {
+	return c.set(ctx, "trustedCerts", certs)
+}
+
+// DeleteTrustedCerts deletes the named trusted certificates
+func (c *Client) DeleteTrustedCerts(ctx context.Context, names ...string) (Result, error) {
+	return c.delete(ctx, "trustedCerts", names)
+}
+
+// ListSecrets returns every secure password, without their values
+func (c *Client) ListSecrets(ctx context.Context) ([]Secret, error) {
+	secrets := []Secret{}
+	if err := c.list(ctx, "secrets", &secrets); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// GetSecret returns the named secure password without its value, or nil if it doesn't exist
+func (c *Client) GetSecret(ctx context.Context, name string) (*Secret, error) {
+	secret := &Secret{}
+	if found, err := c.get(ctx, "secrets", name, secret); !found {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// SetSecrets creates or updates secure passwords
+func (c *Client) SetSecrets(ctx context.Context, secrets ...Secret) (Result, error) {
+	return c.set(ctx, "secrets", secrets)
+}
+
+// DeleteSecrets deletes the named secure passwords
+func (c *Client) DeleteSecrets(ctx context.Context, names ...string) (Result, error) {
+	return c.delete(ctx, "secrets", names)
+}
+
+func (c *Client) list(ctx context.Context, name string, out interface{}) error {
+	et, _ := lookupEntityType(name)
+	data := map[string]json.RawMessage{}
+	if err := c.Query(ctx, fmt.Sprintf("query list { %s { %s } }", et.Name, et.Fields), nil, &data); err != nil {
+		return err
+	}
+	if raw, ok := data[et.Name]; ok && string(raw) != "null" {
+		return json.Unmarshal(raw, out)
+	}
+	return nil
+}
+
+// get queries a single entity by its lookup key into out, returning false if it doesn't exist
+func (c *Client) get(ctx context.Context, name string, key string, out interface{}) (bool, error) {
+	et, _ := lookupEntityType(name)
+	data := map[string]json.RawMessage{}
+	query := fmt.Sprintf("query get($key: String!) { %s(%s: $key) { %s } }", et.GetQuery, et.GetArg, et.Fields)
+	if err := c.Query(ctx, query, map[string]interface{}{"key": key}, &data); err != nil {
+		return false, err
+	}
+	raw, ok := data[et.GetQuery]
+	if !ok || string(raw) == "null" {
+		return false, nil
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *Client) set(ctx context.Context, name string, entities interface{}) (Result, error) {
+	raw, err := json.Marshal(entities)
+	if err != nil {
+		return Result{}, err
+	}
+	bundle := Bundle{}
+	section := []map[string]interface{}{}
+	if err := json.Unmarshal(raw, &section); err != nil {
+		return Result{}, err
+	}
+	bundle[name] = section
+	return c.Install(ctx, bundle)
+}
+
+func (c *Client) delete(ctx context.Context, name string, keys []string) (Result, error) {
+	et, _ := lookupEntityType(name)
+	bundle := Bundle{}
+	for _, k := range keys {
+		bundle[name] = append(bundle[name], map[string]interface{}{et.Key: k})
+	}
+	return c.Delete(ctx, bundle)
+}
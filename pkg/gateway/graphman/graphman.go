@@ -0,0 +1,377 @@
+package graphman
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// entityType describes how a Graphman bundle section is queried, installed and deleted
+type entityType struct {
+	Name           string
+	InputType      string
+	SetMutation    string
+	DeleteMutation string
+	DeleteArg      string
+	Key            string
+	GetQuery       string
+	GetArg         string
+	Fields         string
+}
+
+// entityTypes are listed in install order, dependencies first. Deletes run in reverse.
+var entityTypes = []entityType{
+	{Name: "keys", InputType: "KeyInput", SetMutation: "setKeys", DeleteMutation: "deleteKeys", DeleteArg: "aliases", Key: "alias",
+		GetQuery: "keyByAlias", GetArg: "alias", Fields: "alias keystoreId keyType subjectDn certChain usageTypes checksum"},
+	{Name: "trustedCerts", InputType: "TrustedCertInput", SetMutation: "setTrustedCerts", DeleteMutation: "deleteTrustedCerts", DeleteArg: "names", Key: "name",
+		GetQuery: "trustedCertByName", GetArg: "name", Fields: "name certBase64 thumbprintSha1 verifyHostname trustAnchor trustedFor revocationCheckPolicyType checksum"},
+	{Name: "secrets", InputType: "SecretInput", SetMutation: "setSecrets", DeleteMutation: "deleteSecrets", DeleteArg: "names", Key: "name",
+		GetQuery: "secretByName", GetArg: "name", Fields: "name description secretType variableReferencable checksum"},
+	{Name: "clusterProperties", InputType: "ClusterPropertyInput", SetMutation: "setClusterProperties", DeleteMutation: "deleteClusterProperties", DeleteArg: "names", Key: "name",
+		GetQuery: "clusterPropertyByName", GetArg: "name", Fields: "goid name value description hiddenProperty checksum"},
+	{Name: "policies", InputType: "PolicyInput", SetMutation: "setPolicies", DeleteMutation: "deletePolicies", DeleteArg: "names", Key: "name",
+		GetQuery: "policyByName", GetArg: "name", Fields: "goid guid name folderPath policyType policy { xml } checksum"},
+	{Name: "services", InputType: "ServiceInput", SetMutation: "setServices", DeleteMutation: "deleteServices", DeleteArg: "resolutionPaths", Key: "resolutionPath",
+		GetQuery: "serviceByResolutionPath", GetArg: "resolutionPath", Fields: "goid guid name resolutionPath enabled folderPath methodsAllowed serviceType policy { xml } checksum"},
+}
+
+// Bundle is a Graphman bundle, a set of entities keyed by entity type
+type Bundle map[string][]map[string]interface{}
+
+// Result summarises the outcome of a Graphman mutation
+type Result struct {
+	Applied int
+	Failed  int
+	Errors  []string
+}
+
+// Add combines the outcome of another mutation into r
+func (r *Result) Add(other Result) {
+	r.Applied = r.Applied + other.Applied
+	r.Failed = r.Failed + other.Failed
+	r.Errors = append(r.Errors, other.Errors...)
+}
+
+// Err returns an error describing any failures recorded in r
+func (r Result) Err() error {
+	if r.Failed == 0 && len(r.Errors) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(r.Errors, "; "))
+}
+
+type payload struct {
+	DetailedStatus []struct {
+		Status      string `json:"status"`
+		Description string `json:"description"`
+	} `json:"detailedStatus"`
+}
+
+// ParseBundle parses a Graphman JSON bundle, rejecting entity types that can't be applied
+func ParseBundle(data []byte) (Bundle, error) {
+	sections := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return nil, err
+	}
+
+	bundle := Bundle{}
+	for name, section := range sections {
+		// bundle properties describe the export and aren't applied
+		if name == "properties" {
+			continue
+		}
+		if _, ok := lookupEntityType(name); !ok {
+			return nil, fmt.Errorf("unsupported entity type %s", name)
+		}
+		entities := []map[string]interface{}{}
+		if err := json.Unmarshal(section, &entities); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		bundle[name] = entities
+	}
+	return bundle, nil
+}
+
+// ValidateBundle checks that a Graphman bundle parses and that every entity has a key that no other
+// entity of the same type in the bundle uses. It returns a description of each problem found.
+func ValidateBundle(data []byte) []string {
+	bundle, err := ParseBundle(data)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	problems := []string{}
+	for _, et := range entityTypes {
+		keys := map[string]bool{}
+		for i, e := range bundle[et.Name] {
+			key, ok := e[et.Key].(string)
+			if !ok || key == "" {
+				problems = append(problems, fmt.Sprintf("%s[%d] has no %s", et.Name, i, et.Key))
+				continue
+			}
+			if keys[key] {
+				problems = append(problems, fmt.Sprintf("duplicate %s %s", et.Name, key))
+			}
+			keys[key] = true
+		}
+	}
+	return problems
+}
+
+// Removed returns the entities in from that are no longer present in to
+func Removed(from Bundle, to Bundle) Bundle {
+	removed := Bundle{}
+	for _, et := range entityTypes {
+		keys := map[string]bool{}
+		for _, e := range to[et.Name] {
+			keys[fmt.Sprint(e[et.Key])] = true
+		}
+		for _, e := range from[et.Name] {
+			if !keys[fmt.Sprint(e[et.Key])] {
+				removed[et.Name] = append(removed[et.Name], e)
+			}
+		}
+	}
+	return removed
+}
+
+// Keys returns the entities of bundles with only their key, enough to find the entities a later bundle removes
+func Keys(bundles ...Bundle) Bundle {
+	keys := Bundle{}
+	for _, et := range entityTypes {
+		for _, b := range bundles {
+			for _, e := range b[et.Name] {
+				keys[et.Name] = append(keys[et.Name], map[string]interface{}{et.Key: e[et.Key]})
+			}
+		}
+	}
+	return keys
+}
+
+// Entities returns the entities in the bundle keyed by entity type and key, e.g. clusterProperties/cluster.hostname
+func (b Bundle) Entities() map[string]map[string]interface{} {
+	entities := map[string]map[string]interface{}{}
+	for _, et := range entityTypes {
+		for _, e := range b[et.Name] {
+			entities[et.Name+"/"+fmt.Sprint(e[et.Key])] = e
+		}
+	}
+	return entities
+}
+
+// Filter returns the entities in the bundle whose key, as returned by Entities, keep returns true for
+func (b Bundle) Filter(keep func(key string) bool) Bundle {
+	filtered := Bundle{}
+	for _, et := range entityTypes {
+		for _, e := range b[et.Name] {
+			if keep(et.Name + "/" + fmt.Sprint(e[et.Key])) {
+				filtered[et.Name] = append(filtered[et.Name], e)
+			}
+		}
+	}
+	return filtered
+}
+
+// Add adds an entity keyed as returned by Entities to the bundle
+func (b Bundle) Add(key string, entity map[string]interface{}) {
+	name := strings.SplitN(key, "/", 2)[0]
+	b[name] = append(b[name], entity)
+}
+
+// Change is an entity that applying bundles would create, update or delete on a Gateway
+type Change struct {
+	Action string
+	Type   string
+	Key    string
+}
+
+// diffIgnoredFields are assigned by the Gateway and don't describe a change
+var diffIgnoredFields = map[string]bool{"goid": true, "guid": true, "checksum": true}
+
+// Diff returns the changes installing the install bundles, then deleting the entities in the remove bundles,
+// would make to a Gateway whose entities are current (see Export). Entities are compared on the fields both
+// have, fields the Gateway doesn't return such as secret values aren't compared. Changes are returned in
+// install order by type, then by key.
+func Diff(current Bundle, install []Bundle, remove []Bundle) []Change {
+	existing := current.Entities()
+	installed := map[string]map[string]interface{}{}
+	for _, b := range install {
+		for key, e := range b.Entities() {
+			installed[key] = e
+		}
+	}
+	removed := map[string]bool{}
+	for _, b := range remove {
+		for key := range b.Entities() {
+			removed[key] = true
+		}
+	}
+
+	changes := []Change{}
+	for _, et := range entityTypes {
+		keys := []string{}
+		for key := range installed {
+			if strings.HasPrefix(key, et.Name+"/") && !removed[key] {
+				keys = append(keys, key)
+			}
+		}
+		for key := range removed {
+			if strings.HasPrefix(key, et.Name+"/") && existing[key] != nil {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			change := Change{Type: et.Name, Key: strings.TrimPrefix(key, et.Name+"/")}
+			switch {
+			case removed[key]:
+				change.Action = "delete"
+			case existing[key] == nil:
+				change.Action = "create"
+			case !sameEntity(existing[key], installed[key]):
+				change.Action = "update"
+			default:
+				continue
+			}
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+func sameEntity(current map[string]interface{}, desired map[string]interface{}) bool {
+	for field, value := range desired {
+		currentValue, ok := current[field]
+		if !ok || diffIgnoredFields[field] {
+			continue
+		}
+		if !reflect.DeepEqual(currentValue, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Install sends bundle to the Graphman endpoint as a single mutation that sets every entity it contains
+func (c *Client) Install(ctx context.Context, bundle Bundle) (Result, error) {
+	var params, fields []string
+	variables := map[string]interface{}{}
+	for _, et := range entityTypes {
+		entities := bundle[et.Name]
+		if len(entities) == 0 {
+			continue
+		}
+		params = append(params, fmt.Sprintf("$%s: [%s!]!", et.Name, et.InputType))
+		fields = append(fields, fmt.Sprintf("%s(input: $%s) { detailedStatus { status description } }", et.SetMutation, et.Name))
+		variables[et.Name] = entities
+	}
+	if len(fields) == 0 {
+		return Result{}, nil
+	}
+
+	query := "mutation install(" + strings.Join(params, ", ") + ") { " + strings.Join(fields, " ") + " }"
+	return c.mutate(ctx, query, variables)
+}
+
+// Delete sends a mutation to the Graphman endpoint that deletes every entity in bundle
+func (c *Client) Delete(ctx context.Context, bundle Bundle) (Result, error) {
+	var params, fields []string
+	variables := map[string]interface{}{}
+	for i := len(entityTypes) - 1; i >= 0; i-- {
+		et := entityTypes[i]
+		keys := []string{}
+		for _, e := range bundle[et.Name] {
+			keys = append(keys, fmt.Sprint(e[et.Key]))
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		sort.Strings(keys)
+		params = append(params, fmt.Sprintf("$%s: [String!]!", et.Name))
+		fields = append(fields, fmt.Sprintf("%s(%s: $%s) { detailedStatus { status description } }", et.DeleteMutation, et.DeleteArg, et.Name))
+		variables[et.Name] = keys
+	}
+	if len(fields) == 0 {
+		return Result{}, nil
+	}
+
+	query := "mutation delete(" + strings.Join(params, ", ") + ") { " + strings.Join(fields, " ") + " }"
+	return c.mutate(ctx, query, variables)
+}
+
+// Export returns every entity of the managed types as a bundle
+func (c *Client) Export(ctx context.Context) (Bundle, error) {
+	fields := []string{}
+	for _, et := range entityTypes {
+		fields = append(fields, et.Name+" { "+et.Fields+" }")
+	}
+	data := Bundle{}
+	if err := c.Query(ctx, "query export { "+strings.Join(fields, " ")+" }", nil, &data); err != nil {
+		return nil, err
+	}
+	for name, entities := range data {
+		if len(entities) == 0 {
+			delete(data, name)
+		}
+	}
+	return data, nil
+}
+
+// Summary returns the keys of every entity of the managed types, keyed by entity type
+func (c *Client) Summary(ctx context.Context) (map[string][]string, error) {
+	fields := []string{}
+	for _, et := range entityTypes {
+		fields = append(fields, et.Name+" { "+et.Key+" }")
+	}
+	data := Bundle{}
+	if err := c.Query(ctx, "query summary { "+strings.Join(fields, " ")+" }", nil, &data); err != nil {
+		return nil, err
+	}
+
+	summary := map[string][]string{}
+	for _, et := range entityTypes {
+		for _, e := range data[et.Name] {
+			summary[et.Name] = append(summary[et.Name], fmt.Sprint(e[et.Key]))
+		}
+		sort.Strings(summary[et.Name])
+	}
+	return summary, nil
+}
+
+func (c *Client) mutate(ctx context.Context, query string, variables map[string]interface{}) (Result, error) {
+	data := map[string]payload{}
+	err := c.Query(ctx, query, variables, &data)
+	var graphqlErr *Error
+	if err != nil && !errors.As(err, &graphqlErr) {
+		return Result{}, err
+	}
+
+	result := Result{}
+	if graphqlErr != nil {
+		result.Errors = append(result.Errors, graphqlErr.Messages...)
+	}
+	for _, p := range data {
+		for _, s := range p.DetailedStatus {
+			if s.Status == "ERROR" {
+				result.Failed++
+				result.Errors = append(result.Errors, s.Description)
+				continue
+			}
+			result.Applied++
+		}
+	}
+	return result, nil
+}
+
+func lookupEntityType(name string) (entityType, bool) {
+	for _, et := range entityTypes {
+		if et.Name == name {
+			return et, true
+		}
+	}
+	return entityType{}, false
+}
@@ -0,0 +1,323 @@
+// Package graphmantest provides an in-process Gateway Graphman API for tests
+package graphmantest
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type entityType struct {
+	key            string
+	setMutation    string
+	deleteMutation string
+	getQuery       string
+	getArg         string
+}
+
+var entityTypes = map[string]entityType{
+	"keys":              {key: "alias", setMutation: "setKeys", deleteMutation: "deleteKeys", getQuery: "keyByAlias", getArg: "alias"},
+	"trustedCerts":      {key: "name", setMutation: "setTrustedCerts", deleteMutation: "deleteTrustedCerts", getQuery: "trustedCertByName", getArg: "name"},
+	"secrets":           {key: "name", setMutation: "setSecrets", deleteMutation: "deleteSecrets", getQuery: "secretByName", getArg: "name"},
+	"clusterProperties": {key: "name", setMutation: "setClusterProperties", deleteMutation: "deleteClusterProperties", getQuery: "clusterPropertyByName", getArg: "name"},
+	"policies":          {key: "name", setMutation: "setPolicies", deleteMutation: "deletePolicies", getQuery: "policyByName", getArg: "name"},
+	"services":          {key: "resolutionPath", setMutation: "setServices", deleteMutation: "deleteServices", getQuery: "serviceByResolutionPath", getArg: "resolutionPath"},
+}
+
+// Server is a fake Gateway Graphman endpoint storing the entities it receives in memory.
+// It understands the top level fields of the queries and mutations sent by the graphman
+// package; selection sets are ignored and every stored field is returned.
+type Server struct {
+	*httptest.Server
+
+	Username string
+	Password string
+
+	mu            sync.Mutex
+	entities      map[string]map[string]map[string]interface{}
+	rejected      map[string]string
+	failures      int
+	failureStatus int
+	queries       []string
+}
+
+type field struct {
+	name string
+	args map[string]string
+}
+
+type status struct {
+	Status      string `json:"status"`
+	Description string `json:"description"`
+}
+
+// NewServer starts a TLS Server that accepts username and password
+func NewServer(username string, password string) *Server {
+	s := &Server{
+		Username: username,
+		Password: password,
+		entities: map[string]map[string]map[string]interface{}{},
+		rejected: map[string]string{},
+	}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// GraphmanURL returns the URL of the Graphman endpoint
+func (s *Server) GraphmanURL() string {
+	return s.URL + "/graphman"
+}
+
+// CACert returns the PEM encoded certificate of the server
+func (s *Server) CACert() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw})
+}
+
+// FailRequests makes the next n requests fail with statusCode
+func (s *Server) FailRequests(n int, statusCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = n
+	s.failureStatus = statusCode
+}
+
+// Reject makes mutations setting the entity of entityType with key fail with description
+func (s *Server) Reject(entityType string, key string, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejected[entityType+"/"+key] = description
+}
+
+// Put stores an entity on the server as if it had been set through a mutation
+func (s *Server) Put(entityType string, entity map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(entityType, entity)
+}
+
+// Entities returns the stored entities of entityType sorted by key
+func (s *Server) Entities(entityType string) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.list(entityType, true)
+}
+
+// Queries returns the queries received so far in order
+func (s *Server) Queries() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.queries...)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failures > 0 {
+		s.failures--
+		http.Error(w, "injected failure", s.failureStatus)
+		return
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok || username != s.Username || password != s.Password {
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost || r.URL.Path != "/graphman" {
+		http.NotFound(w, r)
+		return
+	}
+
+	req := struct {
+		Query     string                     `json:"query"`
+		Variables map[string]json.RawMessage `json:"variables"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.queries = append(s.queries, req.Query)
+
+	data := map[string]interface{}{}
+	errs := []map[string]string{}
+	fields, err := parseFields(req.Query)
+	if err != nil {
+		errs = append(errs, map[string]string{"message": err.Error()})
+	}
+	for _, f := range fields {
+		value, err := s.resolve(f, req.Variables)
+		if err != nil {
+			errs = append(errs, map[string]string{"message": err.Error()})
+			continue
+		}
+		data[f.name] = value
+	}
+
+	resp := map[string]interface{}{"data": data}
+	if len(errs) > 0 {
+		resp["errors"] = errs
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (s *Server) resolve(f field, variables map[string]json.RawMessage) (interface{}, error) {
+	for name, et := range entityTypes {
+		switch f.name {
+		case name:
+			return s.list(name, false), nil
+		case et.getQuery:
+			key := ""
+			if err := json.Unmarshal(variables[f.args[et.getArg]], &key); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.name, err)
+			}
+			for _, e := range s.list(name, false) {
+				if fmt.Sprint(e[et.getArg]) == key {
+					return e, nil
+				}
+			}
+			return nil, nil
+		case et.setMutation:
+			input := []map[string]interface{}{}
+			if err := json.Unmarshal(variables[f.args["input"]], &input); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.name, err)
+			}
+			statuses := []status{}
+			for _, e := range input {
+				if name == "trustedCerts" && e["thumbprintSha1"] == nil {
+					e["thumbprintSha1"] = thumbprint(fmt.Sprint(e["certBase64"]))
+				}
+				key := fmt.Sprint(e[et.key])
+				if description, ok := s.rejected[name+"/"+key]; ok {
+					statuses = append(statuses, status{Status: "ERROR", Description: description})
+					continue
+				}
+				st := "CREATED"
+				if _, ok := s.entities[name][key]; ok {
+					st = "UPDATED"
+				}
+				s.put(name, e)
+				statuses = append(statuses, status{Status: st, Description: key})
+			}
+			return map[string]interface{}{"detailedStatus": statuses}, nil
+		case et.deleteMutation:
+			keys := []string{}
+			for _, v := range f.args {
+				if err := json.Unmarshal(variables[v], &keys); err != nil {
+					return nil, fmt.Errorf("%s: %w", f.name, err)
+				}
+			}
+			statuses := []status{}
+			for _, key := range keys {
+				if _, ok := s.entities[name][key]; !ok {
+					statuses = append(statuses, status{Status: "NONE", Description: key + " not found"})
+					continue
+				}
+				delete(s.entities[name], key)
+				statuses = append(statuses, status{Status: "DELETED", Description: key})
+			}
+			return map[string]interface{}{"detailedStatus": statuses}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown field %s", f.name)
+}
+
+func (s *Server) put(name string, entity map[string]interface{}) {
+	if s.entities[name] == nil {
+		s.entities[name] = map[string]map[string]interface{}{}
+	}
+	s.entities[name][fmt.Sprint(entity[entityTypes[name].key])] = entity
+}
+
+// list returns stored entities sorted by key. Secret values are only returned when withSecrets is set.
+func (s *Server) list(name string, withSecrets bool) []map[string]interface{} {
+	keys := []string{}
+	for k := range s.entities[name] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	entities := []map[string]interface{}{}
+	for _, k := range keys {
+		e := map[string]interface{}{}
+		for f, v := range s.entities[name][k] {
+			if !withSecrets && (f == "secret" || f == "pem" || f == "p12") {
+				continue
+			}
+			e[f] = v
+		}
+		entities = append(entities, e)
+	}
+	return entities
+}
+
+// parseFields returns the top level fields of a GraphQL operation along with the variable passed to each argument
+func parseFields(query string) ([]field, error) {
+	start := strings.Index(query, "{")
+	end := strings.LastIndex(query, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("invalid query")
+	}
+	body := query[start+1 : end]
+
+	fields := []field{}
+	for i := 0; i < len(body); {
+		c := body[i]
+		switch {
+		case c == ' ' || c == '\n' || c == '\t' || c == '\r' || c == ',':
+			i++
+		case c == '(':
+			close := strings.Index(body[i:], ")")
+			if close < 0 || len(fields) == 0 {
+				return nil, fmt.Errorf("invalid arguments")
+			}
+			for _, arg := range strings.Split(body[i+1:i+close], ",") {
+				parts := strings.SplitN(arg, ":", 2)
+				if len(parts) == 2 {
+					fields[len(fields)-1].args[strings.TrimSpace(parts[0])] = strings.TrimPrefix(strings.TrimSpace(parts[1]), "$")
+				}
+			}
+			i = i + close + 1
+		case c == '{':
+			depth := 0
+			for ; i < len(body); i++ {
+				if body[i] == '{' {
+					depth++
+				}
+				if body[i] == '}' {
+					depth--
+					if depth == 0 {
+						i++
+						break
+					}
+				}
+			}
+		default:
+			j := i
+			for j < len(body) && strings.IndexByte(" \n\t\r,({}", body[j]) < 0 {
+				j++
+			}
+			fields = append(fields, field{name: body[i:j], args: map[string]string{}})
+			i = j
+		}
+	}
+	return fields, nil
+}
+
+func thumbprint(certBase64 string) string {
+	der, err := base64.StdEncoding.DecodeString(certBase64)
+	if err != nil {
+		return ""
+	}
+	sum := sha1.Sum(der)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,152 @@
+package management
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Options configures how a Client connects to a Gateway management API
+type Options struct {
+	// CACert is a PEM encoded CA bundle used to verify the Gateway certificate
+	CACert []byte
+	// ServerName is the name verified in the Gateway certificate, defaults to the host of the request URL
+	ServerName string
+	// InsecureSkipVerify disables verification of the Gateway certificate when no CACert is set
+	InsecureSkipVerify bool
+	// Timeout limits each request, defaults to 30 seconds
+	Timeout time.Duration
+	// Retries is the number of times a request is retried after a connection error, or a 5xx or 429 response
+	// to a request without side effects
+	Retries int
+	// RetryWait is the delay before the first retry, doubling for each subsequent retry. Defaults to 1 second
+	RetryWait time.Duration
+}
+
+// Client sends authenticated requests to a Gateway management API. Clients with the same TLS settings share
+// a transport, so creating a Client for each request reuses the connections to a Gateway.
+type Client struct {
+	username   string
+	password   string
+	retries    int
+	retryWait  time.Duration
+	httpClient *http.Client
+}
+
+// transports are shared by clients with the same TLS settings, idle connections to removed pods are closed
+// after idleConnTimeout
+var (
+	transportsMu sync.Mutex
+	transports   = map[[sha256.Size]byte]*http.Transport{}
+)
+
+const idleConnTimeout = 90 * time.Second
+
+// NewClient returns a Client that authenticates with username and password
+func NewClient(username string, password string, opts Options) (*Client, error) {
+	t, err := transport(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	retryWait := opts.RetryWait
+	if retryWait == 0 {
+		retryWait = time.Second
+	}
+
+	return &Client{
+		username:   username,
+		password:   password,
+		retries:    opts.Retries,
+		retryWait:  retryWait,
+		httpClient: &http.Client{Timeout: timeout, Transport: t},
+	}, nil
+}
+
+// transport returns the transport shared by clients with the TLS settings of opts
+func transport(opts Options) (*http.Transport, error) {
+	key := sha256.Sum256([]byte(fmt.Sprintf("%t\n%s\n%s", opts.InsecureSkipVerify, opts.ServerName, opts.CACert)))
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	if t, ok := transports[key]; ok {
+		return t, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify, ServerName: opts.ServerName}
+	if len(opts.CACert) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(opts.CACert) {
+			return nil, errors.New("no valid certificates found in CA bundle")
+		}
+		tlsConfig = &tls.Config{RootCAs: pool, ServerName: opts.ServerName}
+	}
+	t := &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment, IdleConnTimeout: idleConnTimeout}
+	transports[key] = t
+	return t, nil
+}
+
+// Do sends a request to url and returns the status and body of the response. Connection errors are retried,
+// 5xx and 429 responses only for GET and HEAD requests since other requests may have been partly applied.
+// The last response is returned once the retries are used up.
+func (c *Client) Do(ctx context.Context, method string, url string, contentType string, body []byte) (int, []byte, error) {
+	return c.do(ctx, method, url, contentType, body, method == http.MethodGet || method == http.MethodHead)
+}
+
+// DoIdempotent sends a request like Do but retries 5xx and 429 responses whatever the method, for requests
+// without side effects such as Graphman queries
+func (c *Client) DoIdempotent(ctx context.Context, method string, url string, contentType string, body []byte) (int, []byte, error) {
+	return c.do(ctx, method, url, contentType, body, true)
+}
+
+func (c *Client) do(ctx context.Context, method string, url string, contentType string, body []byte, idempotent bool) (int, []byte, error) {
+	wait := c.retryWait
+	for attempt := 0; ; attempt++ {
+		status, respBody, retry, err := c.send(ctx, method, url, contentType, body, idempotent)
+		if !retry || attempt >= c.retries {
+			return status, respBody, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		wait = wait * 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, method string, url string, contentType string, body []byte, idempotent bool) (int, []byte, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, false, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.SetBasicAuth(c.username, c.password)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, idempotent, err
+	}
+	retry := idempotent && (resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests)
+	return resp.StatusCode, respBody, retry, nil
+}
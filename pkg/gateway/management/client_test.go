@@ -0,0 +1,70 @@
+package management
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient(t *testing.T) {
+	attempts := 0
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if username, password, _ := r.BasicAuth(); username != "admin" || password != "7layer" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer s.Close()
+	caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw})
+	opts := Options{CACert: caCert, Retries: 2, RetryWait: time.Millisecond}
+
+	c, err := NewClient("admin", "7layer", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, body, err := c.Do(context.Background(), http.MethodGet, s.URL, "", nil)
+	if err != nil || status != http.StatusOK || string(body) != "ok" || attempts != 3 {
+		t.Fatalf("expected the request to succeed on the third attempt, got %d %s after %d: %v", status, body, attempts, err)
+	}
+
+	// requests with side effects aren't sent again after a server error
+	attempts = 0
+	if status, _, err := c.Do(context.Background(), http.MethodPut, s.URL, "application/xml", []byte("<l7:Bundle/>")); err != nil || status != http.StatusServiceUnavailable || attempts != 1 {
+		t.Fatalf("expected the PUT to fail without retries, got %d after %d: %v", status, attempts, err)
+	}
+	attempts = 0
+	if status, _, err := c.DoIdempotent(context.Background(), http.MethodPost, s.URL, "application/json", []byte("{}")); err != nil || status != http.StatusOK || attempts != 3 {
+		t.Fatalf("expected the idempotent POST to succeed on the third attempt, got %d after %d: %v", status, attempts, err)
+	}
+
+	other, err := NewClient("admin", "wrong", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.httpClient.Transport != c.httpClient.Transport {
+		t.Fatal("expected clients with the same TLS settings to share a transport")
+	}
+	if status, _, err := other.Do(context.Background(), http.MethodGet, s.URL, "", nil); err != nil || status != http.StatusUnauthorized {
+		t.Fatalf("expected client errors to be returned without retries, got %d: %v", status, err)
+	}
+
+	insecure, err := NewClient("admin", "7layer", Options{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if insecure.httpClient.Transport == c.httpClient.Transport {
+		t.Fatal("expected clients with different TLS settings to use their own transport")
+	}
+	if _, err := NewClient("admin", "7layer", Options{CACert: []byte("not a certificate")}); err == nil {
+		t.Fatal("expected an error for a CA bundle without certificates")
+	}
+}
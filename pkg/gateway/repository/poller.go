@@ -0,0 +1,260 @@
+// Package repository polls Gateway repositories for new commits
+package repository
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/util"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// Target is a repository ref polled on behalf of one or more objects. Secret is the namespaced name of the
+// credentials so that objects only share results when they share access to the repository. Interval overrides
+// the Poller interval when set. Targets with an Archive type are archives that resolve to a revision of their
+// content rather than a commit.
+type Target struct {
+	URL      string
+	Ref      util.GitReference
+	Archive  util.ArchiveReference
+	Secret   string
+	Interval time.Duration
+}
+
+type target struct {
+	// mu is held while the target is being looked up so Gateways watching the same target wait for a single lookup
+	mu      sync.Mutex
+	polled  bool
+	creds   *util.GitCredentials
+	commit  string
+	err     error
+	next    time.Time
+	polling bool
+}
+
+// Poller periodically lists the refs of every watched repository target and enqueues the objects watching
+// a target when the commit it resolves to changes. Objects watching the same target share a single lookup.
+type Poller struct {
+	Interval time.Duration
+	Jitter   time.Duration
+	Log      logr.Logger
+
+	object   client.Object
+	mu       sync.Mutex
+	targets  map[Target]*target
+	watchers map[types.NamespacedName]Target
+	events   chan event.GenericEvent
+	cache    *util.RepositoryCache
+	// lookup is replaced in tests
+	lookup func(t Target, creds *util.GitCredentials) (string, error)
+}
+
+// NewPoller returns a Poller that looks up each target every interval plus up to jitter. Events are sent
+// for objects of the same kind as object, e.g. &securityv1.Gateway{}.
+func NewPoller(interval time.Duration, jitter time.Duration, object client.Object, log logr.Logger) *Poller {
+	return &Poller{
+		Interval: interval,
+		Jitter:   jitter,
+		Log:      log,
+		object:   object,
+		targets:  map[Target]*target{},
+		watchers: map[types.NamespacedName]Target{},
+		events:   make(chan event.GenericEvent, 1024),
+		lookup:   lookup,
+	}
+}
+
+// lookup returns the commit a git target resolves to or the revision of an archive target
+func lookup(t Target, creds *util.GitCredentials) (string, error) {
+	if t.Archive.Type != "" {
+		return util.GetArchiveRevision(t.URL, creds, t.Archive)
+	}
+	return util.GetLatestCommit(t.URL, creds, t.Ref)
+}
+
+// UseCache makes the poller prune the clones of repositories from the cache once none of its targets need them
+func (p *Poller) UseCache(c *util.RepositoryCache) {
+	p.cache = c
+	c.Use(p.urls)
+}
+
+// urls returns the URLs of the targets being polled
+func (p *Poller) urls() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	urls := []string{}
+	for t := range p.targets {
+		urls = append(urls, t.URL)
+	}
+	return urls
+}
+
+// Source returns the source of events for objects whose targets moved
+func (p *Poller) Source() source.Source {
+	return &source.Channel{Source: p.events}
+}
+
+// Watch registers the object for target and returns the commit the target last resolved to. The target
+// is looked up straight away if it hasn't been polled yet or if its credentials changed.
+func (p *Poller) Watch(name types.NamespacedName, t Target, creds *util.GitCredentials) (string, error) {
+	p.mu.Lock()
+	p.watchers[name] = t
+	tgt, ok := p.targets[t]
+	if !ok {
+		tgt = &target{}
+		p.targets[t] = tgt
+	}
+	p.mu.Unlock()
+
+	tgt.mu.Lock()
+	defer tgt.mu.Unlock()
+	if !tgt.polled || !reflect.DeepEqual(tgt.creds, creds) {
+		tgt.creds = creds
+		p.poll(t, tgt)
+	}
+	return tgt.commit, tgt.err
+}
+
+// Forget stops polling for the object. Targets that nothing watches are dropped on the next poll.
+func (p *Poller) Forget(name types.NamespacedName) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.watchers, name)
+}
+
+// Refresh makes the poller look up the object's target on its next tick and reports whether the object is watched
+func (p *Poller) Refresh(name types.NamespacedName) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t, ok := p.watchers[name]
+	if !ok {
+		return false
+	}
+	if tgt, ok := p.targets[t]; ok {
+		tgt.next = time.Time{}
+	}
+	return true
+}
+
+// Start polls targets until ctx is done
+func (p *Poller) Start(ctx context.Context) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			due, dropped := p.due()
+			for t, tgt := range due {
+				go p.refresh(ctx, t, tgt)
+			}
+			if dropped && p.cache != nil {
+				go p.prune()
+			}
+		}
+	}
+}
+
+// due removes targets that nothing watches anymore and returns the targets that should be looked up and
+// whether any were removed
+func (p *Poller) due() (map[Target]*target, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	watched := map[Target]bool{}
+	for _, t := range p.watchers {
+		watched[t] = true
+	}
+
+	now := time.Now()
+	due := map[Target]*target{}
+	dropped := false
+	for t, tgt := range p.targets {
+		if !watched[t] {
+			delete(p.targets, t)
+			dropped = true
+			continue
+		}
+		if tgt.polling || now.Before(tgt.next) {
+			continue
+		}
+		tgt.polling = true
+		due[t] = tgt
+	}
+	return due, dropped
+}
+
+// prune removes the cached repositories that no longer have a target
+func (p *Poller) prune() {
+	removed, err := p.cache.Prune()
+	if err != nil {
+		p.Log.Error(err, "Failed to prune repository cache")
+	}
+	if removed > 0 {
+		p.Log.Info("Pruned repository cache", "Removed", removed)
+	}
+}
+
+// refresh looks up a target and enqueues the objects watching it if the commit or lookup error changed
+func (p *Poller) refresh(ctx context.Context, t Target, tgt *target) {
+	tgt.mu.Lock()
+	commit, err := tgt.commit, tgt.err
+	p.poll(t, tgt)
+	moved := tgt.commit != commit || (tgt.err == nil) != (err == nil)
+	commit = tgt.commit
+	tgt.mu.Unlock()
+
+	p.mu.Lock()
+	tgt.polling = false
+	names := []types.NamespacedName{}
+	for name, wt := range p.watchers {
+		if wt == t {
+			names = append(names, name)
+		}
+	}
+	p.mu.Unlock()
+
+	if !moved {
+		return
+	}
+	p.Log.Info("Repository ref moved", "URL", t.URL, "Ref", t.Ref.Name(), "Commit", commit, "Watchers", len(names))
+	for _, name := range names {
+		obj := p.object.DeepCopyObject().(client.Object)
+		obj.SetName(name.Name)
+		obj.SetNamespace(name.Namespace)
+		select {
+		case p.events <- event.GenericEvent{Object: obj}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// poll looks up the target, tgt.mu must be held
+func (p *Poller) poll(t Target, tgt *target) {
+	tgt.commit, tgt.err = p.lookup(t, tgt.creds)
+	if tgt.err != nil {
+		p.Log.Error(tgt.err, "Failed to look up repository ref", "URL", t.URL, "Ref", t.Ref.Name())
+	}
+	tgt.polled = true
+
+	interval := p.Interval
+	if t.Interval > 0 {
+		interval = t.Interval
+	}
+	next := time.Now().Add(interval)
+	if p.Jitter > 0 {
+		next = next.Add(time.Duration(rand.Int63n(int64(p.Jitter))))
+	}
+	p.mu.Lock()
+	tgt.next = next
+	p.mu.Unlock()
+}
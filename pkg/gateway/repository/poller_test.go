@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/util"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type fakeRemote struct {
+	mu      sync.Mutex
+	commits map[string]string
+	lookups int
+}
+
+func (f *fakeRemote) lookup(t Target, creds *util.GitCredentials) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lookups++
+	commit, ok := f.commits[t.URL+"#"+t.Ref.Name()]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return commit, nil
+}
+
+func (f *fakeRemote) set(url string, ref util.GitReference, commit string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commits[url+"#"+ref.Name()] = commit
+}
+
+func (f *fakeRemote) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lookups
+}
+
+func TestPoller(t *testing.T) {
+	main := util.GitReference{Branch: "main"}
+	remote := &fakeRemote{commits: map[string]string{}}
+	remote.set("https://example.com/repo", main, "c1")
+
+	p := NewPoller(10*time.Millisecond, 0, &securityv1.Gateway{}, logr.Discard())
+	p.lookup = remote.lookup
+	cache := util.NewRepositoryCache(t.TempDir())
+	p.UseCache(cache)
+
+	target := Target{URL: "https://example.com/repo", Ref: main}
+	a := types.NamespacedName{Namespace: "default", Name: "a"}
+	b := types.NamespacedName{Namespace: "default", Name: "b"}
+	for _, gw := range []types.NamespacedName{a, b} {
+		commit, err := p.Watch(gw, target, nil)
+		if err != nil || commit != "c1" {
+			t.Fatalf("unexpected commit %s: %v", commit, err)
+		}
+	}
+	if remote.count() != 1 {
+		t.Fatalf("expected Gateways watching the same target to share a lookup, got %d lookups", remote.count())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Start(ctx)
+
+	// polling a ref that hasn't moved doesn't enqueue anything
+	time.Sleep(1500 * time.Millisecond)
+	select {
+	case e := <-p.events:
+		t.Fatalf("unexpected event for %s", e.Object.GetName())
+	default:
+	}
+
+	remote.set("https://example.com/repo", main, "c2")
+	enqueued := map[string]bool{}
+	timeout := time.After(5 * time.Second)
+	for len(enqueued) < 2 {
+		select {
+		case e := <-p.events:
+			enqueued[e.Object.GetName()] = true
+		case <-timeout:
+			t.Fatalf("expected both Gateways to be enqueued, got %v", enqueued)
+		}
+	}
+	if commit, _ := p.Watch(a, target, nil); commit != "c2" {
+		t.Fatalf("expected c2, got %s", commit)
+	}
+	_, cached, err := cache.CommitFiles(target.URL, map[string][]byte{"a.bundle": []byte("a")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.Forget(a)
+	p.Forget(b)
+	time.Sleep(1500 * time.Millisecond)
+	if cache.Has(target.URL, cached.Hash.String()) {
+		t.Fatal("expected the cached clone of an unwatched target to be pruned")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.targets) != 0 {
+		t.Fatalf("expected unwatched targets to be dropped, got %v", p.targets)
+	}
+}
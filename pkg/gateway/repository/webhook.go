@@ -0,0 +1,385 @@
+package repository
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/util"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WebhookSecretKey is the key in the webhook Secret holding the shared secret configured on the git server
+const WebhookSecretKey = "secret"
+
+// maxPayloadSize limits the size of webhook payloads that are read
+const maxPayloadSize = 5 << 20
+
+var errInvalidSignature = errors.New("invalid webhook signature")
+
+// push is a set of ref updates reported by a git server. URLs are every URL the repository can be cloned from.
+type push struct {
+	urls          []string
+	refs          []string
+	defaultBranch string
+}
+
+// parser validates a webhook request and returns the push it reports, or nil for events that aren't pushes
+type parser func(r *http.Request, body []byte, secret []byte) (*push, error)
+
+// Receiver serves push webhooks from git servers. Gateways and Repositories whose URL and ref match a push
+// are looked up by their Poller straight away instead of waiting for the next poll.
+type Receiver struct {
+	Addr string
+	// Client should be the manager's cached client, the operator's Role only covers the watched namespaces
+	Client client.Reader
+	// Secret holds the shared secret used to validate webhook signatures under WebhookSecretKey
+	Secret           types.NamespacedName
+	GatewayPoller    *Poller
+	RepositoryPoller *Poller
+	Log              logr.Logger
+}
+
+// Start serves webhooks until ctx is done
+func (rc *Receiver) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle("/webhooks/github", rc.handle(parseGitHub))
+	mux.Handle("/webhooks/gitlab", rc.handle(parseGitLab))
+	mux.Handle("/webhooks/bitbucket", rc.handle(parseBitbucket))
+	mux.Handle("/webhooks/generic", rc.handle(parseGeneric))
+	srv := &http.Server{Addr: rc.Addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	errs := make(chan error, 1)
+	go func() {
+		rc.Log.Info("Serving repository webhooks", "Addr", rc.Addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errs <- err
+		}
+		close(errs)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
+
+func (rc *Receiver) handle(parse parser) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxPayloadSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		secret := &corev1.Secret{}
+		if err := rc.Client.Get(r.Context(), rc.Secret, secret); err != nil {
+			rc.Log.Error(err, "Failed to retrieve webhook secret", "Name", rc.Secret.Name, "Namespace", rc.Secret.Namespace)
+			http.Error(w, "webhook secret unavailable", http.StatusInternalServerError)
+			return
+		}
+		if len(secret.Data[WebhookSecretKey]) == 0 {
+			http.Error(w, "webhook secret unavailable", http.StatusInternalServerError)
+			return
+		}
+
+		p, err := parse(r, body, secret.Data[WebhookSecretKey])
+		if err == errInvalidSignature {
+			rc.Log.Info("Rejected webhook with an invalid signature", "Path", r.URL.Path, "Remote", r.RemoteAddr)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if p == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		gateways, repositories, err := rc.matches(r.Context(), p)
+		if err != nil {
+			rc.Log.Error(err, "Failed to list webhook targets")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		refreshed := 0
+		for _, gw := range gateways {
+			if rc.GatewayPoller.Refresh(gw) {
+				refreshed++
+			}
+		}
+		for _, repo := range repositories {
+			if rc.RepositoryPoller.Refresh(repo) {
+				refreshed++
+			}
+		}
+		rc.Log.Info("Received push webhook", "Refs", p.refs, "Refreshed", refreshed)
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, "%d refreshed\n", refreshed)
+	})
+}
+
+// matches returns the Gateways and Repositories whose URL and ref were updated by the push
+func (rc *Receiver) matches(ctx context.Context, p *push) ([]types.NamespacedName, []types.NamespacedName, error) {
+	gwList := &securityv1.GatewayList{}
+	if err := rc.Client.List(ctx, gwList); err != nil {
+		return nil, nil, err
+	}
+	repoList := &securityv1.RepositoryList{}
+	if err := rc.Client.List(ctx, repoList); err != nil {
+		return nil, nil, err
+	}
+
+	gateways := []types.NamespacedName{}
+	for _, gw := range gwList.Items {
+		repo := gw.Spec.App.Repository
+		if repo.Enabled && p.matches(repo.URL, util.GitReference{Branch: repo.Branch, Tag: repo.Tag, Commit: repo.Commit}) {
+			gateways = append(gateways, types.NamespacedName{Name: gw.Name, Namespace: gw.Namespace})
+		}
+	}
+	repositories := []types.NamespacedName{}
+	for _, repo := range repoList.Items {
+		// archives aren't pushed to
+		if repo.Spec.Type != "" && repo.Spec.Type != "git" {
+			continue
+		}
+		if p.matches(repo.Spec.URL, util.GitReference{Branch: repo.Spec.Branch, Tag: repo.Spec.Tag, Commit: repo.Spec.Commit}) {
+			repositories = append(repositories, types.NamespacedName{Name: repo.Name, Namespace: repo.Namespace})
+		}
+	}
+	return gateways, repositories, nil
+}
+
+// matches returns true if the push is to the repository at url and may have moved ref
+func (p *push) matches(url string, ref util.GitReference) bool {
+	found := false
+	for _, u := range p.urls {
+		if normalizeURL(u) == normalizeURL(url) {
+			found = true
+		}
+	}
+	if !found {
+		return false
+	}
+	for _, pushed := range p.refs {
+		if refMatches(ref, pushed, p.defaultBranch) {
+			return true
+		}
+	}
+	return false
+}
+
+// refMatches returns true if pushing the pushed ref may have moved ref. Pinned commits never move and the
+// default branch matches any branch push when the git server doesn't report which branch is the default.
+func refMatches(ref util.GitReference, pushed string, defaultBranch string) bool {
+	switch {
+	case ref.Commit != "":
+		return false
+	case ref.Tag != "" || ref.Branch != "":
+		return ref.Name() == pushed
+	case defaultBranch != "":
+		return pushed == "refs/heads/"+defaultBranch
+	}
+	return strings.HasPrefix(pushed, "refs/heads/")
+}
+
+// normalizeURL reduces HTTPS, SSH and scp-like repository URLs to host/path so that the same repository
+// matches however it was configured
+func normalizeURL(u string) string {
+	u = strings.ToLower(strings.TrimSpace(u))
+	if i := strings.Index(u, "://"); i >= 0 {
+		u = u[i+3:]
+	} else if i := strings.Index(u, ":"); i >= 0 && !strings.Contains(u[:i], "/") {
+		// scp-like syntax, e.g. git@github.com:org/repo.git
+		u = u[:i] + "/" + u[i+1:]
+	}
+
+	host, path := u, ""
+	if i := strings.Index(u, "/"); i >= 0 {
+		host, path = u[:i], u[i:]
+	}
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	if i := strings.Index(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	path = strings.TrimSuffix(strings.TrimSuffix(path, "/"), ".git")
+	return host + path
+}
+
+// validHMAC checks a hex encoded HMAC-SHA256 signature of body, optionally prefixed with sha256=
+func validHMAC(signature string, body []byte, secret []byte) bool {
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || len(sig) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(sig, mac.Sum(nil))
+}
+
+func parseGitHub(r *http.Request, body []byte, secret []byte) (*push, error) {
+	if !validHMAC(r.Header.Get("X-Hub-Signature-256"), body, secret) {
+		return nil, errInvalidSignature
+	}
+	if r.Header.Get("X-GitHub-Event") != "push" {
+		return nil, nil
+	}
+	payload := struct {
+		Ref        string `json:"ref"`
+		Repository struct {
+			CloneURL      string `json:"clone_url"`
+			SSHURL        string `json:"ssh_url"`
+			HTMLURL       string `json:"html_url"`
+			DefaultBranch string `json:"default_branch"`
+		} `json:"repository"`
+	}{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	return &push{
+		urls:          []string{payload.Repository.CloneURL, payload.Repository.SSHURL, payload.Repository.HTMLURL},
+		refs:          []string{payload.Ref},
+		defaultBranch: payload.Repository.DefaultBranch,
+	}, nil
+}
+
+// parseGitLab validates the secret token GitLab sends with each request, GitLab doesn't sign payloads
+func parseGitLab(r *http.Request, body []byte, secret []byte) (*push, error) {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Gitlab-Token")), secret) != 1 {
+		return nil, errInvalidSignature
+	}
+	switch r.Header.Get("X-Gitlab-Event") {
+	case "Push Hook", "Tag Push Hook":
+	default:
+		return nil, nil
+	}
+	payload := struct {
+		Ref     string `json:"ref"`
+		Project struct {
+			GitHTTPURL    string `json:"git_http_url"`
+			GitSSHURL     string `json:"git_ssh_url"`
+			WebURL        string `json:"web_url"`
+			DefaultBranch string `json:"default_branch"`
+		} `json:"project"`
+	}{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	return &push{
+		urls:          []string{payload.Project.GitHTTPURL, payload.Project.GitSSHURL, payload.Project.WebURL},
+		refs:          []string{payload.Ref},
+		defaultBranch: payload.Project.DefaultBranch,
+	}, nil
+}
+
+// parseBitbucket handles Bitbucket Cloud repo:push and Bitbucket Server repo:refs_changed events
+func parseBitbucket(r *http.Request, body []byte, secret []byte) (*push, error) {
+	if !validHMAC(r.Header.Get("X-Hub-Signature"), body, secret) {
+		return nil, errInvalidSignature
+	}
+	payload := struct {
+		Push struct {
+			Changes []struct {
+				New *struct {
+					Type string `json:"type"`
+					Name string `json:"name"`
+				} `json:"new"`
+			} `json:"changes"`
+		} `json:"push"`
+		Changes []struct {
+			Ref struct {
+				ID string `json:"id"`
+			} `json:"ref"`
+		} `json:"changes"`
+		Repository struct {
+			FullName string `json:"full_name"`
+			Links    struct {
+				HTML struct {
+					Href string `json:"href"`
+				} `json:"html"`
+				Clone []struct {
+					Href string `json:"href"`
+				} `json:"clone"`
+			} `json:"links"`
+		} `json:"repository"`
+	}{}
+
+	switch r.Header.Get("X-Event-Key") {
+	case "repo:push":
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, err
+		}
+		p := &push{urls: []string{payload.Repository.Links.HTML.Href}}
+		for _, c := range payload.Push.Changes {
+			if c.New == nil {
+				continue
+			}
+			if c.New.Type == "tag" {
+				p.refs = append(p.refs, "refs/tags/"+c.New.Name)
+			} else {
+				p.refs = append(p.refs, "refs/heads/"+c.New.Name)
+			}
+		}
+		return p, nil
+	case "repo:refs_changed":
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, err
+		}
+		p := &push{}
+		for _, l := range payload.Repository.Links.Clone {
+			p.urls = append(p.urls, l.Href)
+		}
+		for _, c := range payload.Changes {
+			p.refs = append(p.refs, c.Ref.ID)
+		}
+		return p, nil
+	}
+	return nil, nil
+}
+
+// parseGeneric handles payloads of the form {"url": "...", "ref": "refs/heads/main"} signed like GitHub payloads
+func parseGeneric(r *http.Request, body []byte, secret []byte) (*push, error) {
+	if !validHMAC(r.Header.Get("X-Signature-256"), body, secret) {
+		return nil, errInvalidSignature
+	}
+	payload := struct {
+		URL string `json:"url"`
+		Ref string `json:"ref"`
+	}{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	if payload.URL == "" || payload.Ref == "" {
+		return nil, errors.New("url and ref are required")
+	}
+	ref := payload.Ref
+	if !strings.HasPrefix(ref, "refs/") {
+		ref = "refs/heads/" + ref
+	}
+	return &push{urls: []string{payload.URL}, refs: []string{ref}}, nil
+}
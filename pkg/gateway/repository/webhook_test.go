@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/util"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newGateway(name string, url string, branch string) *securityv1.Gateway {
+	gw := &securityv1.Gateway{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+	gw.Spec.App.Repository.Enabled = true
+	gw.Spec.App.Repository.URL = url
+	gw.Spec.App.Repository.Branch = branch
+	return gw
+}
+
+func sign(body string, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestReceiver(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	securityv1.AddToScheme(scheme)
+
+	gateways := []*securityv1.Gateway{
+		newGateway("https-main", "https://github.com/Example/Bundles.git", "main"),
+		newGateway("ssh-default", "git@github.com:example/bundles.git", ""),
+		newGateway("other-branch", "https://github.com/example/bundles", "dev"),
+		newGateway("other-repo", "https://github.com/example/other", "main"),
+	}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: "operator"}, Data: map[string][]byte{WebhookSecretKey: []byte("s3cr3t")}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret, gateways[0], gateways[1], gateways[2], gateways[3]).Build()
+
+	p := NewPoller(time.Hour, 0, &securityv1.Gateway{}, logr.Discard())
+	p.lookup = func(t Target, creds *util.GitCredentials) (string, error) { return "c1", nil }
+	for _, gw := range gateways {
+		name := types.NamespacedName{Name: gw.Name, Namespace: gw.Namespace}
+		p.Watch(name, Target{URL: gw.Spec.App.Repository.URL, Ref: util.GitReference{Branch: gw.Spec.App.Repository.Branch}}, nil)
+	}
+
+	rc := &Receiver{Client: c, Secret: types.NamespacedName{Name: "webhook", Namespace: "operator"}, GatewayPoller: p, RepositoryPoller: NewPoller(time.Hour, 0, &securityv1.Repository{}, logr.Discard()), Log: logr.Discard()}
+	body := `{"ref": "refs/heads/main", "repository": {"clone_url": "https://github.com/example/bundles.git", "ssh_url": "git@github.com:example/bundles.git", "default_branch": "main"}}`
+
+	send := func(signature string) int {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/github", strings.NewReader(body))
+		req.Header.Set("X-GitHub-Event", "push")
+		req.Header.Set("X-Hub-Signature-256", signature)
+		rec := httptest.NewRecorder()
+		rc.handle(parseGitHub).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := send(sign(body, "wrong")); code != http.StatusUnauthorized {
+		t.Fatalf("expected invalid signature to be rejected, got %d", code)
+	}
+	if code := send(sign(body, "s3cr3t")); code != http.StatusAccepted {
+		t.Fatalf("expected push to be accepted, got %d", code)
+	}
+
+	due, _ := p.due()
+	refreshed := map[string]bool{}
+	for target := range due {
+		refreshed[target.URL+"#"+target.Ref.Name()] = true
+	}
+	if len(due) != 2 || !refreshed["https://github.com/Example/Bundles.git#refs/heads/main"] || !refreshed["git@github.com:example/bundles.git#HEAD"] {
+		t.Fatalf("unexpected targets refreshed %v", refreshed)
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	for _, u := range []string{
+		"https://github.com/example/bundles",
+		"https://user@github.com/Example/bundles.git/",
+		"ssh://git@github.com:22/example/bundles.git",
+		"git@github.com:example/bundles.git",
+	} {
+		if normalizeURL(u) != "github.com/example/bundles" {
+			t.Fatalf("unexpected normalized url for %s: %s", u, normalizeURL(u))
+		}
+	}
+}
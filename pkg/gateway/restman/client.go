@@ -0,0 +1,48 @@
+package restman
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/management"
+)
+
+const basePath = "/restman/1.0"
+
+// Options configures how a Client connects to a Gateway
+type Options = management.Options
+
+// Client calls the Restman API of a single Gateway
+type Client struct {
+	baseURL string
+	client  *management.Client
+}
+
+// NewClient returns a Client for the Gateway at baseURL (e.g. https://10.0.0.1:9443)
+func NewClient(baseURL string, username string, password string, opts Options) (*Client, error) {
+	c, err := management.NewClient(username, password, opts)
+	if err != nil {
+		return nil, fmt.Errorf("restman: %w", err)
+	}
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/"), client: c}, nil
+}
+
+// do sends a request to path, retrying connection errors, and 5xx responses to GET requests. Responses
+// other than 2xx are returned as an *Error.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, contentType string, body []byte) ([]byte, error) {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u = u + "?" + query.Encode()
+	}
+
+	status, respBody, err := c.client.Do(ctx, method, u, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	if status < 200 || status > 299 {
+		return nil, parseError(method, u, status, respBody)
+	}
+	return respBody, nil
+}
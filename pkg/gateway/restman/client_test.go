@@ -0,0 +1,175 @@
+package restman_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/restman"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/restman/restmantest"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/util"
+)
+
+func newClient(t *testing.T, s *restmantest.Server, password string, retries int) *restman.Client {
+	c, err := restman.NewClient(s.URL, "admin", password, restman.Options{CACert: s.CACert(), Retries: retries, RetryWait: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestClusterProperties(t *testing.T) {
+	s := restmantest.NewServer("admin", "7layer")
+	defer s.Close()
+	c := newClient(t, s, "7layer", 0)
+	ctx := context.Background()
+
+	if err := c.SetClusterProperty(ctx, "cwp.one", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetClusterProperty(ctx, "cwp.one", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := s.ClusterProperty("cwp.one"); v != "2" {
+		t.Fatalf("expected cwp.one=2, got %q", v)
+	}
+
+	cp, err := c.GetClusterProperty(ctx, "cwp.one")
+	if err != nil || cp.Value != "2" {
+		t.Fatalf("unexpected cluster property %v: %v", cp, err)
+	}
+
+	if err := c.DeleteClusterProperty(ctx, "cwp.one"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetClusterProperty(ctx, "cwp.one"); !restman.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestSyncClusterProperties(t *testing.T) {
+	s := restmantest.NewServer("admin", "7layer")
+	defer s.Close()
+	s.SetClusterProperty("cwp.same", "1")
+	s.SetClusterProperty("cwp.changed", "old")
+	s.SetClusterProperty("cwp.removed", "1")
+	s.SetClusterProperty("cwp.unmanaged", "1")
+	c := newClient(t, s, "7layer", 0)
+
+	desired := map[string]string{"cwp.same": "1", "cwp.changed": "new", "cwp.added": "1"}
+	changed, err := c.SyncClusterProperties(context.Background(), desired, []string{"cwp.removed", "cwp.same", "cwp.gone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 3 {
+		t.Fatalf("expected 3 changes, got %d", changed)
+	}
+	for name, value := range map[string]string{"cwp.same": "1", "cwp.changed": "new", "cwp.added": "1", "cwp.unmanaged": "1"} {
+		if v, ok := s.ClusterProperty(name); !ok || v != value {
+			t.Fatalf("expected %s=%s, got %q", name, value, v)
+		}
+	}
+	if _, ok := s.ClusterProperty("cwp.removed"); ok {
+		t.Fatal("expected cwp.removed to be deleted")
+	}
+
+	if changed, err := c.SyncClusterProperties(context.Background(), desired, nil); err != nil || changed != 0 {
+		t.Fatalf("expected no changes, got %d: %v", changed, err)
+	}
+}
+
+func TestImportBundle(t *testing.T) {
+	s := restmantest.NewServer("admin", "7layer")
+	defer s.Close()
+	c := newClient(t, s, "7layer", 0)
+
+	if _, err := c.ImportBundle(context.Background(), []byte("<l7:Bundle/>")); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Bundles()) != 1 {
+		t.Fatalf("expected 1 bundle, got %d", len(s.Bundles()))
+	}
+
+	bundle := []byte(`<l7:Bundle xmlns:l7="http://ns.l7tech.com/2010/04/gateway-management"><l7:Mappings>` +
+		`<l7:Mapping action="NewOrUpdate" srcId="1" type="CLUSTER_PROPERTY"/><l7:Mapping action="Delete" srcId="2" type="POLICY"/></l7:Mappings></l7:Bundle>`)
+	mappings, err := c.TestBundle(context.Background(), bundle)
+	if err != nil || len(mappings) != 2 || mappings[0].ActionTaken != "CreatedNew" || mappings[1].ActionTaken != "Ignored" || len(s.Bundles()) != 1 {
+		t.Fatalf("unexpected test mappings %v: %v", mappings, err)
+	}
+	if _, err := c.ImportBundle(context.Background(), bundle); err != nil {
+		t.Fatal(err)
+	}
+	if mappings, _ := c.TestBundle(context.Background(), bundle); len(mappings) != 2 || mappings[0].ActionTaken != "UpdatedExisting" {
+		t.Fatalf("expected the imported entity to be updated, got %v", mappings)
+	}
+
+	s.BundleErrors = []restman.Mapping{{SrcID: "abc", Type: "POLICY", ErrorType: "TargetNotFound"}}
+	_, err = c.ImportBundle(context.Background(), []byte("<l7:Bundle/>"))
+	var restmanErr *restman.Error
+	if !errors.As(err, &restmanErr) || restmanErr.StatusCode != http.StatusConflict || len(restmanErr.Mappings) != 1 {
+		t.Fatalf("expected bundle import error, got %v", err)
+	}
+}
+
+func TestListResources(t *testing.T) {
+	s := restmantest.NewServer("admin", "7layer")
+	defer s.Close()
+	s.ListenPorts = []restman.ListenPort{{ID: "1", Name: "Default HTTPS (8443)", Enabled: true, Protocol: "HTTPS", Port: 8443, EnabledFeatures: []string{"Published service message input"}}}
+	s.Services = []restman.Service{{ID: "2", Name: "echo", Enabled: true, URLPattern: "/echo", Verbs: []string{"GET", "POST"}}}
+	s.JDBCConnections = []restman.JDBCConnection{{ID: "3", Name: "orders", Enabled: true, DriverClass: "com.mysql.cj.jdbc.Driver", JdbcUrl: "jdbc:mysql://mysql:3306/orders"}}
+	c := newClient(t, s, "7layer", 0)
+	ctx := context.Background()
+
+	ports, err := c.ListListenPorts(ctx)
+	if err != nil || len(ports) != 1 || ports[0].Port != 8443 || len(ports[0].EnabledFeatures) != 1 {
+		t.Fatalf("unexpected listen ports %v: %v", ports, err)
+	}
+
+	services, err := c.ListServices(ctx)
+	if err != nil || len(services) != 1 || services[0].URLPattern != "/echo" || len(services[0].Verbs) != 2 {
+		t.Fatalf("unexpected services %v: %v", services, err)
+	}
+
+	connections, err := c.ListJDBCConnections(ctx)
+	if err != nil || len(connections) != 1 || connections[0].Name != "orders" || connections[0].JdbcUrl != "jdbc:mysql://mysql:3306/orders" {
+		t.Fatalf("unexpected JDBC connections %v: %v", connections, err)
+	}
+
+	version, err := c.Version(ctx)
+	if err != nil || version != "10.1.00" {
+		t.Fatalf("unexpected version %q: %v", version, err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	s := restmantest.NewServer("admin", "7layer")
+	defer s.Close()
+	ctx := context.Background()
+
+	var restmanErr *restman.Error
+	err := newClient(t, s, "wrong", 0).Health(ctx)
+	if !errors.As(err, &restmanErr) || restmanErr.StatusCode != http.StatusUnauthorized || restmanErr.Type != "AuthenticationFailed" {
+		t.Fatalf("expected authentication error, got %v", err)
+	}
+
+	s.FailRequests(2, http.StatusServiceUnavailable)
+	if err := newClient(t, s, "7layer", 2).Health(ctx); err != nil {
+		t.Fatalf("expected retries to succeed, got %v", err)
+	}
+
+	s.FailRequests(2, http.StatusServiceUnavailable)
+	if err := newClient(t, s, "7layer", 1).Health(ctx); err == nil {
+		t.Fatal("expected failure after retries")
+	}
+
+	s.FailRequests(1, http.StatusServiceUnavailable)
+	bundle, _ := util.BuildCWPBundle(map[string]string{"cwp.one": "1"})
+	if _, err := newClient(t, s, "7layer", 2).ImportBundle(ctx, bundle); !errors.As(err, &restmanErr) || restmanErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected bundle imports not to be retried after a server error, got %v", err)
+	}
+	if len(s.Bundles()) != 0 {
+		t.Fatalf("expected no bundle to be imported, got %d", len(s.Bundles()))
+	}
+}
@@ -0,0 +1,78 @@
+package restman
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Error is a failed Restman request. Type and Detail are taken from the Restman error
+// response when the Gateway returns one.
+type Error struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Type       string
+	Detail     string
+	// Mappings holds the failed mappings of a rejected bundle import
+	Mappings []Mapping
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("restman: %s %s: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Type != "" {
+		msg = msg + ": " + e.Type
+	}
+	if e.Detail != "" {
+		msg = msg + ": " + e.Detail
+	}
+	for _, m := range e.Mappings {
+		if m.ErrorType != "" {
+			msg = msg + fmt.Sprintf("; %s %s: %s", m.Type, m.SrcID, m.ErrorType)
+		}
+	}
+	return msg
+}
+
+// IsNotFound returns true if err is a Restman error for a missing resource
+func IsNotFound(err error) bool {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.StatusCode == http.StatusNotFound || e.Type == "ResourceNotFound"
+	}
+	return false
+}
+
+type errorResponse struct {
+	XMLName xml.Name `xml:"Error"`
+	Type    string   `xml:"Type"`
+	Detail  string   `xml:"Detail"`
+}
+
+func parseError(method string, u string, statusCode int, body []byte) error {
+	e := &Error{Method: method, URL: u, StatusCode: statusCode}
+
+	errResp := errorResponse{}
+	if xml.Unmarshal(body, &errResp) == nil {
+		e.Type = errResp.Type
+		e.Detail = strings.TrimSpace(errResp.Detail)
+		return e
+	}
+
+	// rejected bundle imports return their mappings with an errorType on each failed mapping
+	mappings := itemResponse{}
+	if xml.Unmarshal(body, &mappings) == nil && mappings.Resource.Mappings != nil {
+		e.Type = "BundleImportFailed"
+		for _, m := range mappings.Resource.Mappings.Mappings {
+			if m.ErrorType != "" {
+				e.Mappings = append(e.Mappings, m)
+			}
+		}
+		return e
+	}
+
+	e.Detail = strings.TrimSpace(string(body))
+	return e
+}
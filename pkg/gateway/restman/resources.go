@@ -0,0 +1,345 @@
+package restman
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+)
+
+// Mapping is the outcome of importing a single entity from a bundle
+type Mapping struct {
+	Action      string `xml:"action,attr"`
+	ActionTaken string `xml:"actionTaken,attr"`
+	SrcID       string `xml:"srcId,attr"`
+	TargetID    string `xml:"targetId,attr"`
+	Type        string `xml:"type,attr"`
+	ErrorType   string `xml:"errorType,attr"`
+}
+
+// ClusterProperty is a Gateway cluster-wide property
+type ClusterProperty struct {
+	XMLName xml.Name `xml:"http://ns.l7tech.com/2010/04/gateway-management ClusterProperty"`
+	ID      string   `xml:"id,attr,omitempty"`
+	Version int      `xml:"version,attr,omitempty"`
+	Name    string   `xml:"Name"`
+	Value   string   `xml:"Value"`
+}
+
+// ListenPort is a Gateway listen port
+type ListenPort struct {
+	ID              string   `xml:"id,attr"`
+	Name            string   `xml:"Name"`
+	Enabled         bool     `xml:"Enabled"`
+	Protocol        string   `xml:"Protocol"`
+	Interface       string   `xml:"Interface"`
+	Port            int      `xml:"Port"`
+	EnabledFeatures []string `xml:"EnabledFeatures>StringValue"`
+}
+
+// JDBCConnection is a JDBC connection configured on the Gateway
+type JDBCConnection struct {
+	ID          string `xml:"id,attr"`
+	Name        string `xml:"Name"`
+	Enabled     bool   `xml:"Enabled"`
+	DriverClass string `xml:"Extension>DriverClass"`
+	JdbcUrl     string `xml:"Extension>JdbcUrl"`
+}
+
+// Service is a published Gateway service
+type Service struct {
+	ID         string
+	FolderID   string
+	Name       string
+	Enabled    bool
+	URLPattern string
+	Verbs      []string
+}
+
+type serviceMO struct {
+	ID     string `xml:"id,attr"`
+	Detail struct {
+		FolderID   string   `xml:"folderId,attr"`
+		Name       string   `xml:"Name"`
+		Enabled    bool     `xml:"Enabled"`
+		URLPattern string   `xml:"ServiceMappings>HttpMapping>UrlPattern"`
+		Verbs      []string `xml:"ServiceMappings>HttpMapping>Verbs>Verb"`
+	} `xml:"ServiceDetail"`
+}
+
+type mappings struct {
+	Mappings []Mapping `xml:"Mapping"`
+}
+
+type resource struct {
+	Mappings        *mappings        `xml:"Mappings"`
+	ClusterProperty *ClusterProperty `xml:"ClusterProperty"`
+	ListenPort      *ListenPort      `xml:"ListenPort"`
+	Service         *serviceMO       `xml:"Service"`
+	JDBCConnection  *JDBCConnection  `xml:"JDBCConnection"`
+}
+
+type itemResponse struct {
+	XMLName  xml.Name `xml:"Item"`
+	Name     string   `xml:"Name"`
+	ID       string   `xml:"Id"`
+	Type     string   `xml:"Type"`
+	Resource resource `xml:"Resource"`
+}
+
+type listResponse struct {
+	XMLName xml.Name       `xml:"List"`
+	Items   []itemResponse `xml:"Item"`
+}
+
+// ImportBundle installs a Restman bundle and returns the mapping results
+func (c *Client) ImportBundle(ctx context.Context, bundle []byte) ([]Mapping, error) {
+	return c.importBundle(ctx, nil, bundle)
+}
+
+// TestBundle imports a Restman bundle in test mode and returns the mapping results without changing the
+// Gateway. The action taken on each mapping (CreatedNew, UpdatedExisting, UsedExisting, Deleted or Ignored)
+// is what importing the bundle would do.
+func (c *Client) TestBundle(ctx context.Context, bundle []byte) ([]Mapping, error) {
+	return c.importBundle(ctx, url.Values{"test": []string{"true"}}, bundle)
+}
+
+func (c *Client) importBundle(ctx context.Context, query url.Values, bundle []byte) ([]Mapping, error) {
+	body, err := c.do(ctx, http.MethodPut, basePath+"/bundle", query, "application/xml", bundle)
+	if err != nil {
+		return nil, err
+	}
+	item := itemResponse{}
+	if err := xml.Unmarshal(body, &item); err != nil {
+		return nil, err
+	}
+	if item.Resource.Mappings == nil {
+		return nil, nil
+	}
+	return item.Resource.Mappings.Mappings, nil
+}
+
+// ExportBundle exports a Restman bundle, query takes the Restman bundle export parameters (e.g. all=true)
+func (c *Client) ExportBundle(ctx context.Context, query url.Values) ([]byte, error) {
+	return c.do(ctx, http.MethodGet, basePath+"/bundle", query, "", nil)
+}
+
+// ListClusterProperties returns every cluster property on the Gateway
+func (c *Client) ListClusterProperties(ctx context.Context) ([]ClusterProperty, error) {
+	return c.listClusterProperties(ctx, nil)
+}
+
+// GetClusterProperty returns the named cluster property, or a not found error if it doesn't exist
+func (c *Client) GetClusterProperty(ctx context.Context, name string) (*ClusterProperty, error) {
+	cps, err := c.listClusterProperties(ctx, url.Values{"name": []string{name}})
+	if err != nil {
+		return nil, err
+	}
+	for _, cp := range cps {
+		if cp.Name == name {
+			return &cp, nil
+		}
+	}
+	return nil, &Error{Method: http.MethodGet, URL: c.baseURL + basePath + "/clusterProperties", StatusCode: http.StatusNotFound, Type: "ResourceNotFound", Detail: name}
+}
+
+// SetClusterProperty creates the named cluster property or updates its value
+func (c *Client) SetClusterProperty(ctx context.Context, name string, value string) error {
+	existing, err := c.GetClusterProperty(ctx, name)
+	if err != nil && !IsNotFound(err) {
+		return err
+	}
+
+	if existing == nil {
+		body, err := xml.Marshal(ClusterProperty{Name: name, Value: value})
+		if err != nil {
+			return err
+		}
+		_, err = c.do(ctx, http.MethodPost, basePath+"/clusterProperties", nil, "application/xml", body)
+		return err
+	}
+
+	if existing.Value == value {
+		return nil
+	}
+	existing.Value = value
+	body, err := xml.Marshal(existing)
+	if err != nil {
+		return err
+	}
+	_, err = c.do(ctx, http.MethodPut, basePath+"/clusterProperties/"+url.PathEscape(existing.ID), nil, "application/xml", body)
+	return err
+}
+
+// DeleteClusterProperty deletes the named cluster property if it exists
+func (c *Client) DeleteClusterProperty(ctx context.Context, name string) error {
+	existing, err := c.GetClusterProperty(ctx, name)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	_, err = c.do(ctx, http.MethodDelete, basePath+"/clusterProperties/"+url.PathEscape(existing.ID), nil, "", nil)
+	return err
+}
+
+// SyncClusterProperties brings the Gateway's cluster properties to the desired values, only writing the ones
+// that differ, and deletes the properties named in remove that aren't desired. It returns the number of
+// properties changed.
+func (c *Client) SyncClusterProperties(ctx context.Context, desired map[string]string, remove []string) (int, error) {
+	current, err := c.ListClusterProperties(ctx)
+	if err != nil {
+		return 0, err
+	}
+	existing := map[string]ClusterProperty{}
+	for _, cp := range current {
+		existing[cp.Name] = cp
+	}
+
+	names := make([]string, 0, len(desired))
+	for name := range desired {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	changed := 0
+	for _, name := range names {
+		cp, ok := existing[name]
+		if ok && cp.Value == desired[name] {
+			continue
+		}
+		cp.Name = name
+		cp.Value = desired[name]
+		body, err := xml.Marshal(cp)
+		if err != nil {
+			return changed, err
+		}
+		if ok {
+			_, err = c.do(ctx, http.MethodPut, basePath+"/clusterProperties/"+url.PathEscape(cp.ID), nil, "application/xml", body)
+		} else {
+			_, err = c.do(ctx, http.MethodPost, basePath+"/clusterProperties", nil, "application/xml", body)
+		}
+		if err != nil {
+			return changed, err
+		}
+		changed++
+	}
+
+	for _, name := range remove {
+		cp, ok := existing[name]
+		if _, keep := desired[name]; !ok || keep {
+			continue
+		}
+		if _, err := c.do(ctx, http.MethodDelete, basePath+"/clusterProperties/"+url.PathEscape(cp.ID), nil, "", nil); err != nil && !IsNotFound(err) {
+			return changed, err
+		}
+		changed++
+	}
+	return changed, nil
+}
+
+func (c *Client) listClusterProperties(ctx context.Context, query url.Values) ([]ClusterProperty, error) {
+	list, err := c.list(ctx, "/clusterProperties", query)
+	if err != nil {
+		return nil, err
+	}
+	cps := []ClusterProperty{}
+	for _, item := range list.Items {
+		if item.Resource.ClusterProperty != nil {
+			cps = append(cps, *item.Resource.ClusterProperty)
+		}
+	}
+	return cps, nil
+}
+
+// ListListenPorts returns the listen ports configured on the Gateway
+func (c *Client) ListListenPorts(ctx context.Context) ([]ListenPort, error) {
+	list, err := c.list(ctx, "/listenPorts", nil)
+	if err != nil {
+		return nil, err
+	}
+	ports := []ListenPort{}
+	for _, item := range list.Items {
+		if item.Resource.ListenPort != nil {
+			ports = append(ports, *item.Resource.ListenPort)
+		}
+	}
+	return ports, nil
+}
+
+// ListServices returns the services published on the Gateway
+func (c *Client) ListServices(ctx context.Context) ([]Service, error) {
+	list, err := c.list(ctx, "/services", nil)
+	if err != nil {
+		return nil, err
+	}
+	services := []Service{}
+	for _, item := range list.Items {
+		s := item.Resource.Service
+		if s == nil {
+			continue
+		}
+		services = append(services, Service{
+			ID:         s.ID,
+			FolderID:   s.Detail.FolderID,
+			Name:       s.Detail.Name,
+			Enabled:    s.Detail.Enabled,
+			URLPattern: s.Detail.URLPattern,
+			Verbs:      s.Detail.Verbs,
+		})
+	}
+	return services, nil
+}
+
+// ListJDBCConnections returns the JDBC connections configured on the Gateway
+func (c *Client) ListJDBCConnections(ctx context.Context) ([]JDBCConnection, error) {
+	list, err := c.list(ctx, "/jdbcConnections", nil)
+	if err != nil {
+		return nil, err
+	}
+	connections := []JDBCConnection{}
+	for _, item := range list.Items {
+		if item.Resource.JDBCConnection != nil {
+			connections = append(connections, *item.Resource.JDBCConnection)
+		}
+	}
+	return connections, nil
+}
+
+// Health returns nil if the Gateway responds to pings
+func (c *Client) Health(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodGet, "/ssg/ping", nil, "", nil)
+	return err
+}
+
+var versionPattern = regexp.MustCompile(`(?i)version\D{0,20}?(\d+(\.\d+)+)`)
+
+// Version returns the Gateway version reported by the ping endpoint. The ping endpoint must be
+// configured to return detailed output for the version to be available.
+func (c *Client) Version(ctx context.Context) (string, error) {
+	body, err := c.do(ctx, http.MethodGet, "/ssg/ping", nil, "", nil)
+	if err != nil {
+		return "", err
+	}
+	m := versionPattern.FindSubmatch(body)
+	if m == nil {
+		return "", errors.New("restman: gateway version not found in ping response")
+	}
+	return string(m[1]), nil
+}
+
+func (c *Client) list(ctx context.Context, path string, query url.Values) (*listResponse, error) {
+	body, err := c.do(ctx, http.MethodGet, basePath+path, query, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	list := &listResponse{}
+	if err := xml.Unmarshal(body, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
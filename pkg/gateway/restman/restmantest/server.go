@@ -0,0 +1,318 @@
+// Package restmantest provides an in-process Gateway Restman API for tests
+package restmantest
+
+import (
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/restman"
+)
+
+const namespace = "http://ns.l7tech.com/2010/04/gateway-management"
+
+// Server is a fake Gateway serving the parts of the Restman API used by the operator.
+// ListenPorts, Services, JDBCConnections, Export, Version and BundleErrors may be set before the first request.
+type Server struct {
+	*httptest.Server
+
+	Username string
+	Password string
+
+	ListenPorts     []restman.ListenPort
+	Services        []restman.Service
+	JDBCConnections []restman.JDBCConnection
+	// Export is returned by bundle exports
+	Export []byte
+	// Version is reported by the ping endpoint
+	Version string
+	// BundleErrors makes bundle imports fail with these mappings
+	BundleErrors []restman.Mapping
+
+	mu                sync.Mutex
+	nextID            int
+	failures          int
+	failureStatus     int
+	clusterProperties map[string]restman.ClusterProperty
+	bundles           [][]byte
+	imported          map[string]bool
+}
+
+// NewServer starts a TLS Server that accepts username and password
+func NewServer(username string, password string) *Server {
+	s := &Server{
+		Username:          username,
+		Password:          password,
+		Version:           "10.1.00",
+		clusterProperties: map[string]restman.ClusterProperty{},
+		imported:          map[string]bool{},
+	}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// CACert returns the PEM encoded certificate of the server
+func (s *Server) CACert() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw})
+}
+
+// FailRequests makes the next n requests fail with statusCode
+func (s *Server) FailRequests(n int, statusCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = n
+	s.failureStatus = statusCode
+}
+
+// SetClusterProperty sets a cluster property on the server
+func (s *Server) SetClusterProperty(name string, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp, ok := s.clusterProperties[name]
+	if !ok {
+		cp = restman.ClusterProperty{ID: s.newID(), Name: name}
+	}
+	cp.Value = value
+	s.clusterProperties[name] = cp
+}
+
+// ClusterProperty returns the value of a cluster property on the server
+func (s *Server) ClusterProperty(name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp, ok := s.clusterProperties[name]
+	return cp.Value, ok
+}
+
+// Bundles returns the bundles imported so far in the order they were received
+func (s *Server) Bundles() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]byte{}, s.bundles...)
+}
+
+func (s *Server) newID() string {
+	s.nextID++
+	return fmt.Sprintf("%032x", s.nextID)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failures > 0 {
+		s.failures--
+		writeError(w, s.failureStatus, "ServerError", "injected failure")
+		return
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok || username != s.Username || password != s.Password {
+		writeError(w, http.StatusUnauthorized, "AuthenticationFailed", "invalid credentials")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", err.Error())
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/restman/1.0")
+	switch {
+	case r.URL.Path == "/ssg/ping" && r.Method == http.MethodGet:
+		fmt.Fprintf(w, "OK\nGateway Version: %s\n", s.Version)
+	case path == "/bundle" && r.Method == http.MethodPut:
+		s.importBundle(w, body, r.URL.Query().Get("test") == "true")
+	case path == "/bundle" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write(s.Export)
+	case path == "/clusterProperties" && r.Method == http.MethodGet:
+		s.listClusterProperties(w, r.URL.Query().Get("name"))
+	case path == "/clusterProperties" && r.Method == http.MethodPost:
+		s.putClusterProperty(w, "", body)
+	case strings.HasPrefix(path, "/clusterProperties/") && r.Method == http.MethodPut:
+		s.putClusterProperty(w, strings.TrimPrefix(path, "/clusterProperties/"), body)
+	case strings.HasPrefix(path, "/clusterProperties/") && r.Method == http.MethodDelete:
+		s.deleteClusterProperty(w, strings.TrimPrefix(path, "/clusterProperties/"))
+	case path == "/listenPorts" && r.Method == http.MethodGet:
+		s.listListenPorts(w)
+	case path == "/services" && r.Method == http.MethodGet:
+		s.listServices(w)
+	case path == "/jdbcConnections" && r.Method == http.MethodGet:
+		s.listJDBCConnections(w)
+	default:
+		writeError(w, http.StatusNotFound, "ResourceNotFound", r.Method+" "+r.URL.Path)
+	}
+}
+
+// importBundle records the entities mapped by the bundle so later imports report them as existing.
+// Bundles imported in test mode report the actions they would take without being recorded.
+func (s *Server) importBundle(w http.ResponseWriter, body []byte, test bool) {
+	mappings := ""
+	for _, m := range s.BundleErrors {
+		mappings += fmt.Sprintf(`<l7:Mapping action="NewOrExisting" srcId="%s" type="%s" errorType="%s"/>`, escape(m.SrcID), escape(m.Type), escape(m.ErrorType))
+	}
+	if len(s.BundleErrors) > 0 {
+		writeXML(w, http.StatusConflict, item("Bundle mappings", "", "BUNDLE MAPPINGS", "<l7:Mappings>"+mappings+"</l7:Mappings>"))
+		return
+	}
+
+	bundle := struct {
+		Mappings []restman.Mapping `xml:"Mappings>Mapping"`
+	}{}
+	xml.Unmarshal(body, &bundle)
+	for _, m := range bundle.Mappings {
+		key := m.Type + "/" + m.SrcID
+		actionTaken := "CreatedNew"
+		switch {
+		case m.Action == "Delete" && s.imported[key]:
+			actionTaken = "Deleted"
+		case m.Action == "Delete":
+			actionTaken = "Ignored"
+		case s.imported[key]:
+			actionTaken = "UpdatedExisting"
+		}
+		if !test {
+			s.imported[key] = m.Action != "Delete"
+		}
+		mappings += fmt.Sprintf(`<l7:Mapping action="%s" actionTaken="%s" srcId="%s" targetId="%s" type="%s"/>`, escape(m.Action), actionTaken, escape(m.SrcID), escape(m.SrcID), escape(m.Type))
+	}
+	if !test {
+		s.bundles = append(s.bundles, body)
+	}
+	writeXML(w, http.StatusOK, item("Bundle mappings", "", "BUNDLE MAPPINGS", "<l7:Mappings>"+mappings+"</l7:Mappings>"))
+}
+
+func (s *Server) listClusterProperties(w http.ResponseWriter, name string) {
+	names := []string{}
+	for n := range s.clusterProperties {
+		if name == "" || n == name {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+
+	items := []string{}
+	for _, n := range names {
+		cp := s.clusterProperties[n]
+		items = append(items, item(cp.Name, cp.ID, "CLUSTER_PROPERTY", fmt.Sprintf(`<l7:ClusterProperty id="%s"><l7:Name>%s</l7:Name><l7:Value>%s</l7:Value></l7:ClusterProperty>`, cp.ID, escape(cp.Name), escape(cp.Value))))
+	}
+	writeXML(w, http.StatusOK, list("CLUSTER_PROPERTY", items))
+}
+
+func (s *Server) putClusterProperty(w http.ResponseWriter, id string, body []byte) {
+	cp := restman.ClusterProperty{}
+	if err := xml.Unmarshal(body, &cp); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidResource", err.Error())
+		return
+	}
+
+	if id == "" {
+		if _, ok := s.clusterProperties[cp.Name]; ok {
+			writeError(w, http.StatusForbidden, "InvalidResource", "cluster property "+cp.Name+" already exists")
+			return
+		}
+		cp.ID = s.newID()
+		s.clusterProperties[cp.Name] = cp
+		writeXML(w, http.StatusCreated, item(cp.Name, cp.ID, "CLUSTER_PROPERTY", ""))
+		return
+	}
+
+	for n, existing := range s.clusterProperties {
+		if existing.ID == id {
+			delete(s.clusterProperties, n)
+			cp.ID = id
+			s.clusterProperties[cp.Name] = cp
+			writeXML(w, http.StatusOK, item(cp.Name, cp.ID, "CLUSTER_PROPERTY", ""))
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "ResourceNotFound", "cluster property "+id)
+}
+
+func (s *Server) deleteClusterProperty(w http.ResponseWriter, id string) {
+	for n, existing := range s.clusterProperties {
+		if existing.ID == id {
+			delete(s.clusterProperties, n)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "ResourceNotFound", "cluster property "+id)
+}
+
+func (s *Server) listListenPorts(w http.ResponseWriter) {
+	items := []string{}
+	for _, p := range s.ListenPorts {
+		features := ""
+		for _, f := range p.EnabledFeatures {
+			features += "<l7:StringValue>" + escape(f) + "</l7:StringValue>"
+		}
+		items = append(items, item(p.Name, p.ID, "SSG_CONNECTOR", fmt.Sprintf(`<l7:ListenPort id="%s"><l7:Name>%s</l7:Name><l7:Enabled>%t</l7:Enabled><l7:Protocol>%s</l7:Protocol><l7:Interface>%s</l7:Interface><l7:Port>%d</l7:Port><l7:EnabledFeatures>%s</l7:EnabledFeatures></l7:ListenPort>`,
+			escape(p.ID), escape(p.Name), p.Enabled, escape(p.Protocol), escape(p.Interface), p.Port, features)))
+	}
+	writeXML(w, http.StatusOK, list("SSG_CONNECTOR", items))
+}
+
+func (s *Server) listServices(w http.ResponseWriter) {
+	items := []string{}
+	for _, svc := range s.Services {
+		verbs := ""
+		for _, v := range svc.Verbs {
+			verbs += "<l7:Verb>" + escape(v) + "</l7:Verb>"
+		}
+		items = append(items, item(svc.Name, svc.ID, "SERVICE", fmt.Sprintf(`<l7:Service id="%s"><l7:ServiceDetail id="%s" folderId="%s"><l7:Name>%s</l7:Name><l7:Enabled>%t</l7:Enabled><l7:ServiceMappings><l7:HttpMapping><l7:UrlPattern>%s</l7:UrlPattern><l7:Verbs>%s</l7:Verbs></l7:HttpMapping></l7:ServiceMappings></l7:ServiceDetail></l7:Service>`,
+			escape(svc.ID), escape(svc.ID), escape(svc.FolderID), escape(svc.Name), svc.Enabled, escape(svc.URLPattern), verbs)))
+	}
+	writeXML(w, http.StatusOK, list("SERVICE", items))
+}
+
+func (s *Server) listJDBCConnections(w http.ResponseWriter) {
+	items := []string{}
+	for _, c := range s.JDBCConnections {
+		items = append(items, item(c.Name, c.ID, "JDBC_CONNECTION", fmt.Sprintf(`<l7:JDBCConnection id="%s"><l7:Name>%s</l7:Name><l7:Enabled>%t</l7:Enabled><l7:Extension><l7:DriverClass>%s</l7:DriverClass><l7:JdbcUrl>%s</l7:JdbcUrl></l7:Extension></l7:JDBCConnection>`,
+			escape(c.ID), escape(c.Name), c.Enabled, escape(c.DriverClass), escape(c.JdbcUrl))))
+	}
+	writeXML(w, http.StatusOK, list("JDBC_CONNECTION", items))
+}
+
+func item(name string, id string, itemType string, resource string) string {
+	s := `<l7:Item xmlns:l7="` + namespace + `"><l7:Name>` + escape(name) + `</l7:Name>`
+	if id != "" {
+		s += `<l7:Id>` + escape(id) + `</l7:Id>`
+	}
+	s += `<l7:Type>` + escape(itemType) + `</l7:Type>`
+	if resource != "" {
+		s += `<l7:Resource>` + resource + `</l7:Resource>`
+	}
+	return s + `</l7:Item>`
+}
+
+func list(itemType string, items []string) string {
+	return `<l7:List xmlns:l7="` + namespace + `"><l7:Name>` + escape(itemType) + ` List</l7:Name><l7:Type>List</l7:Type>` + strings.Join(items, "") + `</l7:List>`
+}
+
+func writeError(w http.ResponseWriter, statusCode int, errorType string, detail string) {
+	writeXML(w, statusCode, `<l7:Error xmlns:l7="`+namespace+`"><l7:Type>`+escape(errorType)+`</l7:Type><l7:Detail>`+escape(detail)+`</l7:Detail></l7:Error>`)
+}
+
+func writeXML(w http.ResponseWriter, statusCode int, body string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(statusCode)
+	io.WriteString(w, body)
+}
+
+func escape(s string) string {
+	b := strings.Builder{}
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
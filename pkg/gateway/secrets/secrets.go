@@ -37,3 +37,49 @@ func NewSecret(gw *securityv1.Gateway) *corev1.Secret {
 
 	return secret
 }
+
+// NewSecurePasswordSecret holds the bootstrap bundle creating the Gateway's secure passwords
+func NewSecurePasswordSecret(gw *securityv1.Gateway, bundle []byte) *corev1.Secret {
+	return newBundleSecret(gw, gw.Name+"-secure-password-bundle", map[string][]byte{"secure-passwords.bundle": bundle})
+}
+
+// NewPrivateKeySecret holds the Graphman bootstrap bundle adding the Gateway's private keys to its keystore
+func NewPrivateKeySecret(gw *securityv1.Gateway, bundle []byte) *corev1.Secret {
+	return newBundleSecret(gw, gw.Name+"-private-key-bundle", map[string][]byte{"private-keys.json": bundle})
+}
+
+// NewJDBCSecret holds the bootstrap bundle creating the Gateway's JDBC connections
+func NewJDBCSecret(gw *securityv1.Gateway, bundle []byte) *corev1.Secret {
+	return newBundleSecret(gw, gw.Name+"-jdbc-bundle", map[string][]byte{"jdbc-connections.bundle": bundle})
+}
+
+// NewCWPSecret holds the cluster property bootstrap bundle when a cluster property value is read from a Secret
+func NewCWPSecret(gw *securityv1.Gateway, bundle []byte) *corev1.Secret {
+	return newBundleSecret(gw, gw.Name+"-cwp-bundle", map[string][]byte{"cwp.bundle": bundle})
+}
+
+// NewResolvedBundleSecret holds a ConfigMap bundle with its template placeholders resolved
+func NewResolvedBundleSecret(gw *securityv1.Gateway, bundle string, data map[string][]byte) *corev1.Secret {
+	return newBundleSecret(gw, util.ResolvedBundleName(gw, bundle), data)
+}
+
+// NewRepositoryBundleSecret holds the bundles of a Repository mounted into the Gateway pods
+func NewRepositoryBundleSecret(gw *securityv1.Gateway, repository string, data map[string][]byte) *corev1.Secret {
+	return newBundleSecret(gw, util.RepositoryBundleName(gw, repository), data)
+}
+
+func newBundleSecret(gw *securityv1.Gateway, name string, data map[string][]byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: gw.Namespace,
+			Labels:    util.DefaultLabels(gw),
+		},
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+}
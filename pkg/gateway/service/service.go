@@ -21,6 +21,23 @@ func NewService(gw *securityv1.Gateway) *corev1.Service {
 		})
 	}
 
+	for _, lp := range gw.Spec.App.ListenPorts.Custom {
+		exists := false
+		for _, p := range gw.Spec.App.Service.Ports {
+			if p.Port == lp.Port || p.TargetPort == lp.Port {
+				exists = true
+			}
+		}
+		if !exists {
+			ports = append(ports, corev1.ServicePort{
+				Name:       util.ListenPortName(lp),
+				Port:       lp.Port,
+				TargetPort: intstr.FromInt(int(lp.Port)),
+				Protocol:   corev1.ProtocolTCP,
+			})
+		}
+	}
+
 	ls := util.DefaultLabels(gw)
 	service := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
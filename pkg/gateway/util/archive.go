@@ -0,0 +1,282 @@
+package util
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// ArchiveReference describes a .zip or .tar.gz bundle archive or an OCI artifact. http archives are fetched
+// with the token (as a bearer token) or username and password of the Repository Secret, s3 archives are
+// path style object URLs signed with its accessKeyId and secretAccessKey. oci artifacts are pulled by Digest,
+// or Tag if no digest is pinned.
+type ArchiveReference struct {
+	Type   string
+	Region string
+	SHA256 string
+	Tag    string
+	Digest string
+}
+
+// S3 credential keys in the Repository Secret
+const (
+	S3AccessKeyIDKey     = "accessKeyId"
+	S3SecretAccessKeyKey = "secretAccessKey"
+)
+
+// maxArchiveSize limits the size of downloaded archives
+const maxArchiveSize = 64 << 20
+
+var archiveClient = &http.Client{Timeout: 2 * time.Minute}
+
+// GetArchiveRevision returns a revision identifying the content of the archive at url without downloading it.
+// Pinned archives are identified by their digest once the server has accepted the credentials, others by their
+// ETag or Last-Modified header. Archives served without either are downloaded and identified by their digest.
+// The headers are requested with HEAD, or a GET of the first byte where HEAD is rejected as it is by presigned
+// S3 URLs and many artifact servers. OCI artifacts are identified by their manifest digest.
+func GetArchiveRevision(url string, creds *GitCredentials, ref ArchiveReference) (string, error) {
+	if ref.Type == "oci" {
+		return GetOCIDigest(url, creds, ref)
+	}
+	resp, err := archiveRequest(http.MethodHead, url, creds, ref, nil)
+	if err != nil {
+		resp, err = archiveRequest(http.MethodGet, url, creds, ref, http.Header{"Range": {"bytes=0-0"}})
+		if err != nil {
+			return "", err
+		}
+	}
+	resp.Body.Close()
+
+	switch {
+	case ref.SHA256 != "":
+		return "sha256:" + strings.ToLower(ref.SHA256), nil
+	case resp.Header.Get("ETag") != "":
+		return "etag:" + strings.Trim(resp.Header.Get("ETag"), `"`), nil
+	case resp.Header.Get("Last-Modified") != "":
+		return "modified:" + resp.Header.Get("Last-Modified"), nil
+	}
+
+	data, err := downloadArchive(url, creds, ref)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// GetArchiveFiles downloads the archive at url, verifies its digest if ref pins one and returns the regular
+// files it contains keyed by their slash separated path.
+func GetArchiveFiles(url string, creds *GitCredentials, ref ArchiveReference) (map[string][]byte, error) {
+	if ref.Type == "oci" {
+		return GetOCIFiles(url, creds, ref)
+	}
+	data, err := downloadArchive(url, creds, ref)
+	if err != nil {
+		return nil, err
+	}
+	if ref.SHA256 != "" {
+		sum := sha256.Sum256(data)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), ref.SHA256) {
+			return nil, fmt.Errorf("%s: sha256 %s doesn't match %s", url, hex.EncodeToString(sum[:]), ref.SHA256)
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "archive")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	dest := filepath.Join(dir, "files")
+
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		src := filepath.Join(dir, "archive.zip")
+		if err := os.WriteFile(src, data, 0600); err != nil {
+			return nil, err
+		}
+		_, err = unzip(src, dest, newExtractLimits())
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		_, err = untar(bytes.NewReader(data), dest, newExtractLimits())
+	default:
+		return nil, fmt.Errorf("%s: not a .zip or .tar.gz archive", url)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+
+	return readFiles(dest)
+}
+
+// readFiles returns the regular files under dir keyed by their slash separated path relative to dir
+func readFiles(dest string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	if _, err := os.Stat(dest); os.IsNotExist(err) {
+		return files, nil
+	}
+	err := filepath.Walk(dest, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dest, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(name)] = contents
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func downloadArchive(url string, creds *GitCredentials, ref ArchiveReference) ([]byte, error) {
+	resp, err := archiveRequest(http.MethodGet, url, creds, ref, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxArchiveSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxArchiveSize {
+		return nil, fmt.Errorf("%s: archive is larger than %d bytes", url, maxArchiveSize)
+	}
+	return data, nil
+}
+
+// archiveRequest sends an authenticated request for the archive with the extra headers. Rejected credentials are
+// reported with the same errors as git so they can be told apart from other failures with IsGitAuthError.
+func archiveRequest(method string, url string, creds *GitCredentials, ref ArchiveReference, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if creds != nil {
+		switch {
+		case ref.Type == "s3" && creds.AccessKeyID != "":
+			region := ref.Region
+			if region == "" {
+				region = "us-east-1"
+			}
+			req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+			signS3Request(req, creds.AccessKeyID, creds.SecretAccessKey, region, time.Now())
+		case creds.Token != "":
+			req.Header.Set("Authorization", "Bearer "+creds.Token)
+		case creds.Password != "":
+			req.SetBasicAuth(creds.Username, creds.Password)
+		}
+	}
+
+	resp, err := archiveClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		err = transport.ErrAuthenticationRequired
+	case resp.StatusCode == http.StatusForbidden:
+		err = transport.ErrAuthorizationFailed
+	case resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent:
+		err = fmt.Errorf("unexpected response %s", resp.Status)
+	}
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	return resp, nil
+}
+
+// emptyPayloadHash is the SHA-256 of the empty request body sent to S3
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// signS3Request signs req for S3 with AWS Signature Version 4. The host and every header already set on req
+// are signed, X-Amz-Content-Sha256 must be set to the hash of the payload.
+func signS3Request(req *http.Request, accessKeyID string, secretAccessKey string, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	canonicalHeaders := ""
+	for _, k := range names {
+		canonicalHeaders += k + ":" + headers[k] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	params := []string{}
+	for _, k := range keys {
+		values := append([]string{}, query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			params = append(params, s3Escape(k)+"="+s3Escape(v))
+		}
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		strings.Join(params, "&"),
+		canonicalHeaders,
+		signedHeaders,
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKeyID+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape percent encodes everything but unreserved characters as S3 expects
+func s3Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
@@ -1,16 +1,29 @@
 package util
 
 import (
-	"crypto/rand"
+	"bytes"
+	"crypto/sha1"
 	"encoding/hex"
 	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
 )
 
+// bundleNamespace is the namespace of Restman bundles
+const bundleNamespace = "http://ns.l7tech.com/2010/04/gateway-management"
+
+// Bundle is a Restman bundle. Bundles read with ParseBundle keep the entities and elements the model
+// doesn't describe in Other and Extra fields so they're written back unchanged.
 type Bundle struct {
 	XMLName    xml.Name   `xml:"l7:Bundle"`
 	XMLNS      string     `xml:"xmlns:l7,attr"`
 	References References `xml:"l7:References"`
 	Mappings   Mappings   `xml:"l7:Mappings"`
+	Extra      []Element  `xml:",any"`
 }
 
 type References struct {
@@ -18,49 +31,227 @@ type References struct {
 }
 
 type Item struct {
-	Name     string   `xml:"l7:Name"`
-	ID       string   `xml:"l7:Id"`
-	Type     string   `xml:"l7:Type"`
-	Resource Resource `xml:"l7:Resource"`
+	Name      string    `xml:"l7:Name"`
+	ID        string    `xml:"l7:Id"`
+	Type      string    `xml:"l7:Type"`
+	TimeStamp string    `xml:"l7:TimeStamp,omitempty"`
+	Resource  Resource  `xml:"l7:Resource"`
+	Extra     []Element `xml:",any"`
 }
 
 type Resource struct {
-	ClusterProperty *ClusterProperty `xml:"l7:ClusterProperty,omitempty"`
-	ListenPort      *ListenPort      `xml:"l7:ListenPort,omitempty"`
+	ClusterProperty       *ClusterProperty       `xml:"l7:ClusterProperty,omitempty"`
+	ListenPort            *ListenPort            `xml:"l7:ListenPort,omitempty"`
+	Folder                *Folder                `xml:"l7:Folder,omitempty"`
+	Service               *Service               `xml:"l7:Service,omitempty"`
+	Policy                *Policy                `xml:"l7:Policy,omitempty"`
+	EncapsulatedAssertion *EncapsulatedAssertion `xml:"l7:EncapsulatedAssertion,omitempty"`
+	JDBCConnection        *JDBCConnection        `xml:"l7:JDBCConnection,omitempty"`
+	StoredPassword        *StoredPassword        `xml:"l7:StoredPassword,omitempty"`
+	PrivateKey            *PrivateKey            `xml:"l7:PrivateKey,omitempty"`
+	TrustedCertificate    *TrustedCertificate    `xml:"l7:TrustedCertificate,omitempty"`
+	IdentityProvider      *IdentityProvider      `xml:"l7:IdentityProvider,omitempty"`
+	ScheduledTask         *ScheduledTask         `xml:"l7:ScheduledTask,omitempty"`
+	// Other holds entities of other types
+	Other []Element `xml:",any"`
 }
 
 type ClusterProperty struct {
-	ID    string `xml:"id,attr"`
-	Name  string `xml:"l7:Name"`
-	Value string `xml:"l7:Value"`
+	ID    string    `xml:"id,attr"`
+	Name  string    `xml:"l7:Name"`
+	Value string    `xml:"l7:Value"`
+	Extra []Element `xml:",any"`
 }
 
 type Mappings struct {
 	Mapping []Mapping `xml:"l7:Mapping"`
 }
 type Mapping struct {
-	Action     string     `xml:"action,attr"`
-	SrcId      string     `xml:"srcId,attr"`
-	Type       string     `xml:"type,attr"`
-	Properties Properties `xml:"l7:Properties"`
+	Action      string      `xml:"action,attr"`
+	ActionTaken string      `xml:"actionTaken,attr,omitempty"`
+	SrcId       string      `xml:"srcId,attr"`
+	SrcUri      string      `xml:"srcUri,attr,omitempty"`
+	TargetId    string      `xml:"targetId,attr,omitempty"`
+	TargetUri   string      `xml:"targetUri,attr,omitempty"`
+	Type        string      `xml:"type,attr"`
+	Properties  *Properties `xml:"l7:Properties,omitempty"`
 }
 
 type Properties struct {
 	Property []Property `xml:"l7:Property"`
 }
 
+// Property is a Restman property, only the value of its type is set
 type Property struct {
 	Key          string `xml:"key,attr"`
 	StringValue  string `xml:"l7:StringValue,omitempty"`
-	BooleanValue bool   `xml:"l7:BooleanValue,omitempty"`
+	BooleanValue string `xml:"l7:BooleanValue,omitempty"`
+	IntegerValue string `xml:"l7:IntegerValue,omitempty"`
+	LongValue    string `xml:"l7:LongValue,omitempty"`
+	DateValue    string `xml:"l7:DateValue,omitempty"`
+}
+
+// ParseBundle parses a Restman bundle, or a bundle export (an l7:Item wrapping the bundle). Elements in the
+// gateway management namespace are read whatever prefix the document binds it to.
+func ParseBundle(data []byte) (*Bundle, error) {
+	d := xml.NewTokenDecoder(&bundleTokenReader{d: xml.NewDecoder(bytes.NewReader(data))})
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		start, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "l7:Bundle":
+			b := &Bundle{}
+			if err := d.DecodeElement(b, &start); err != nil {
+				return nil, err
+			}
+			b.XMLNS = bundleNamespace
+			return b, nil
+		case "l7:Item", "l7:Resource":
+			// bundle exports wrap the bundle in an item
+		case "l7:Name", "l7:Type", "l7:TimeStamp", "l7:Link":
+			if err := d.Skip(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("root element is %s, expected l7:Bundle", start.Name.Local)
+		}
+	}
+}
+
+// Marshal serializes the bundle
+func (b *Bundle) Marshal() ([]byte, error) {
+	b.XMLNS = bundleNamespace
+	return xml.Marshal(b)
+}
+
+// bundleTokenReader names elements and attributes in the gateway management namespace l7:<name> so documents
+// using any prefix decode into the bundle model, whose tags are written for the l7 prefix. Namespace
+// declarations are dropped, the l7 prefix is declared on the bundle when it's marshalled.
+type bundleTokenReader struct {
+	d *xml.Decoder
+}
+
+func (r *bundleTokenReader) Token() (xml.Token, error) {
+	t, err := r.d.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := t.(type) {
+	case xml.StartElement:
+		t.Name = l7Name(t.Name)
+		attrs := []xml.Attr{}
+		for _, a := range t.Attr {
+			if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+				continue
+			}
+			attrs = append(attrs, xml.Attr{Name: l7Name(a.Name), Value: a.Value})
+		}
+		t.Attr = attrs
+		return t, nil
+	case xml.EndElement:
+		t.Name = l7Name(t.Name)
+		return t, nil
+	}
+	return t, nil
 }
 
-func randToken(n int) (string, error) {
-	bytes := make([]byte, n)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
+func l7Name(name xml.Name) xml.Name {
+	if name.Space == bundleNamespace {
+		return xml.Name{Local: "l7:" + name.Local}
 	}
-	return hex.EncodeToString(bytes), nil
+	return name
+}
+
+// Entities returns the items of the bundle other than folders, which bundles commonly include to name the
+// folders they're installed to
+func (b *Bundle) Entities() []Item {
+	items := []Item{}
+	for _, item := range b.References.Item {
+		if item.Type != "FOLDER" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ItemKey identifies an item across bundles, its type and id
+func ItemKey(item Item) string {
+	return item.Type + "/" + item.ID
+}
+
+// BundleConflict is an item defined differently by more than one of the bundles merged. Bundles are
+// the indexes of the bundles defining it, the last one's definition is kept.
+type BundleConflict struct {
+	Type    string
+	ID      string
+	Name    string
+	Bundles []int
+}
+
+// MergeBundles combines bundles into one. Items and mappings are identified by type and id and taken from the
+// last bundle defining them, in the position they first appeared. Items defined differently by more than
+// one bundle are returned as conflicts, identical definitions aren't.
+func MergeBundles(bundles ...*Bundle) (*Bundle, []BundleConflict, error) {
+	merged := &Bundle{XMLNS: bundleNamespace}
+	itemIndex := map[string]int{}
+	definitions := map[string][]byte{}
+	owners := map[string][]int{}
+	conflicting := map[string]bool{}
+	mappingIndex := map[string]int{}
+
+	for i, b := range bundles {
+		for _, item := range b.References.Item {
+			key := ItemKey(item)
+			definition, err := xml.Marshal(item.Resource)
+			if err != nil {
+				return nil, nil, err
+			}
+			if j, ok := itemIndex[key]; ok {
+				if !bytes.Equal(definitions[key], definition) {
+					conflicting[key] = true
+				}
+				merged.References.Item[j] = item
+			} else {
+				itemIndex[key] = len(merged.References.Item)
+				merged.References.Item = append(merged.References.Item, item)
+			}
+			definitions[key] = definition
+			if len(owners[key]) == 0 || owners[key][len(owners[key])-1] != i {
+				owners[key] = append(owners[key], i)
+			}
+		}
+		for _, m := range b.Mappings.Mapping {
+			key := m.Type + "/" + m.SrcId
+			if j, ok := mappingIndex[key]; ok {
+				merged.Mappings.Mapping[j] = m
+				continue
+			}
+			mappingIndex[key] = len(merged.Mappings.Mapping)
+			merged.Mappings.Mapping = append(merged.Mappings.Mapping, m)
+		}
+	}
+
+	conflicts := []BundleConflict{}
+	for _, item := range merged.References.Item {
+		key := ItemKey(item)
+		if conflicting[key] {
+			conflicts = append(conflicts, BundleConflict{Type: item.Type, ID: item.ID, Name: item.Name, Bundles: owners[key]})
+		}
+	}
+	return merged, conflicts, nil
+}
+
+// entityId derives a stable 32 character id from an entity type and name so that
+// generated bundles are identical for identical input
+func entityId(entityType string, name string) string {
+	sum := sha1.Sum([]byte(entityType + ":" + name))
+	return hex.EncodeToString(sum[:16])
 }
 
 type ListenPort struct {
@@ -68,17 +259,22 @@ type ListenPort struct {
 	Name            string          `xml:"l7:Name"`
 	Enabled         string          `xml:"l7:Enabled"`
 	Protocol        string          `xml:"l7:Protocol"`
+	Interface       string          `xml:"l7:Interface,omitempty"`
 	Port            string          `xml:"l7:Port"`
 	EnabledFeatures EnabledFeatures `xml:"l7:EnabledFeatures"`
 	TlsSettings     *TlsSettings    `xml:"l7:TlsSettings"`
+	Properties      *Properties     `xml:"l7:Properties,omitempty"`
+	Extra           []Element       `xml:",any"`
 }
 
 type TlsSettings struct {
 	ClientAuthentication string              `xml:"l7:ClientAuthentication"`
+	PrivateKeyId         string              `xml:"l7:PrivateKeyId,omitempty"`
 	EnabledVersions      EnabledVersions     `xml:"l7:EnabledVersions"`
 	EnabledCipherSuites  EnabledCipherSuites `xml:"l7:EnabledCipherSuites"`
 	UseCipherSuitesOrder bool                `xml:"l7:UseCipherSuitesOrder"`
 	Properties           Properties          `xml:"l7:Properties"`
+	Extra                []Element           `xml:",any"`
 }
 
 type EnabledVersions struct {
@@ -93,25 +289,39 @@ type EnabledFeatures struct {
 	StringValue []string `xml:"l7:StringValue"`
 }
 
+// ClusterPropertiesFromSecrets returns true if a cluster property value is read from a Secret, the
+// cluster property bundle is then stored in a Secret
+func ClusterPropertiesFromSecrets(gw *securityv1.Gateway) bool {
+	for _, p := range gw.Spec.App.ClusterProperties.Properties {
+		if p.ValueFrom != nil && p.ValueFrom.SecretKeyRef != nil {
+			return true
+		}
+	}
+	return false
+}
+
 func BuildCWPBundle(cwps map[string]string) ([]byte, error) {
 	refs := References{}
 	items := []Item{}
 	mapping := []Mapping{}
 
-	for cwp, val := range cwps {
-		randomId, err := randToken(16)
+	names := make([]string, 0, len(cwps))
+	for cwp := range cwps {
+		names = append(names, cwp)
+	}
+	sort.Strings(names)
 
-		if err != nil {
-			return nil, err
-		}
+	for _, cwp := range names {
+		val := cwps[cwp]
+		id := entityId("CLUSTER_PROPERTY", cwp)
 
 		resource := Resource{ClusterProperty: &ClusterProperty{
-			ID:    randomId,
+			ID:    id,
 			Name:  cwp,
 			Value: val,
 		}}
 		items = append(items, Item{Name: cwp,
-			ID:       randomId,
+			ID:       id,
 			Type:     "CLUSTER_PROPERTY",
 			Resource: resource,
 		})
@@ -127,9 +337,9 @@ func BuildCWPBundle(cwps map[string]string) ([]byte, error) {
 
 		mapping = append(mapping, Mapping{
 			Action:     "NewOrUpdate",
-			SrcId:      randomId,
+			SrcId:      id,
 			Type:       "CLUSTER_PROPERTY",
-			Properties: Properties{Property: properties},
+			Properties: &Properties{Property: properties},
 		})
 
 		refs.Item = items
@@ -151,237 +361,334 @@ func BuildCWPBundle(cwps map[string]string) ([]byte, error) {
 
 }
 
-func BuildListenPortBundle(cipherSuites []string, tlsVersions []string) ([]byte, error) {
-	trafficId, _ := randToken(16)
-	managementId, _ := randToken(16)
-	plaintextId, _ := randToken(16)
-	internodeCommunicationId, _ := randToken(16)
+// BuildSecurePasswordBundle builds a bundle creating or updating a stored password for each secure password,
+// passwords holds their values by name
+func BuildSecurePasswordBundle(securePasswords []securityv1.SecurePassword, passwords map[string]string) ([]byte, error) {
+	sorted := append([]securityv1.SecurePassword{}, securePasswords...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	bundle := Bundle{XMLNS: bundleNamespace}
+	for _, sp := range sorted {
+		password, ok := passwords[sp.Name]
+		if !ok {
+			return nil, fmt.Errorf("no value for secure password %s", sp.Name)
+		}
+		id := entityId("SECURE_PASSWORD", sp.Name)
+		properties := []Property{{
+			Key:         "description",
+			StringValue: sp.Description,
+		}, {
+			Key:         "type",
+			StringValue: "Password",
+		}, {
+			Key:          "usageFromVariable",
+			BooleanValue: "true",
+		}}
+
+		bundle.References.Item = append(bundle.References.Item, Item{Name: sp.Name,
+			ID:   id,
+			Type: "SECURE_PASSWORD",
+			Resource: Resource{StoredPassword: &StoredPassword{
+				ID:         id,
+				Name:       sp.Name,
+				Password:   &Password{Value: password},
+				Properties: &Properties{Property: properties},
+			}},
+		})
+		bundle.Mappings.Mapping = append(bundle.Mappings.Mapping, Mapping{
+			Action: "NewOrUpdate",
+			SrcId:  id,
+			Type:   "SECURE_PASSWORD",
+			Properties: &Properties{Property: []Property{{
+				Key:         "MapBy",
+				StringValue: "name",
+			}, {
+				Key:         "MapTo",
+				StringValue: sp.Name,
+			}}},
+		})
+	}
+
+	return xml.Marshal(bundle)
+}
+
+// defaultTlsVersions are enabled on HTTPS listen ports when neither the port nor listenPorts set TLS versions
+var defaultTlsVersions = []string{"TLSv1.2", "TLSv1.3"}
+
+// defaultCipherSuites are enabled on HTTPS listen ports when neither the port nor listenPorts set cipher suites
+var defaultCipherSuites = []string{
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+	"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA384",
+	"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA384",
+	"TLS_DHE_RSA_WITH_AES_256_GCM_SHA384",
+	"TLS_DHE_RSA_WITH_AES_256_CBC_SHA256",
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+	"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256",
+	"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256",
+	"TLS_DHE_RSA_WITH_AES_128_GCM_SHA256",
+	"TLS_DHE_RSA_WITH_AES_128_CBC_SHA256",
+	"TLS_AES_256_GCM_SHA384",
+	"TLS_AES_128_GCM_SHA256",
+}
+
+// BuildListenPortBundle builds a bundle with the hardened default listen ports (when harden is set) and any custom listen ports.
+// Custom ports replace default ports with the same name. HTTPS ports fall back to TLSv1.2 and TLSv1.3 with
+// the hardened cipher suites when no TLS versions or cipher suites are set, they wouldn't accept a handshake otherwise.
+func BuildListenPortBundle(listenPorts securityv1.ListenPorts) ([]byte, error) {
+	cipherSuites := listenPorts.CipherSuites
+	if len(cipherSuites) == 0 {
+		cipherSuites = defaultCipherSuites
+	}
+	tlsVersions := listenPorts.TlsVersions
+	if len(tlsVersions) == 0 {
+		tlsVersions = defaultTlsVersions
+	}
+
+	ports := []*ListenPort{}
+	if listenPorts.Harden {
+		ports = hardenedListenPorts(cipherSuites, tlsVersions)
+	}
+
+	for _, c := range listenPorts.Custom {
+		port := customListenPort(c, cipherSuites, tlsVersions)
+		replaced := false
+		for i := range ports {
+			if ports[i].Name == port.Name {
+				ports[i] = port
+				replaced = true
+			}
+		}
+		if !replaced {
+			ports = append(ports, port)
+		}
+	}
 
 	refs := References{}
-	items := []Item{}
 	mapping := []Mapping{}
+	for _, port := range ports {
+		refs.Item = append(refs.Item, Item{
+			Name:     port.Name,
+			ID:       port.ID,
+			Type:     "SSG_CONNECTOR",
+			Resource: Resource{ListenPort: port},
+		})
 
-	plantextPort := Item{
-		Name: "Default HTTP (8080)",
-		ID:   plaintextId,
-		Type: "SSG_CONNECTOR",
-		Resource: Resource{
-			ListenPort: &ListenPort{
-				ID:       plaintextId,
-				Name:     "Default HTTP (8080)",
-				Enabled:  "false",
-				Protocol: "HTTP",
-				Port:     "8080",
-				EnabledFeatures: EnabledFeatures{
-					StringValue: []string{
-						"Published service message input",
-					}},
+		mapping = append(mapping, Mapping{
+			Action: "NewOrUpdate",
+			SrcId:  port.ID,
+			Type:   "SSG_CONNECTOR",
+			Properties: &Properties{Property: []Property{{
+				Key:         "MapBy",
+				StringValue: "name",
+			}, {
+				Key:         "MapTo",
+				StringValue: port.Name,
 			},
-		},
+			}},
+		})
 	}
 
-	items = append(items, plantextPort)
-
-	managementPort := Item{
-		Name: "Default HTTPS (9443)",
-		ID:   trafficId,
-		Type: "SSG_CONNECTOR",
-		Resource: Resource{
-			ListenPort: &ListenPort{
-				ID:       managementId,
-				Name:     "Default HTTPS (9443)",
-				Enabled:  "true",
-				Protocol: "HTTPS",
-				Port:     "9443",
-				EnabledFeatures: EnabledFeatures{
-					StringValue: []string{
-						"Published service message input",
-						"Administrative access",
-						"Browser-based administration",
-						"Built-in services",
-					}},
-				TlsSettings: &TlsSettings{
-					ClientAuthentication: "Optional",
-					EnabledVersions: EnabledVersions{
-						StringValue: tlsVersions,
-					},
-					EnabledCipherSuites: EnabledCipherSuites{
-						StringValue: cipherSuites,
-					},
-					UseCipherSuitesOrder: true,
-					Properties: Properties{
-						Property: []Property{
-							{
-								Key:          "usesTLS",
-								BooleanValue: true,
-							},
-						},
-					},
+	bundle := Bundle{
+		XMLNS:      "http://ns.l7tech.com/2010/04/gateway-management",
+		References: refs,
+		Mappings:   Mappings{Mapping: mapping},
+	}
+
+	bundleBytes, err := xml.Marshal(bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	return bundleBytes, nil
+}
+
+// ListenPortName returns the container and service port name used for a custom listen port
+func ListenPortName(port securityv1.CustomListenPort) string {
+	return "listen-" + strconv.Itoa(int(port.Port))
+}
+
+func customListenPort(c securityv1.CustomListenPort, cipherSuites []string, tlsVersions []string) *ListenPort {
+	features := c.EnabledFeatures
+	if len(features) == 0 {
+		features = []string{"Published service message input"}
+	}
+
+	port := &ListenPort{
+		ID:              entityId("SSG_CONNECTOR", c.Name),
+		Name:            c.Name,
+		Enabled:         "true",
+		Protocol:        strings.ToUpper(c.Protocol),
+		Port:            strconv.Itoa(int(c.Port)),
+		EnabledFeatures: EnabledFeatures{StringValue: features},
+	}
+	if port.Protocol == "" {
+		port.Protocol = "HTTPS"
+	}
+
+	for _, p := range c.Properties {
+		if port.Properties == nil {
+			port.Properties = &Properties{}
+		}
+		port.Properties.Property = append(port.Properties.Property, Property{Key: p.Name, StringValue: p.Value})
+	}
+
+	if port.Protocol != "HTTPS" {
+		return port
+	}
+
+	clientAuthentication := c.ClientAuthentication
+	if clientAuthentication == "" {
+		clientAuthentication = "Optional"
+	}
+	if len(c.CipherSuites) > 0 {
+		cipherSuites = c.CipherSuites
+	}
+	if len(c.TlsVersions) > 0 {
+		tlsVersions = c.TlsVersions
+	}
+
+	port.TlsSettings = &TlsSettings{
+		ClientAuthentication: clientAuthentication,
+		EnabledVersions: EnabledVersions{
+			StringValue: tlsVersions,
+		},
+		EnabledCipherSuites: EnabledCipherSuites{
+			StringValue: cipherSuites,
+		},
+		UseCipherSuitesOrder: true,
+		Properties: Properties{
+			Property: []Property{
+				{
+					Key:          "usesTLS",
+					BooleanValue: "true",
 				},
 			},
 		},
 	}
+	if c.PrivateKeyAlias != "" {
+		// keys in the default Gateway keystore
+		port.TlsSettings.PrivateKeyId = "00000000000000000000000000000002:" + c.PrivateKeyAlias
+	}
+	return port
+}
 
-	items = append(items, managementPort)
-
-	trafficPort := Item{
-		Name: "Default HTTPS (8443)",
-		ID:   trafficId,
-		Type: "SSG_CONNECTOR",
-		Resource: Resource{
-			ListenPort: &ListenPort{
-				ID:       trafficId,
-				Name:     "Default HTTPS (8443)",
-				Enabled:  "true",
-				Protocol: "HTTPS",
-				Port:     "8443",
-				EnabledFeatures: EnabledFeatures{
-					StringValue: []string{
-						"Published service message input",
-					}},
-				TlsSettings: &TlsSettings{
-					ClientAuthentication: "Optional",
-					EnabledVersions: EnabledVersions{
-						StringValue: tlsVersions,
-					},
-					EnabledCipherSuites: EnabledCipherSuites{
-						StringValue: cipherSuites,
-					},
-					UseCipherSuitesOrder: true,
-					Properties: Properties{
-						Property: []Property{
-							{
-								Key:          "usesTLS",
-								BooleanValue: true,
-							},
-						},
+func hardenedListenPorts(cipherSuites []string, tlsVersions []string) []*ListenPort {
+	plaintextPort := &ListenPort{
+		ID:       entityId("SSG_CONNECTOR", "Default HTTP (8080)"),
+		Name:     "Default HTTP (8080)",
+		Enabled:  "false",
+		Protocol: "HTTP",
+		Port:     "8080",
+		EnabledFeatures: EnabledFeatures{
+			StringValue: []string{
+				"Published service message input",
+			}},
+	}
+
+	managementPort := &ListenPort{
+		ID:       entityId("SSG_CONNECTOR", "Default HTTPS (9443)"),
+		Name:     "Default HTTPS (9443)",
+		Enabled:  "true",
+		Protocol: "HTTPS",
+		Port:     "9443",
+		EnabledFeatures: EnabledFeatures{
+			StringValue: []string{
+				"Published service message input",
+				"Administrative access",
+				"Browser-based administration",
+				"Built-in services",
+			}},
+		TlsSettings: &TlsSettings{
+			ClientAuthentication: "Optional",
+			EnabledVersions: EnabledVersions{
+				StringValue: tlsVersions,
+			},
+			EnabledCipherSuites: EnabledCipherSuites{
+				StringValue: cipherSuites,
+			},
+			UseCipherSuitesOrder: true,
+			Properties: Properties{
+				Property: []Property{
+					{
+						Key:          "usesTLS",
+						BooleanValue: "true",
 					},
 				},
 			},
 		},
 	}
 
-	items = append(items, trafficPort)
-
-	internodeCommunicationPort := Item{
-		Name: "Node HTTPS (2124)",
-		ID:   internodeCommunicationId,
-		Type: "SSG_CONNECTOR",
-		Resource: Resource{
-			ListenPort: &ListenPort{
-				ID:       internodeCommunicationId,
-				Name:     "Node HTTPS (2124)",
-				Enabled:  "false",
-				Protocol: "HTTPS",
-				Port:     "2124",
-				EnabledFeatures: EnabledFeatures{
-					StringValue: []string{
-						"Inter-Node Communication",
-					}},
-				TlsSettings: &TlsSettings{
-					ClientAuthentication: "Optional",
-					EnabledVersions: EnabledVersions{
-						StringValue: []string{
-							"TLSv1.2",
-							"TLSv1.3",
-						},
-					},
-					EnabledCipherSuites: EnabledCipherSuites{
-						StringValue: []string{
-							"TLS_DHE_RSA_WITH_AES_256_CBC_SHA",
-							"TLS_RSA_WITH_AES_256_CBC_SHA",
-							"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA",
-							"TLS_DHE_RSA_WITH_AES_128_CBC_SHA",
-							"TLS_RSA_WITH_AES_128_CBC_SHA",
-							"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA",
-						},
-					},
-					UseCipherSuitesOrder: true,
-					Properties: Properties{
-						Property: []Property{
-							{
-								Key:          "usesTLS",
-								BooleanValue: true,
-							},
-						},
+	trafficPort := &ListenPort{
+		ID:       entityId("SSG_CONNECTOR", "Default HTTPS (8443)"),
+		Name:     "Default HTTPS (8443)",
+		Enabled:  "true",
+		Protocol: "HTTPS",
+		Port:     "8443",
+		EnabledFeatures: EnabledFeatures{
+			StringValue: []string{
+				"Published service message input",
+			}},
+		TlsSettings: &TlsSettings{
+			ClientAuthentication: "Optional",
+			EnabledVersions: EnabledVersions{
+				StringValue: tlsVersions,
+			},
+			EnabledCipherSuites: EnabledCipherSuites{
+				StringValue: cipherSuites,
+			},
+			UseCipherSuitesOrder: true,
+			Properties: Properties{
+				Property: []Property{
+					{
+						Key:          "usesTLS",
+						BooleanValue: "true",
 					},
 				},
 			},
 		},
 	}
 
-	items = append(items, internodeCommunicationPort)
-
-	refs.Item = items
-
-	mapping = append(mapping, Mapping{
-		Action: "NewOrUpdate",
-		SrcId:  plaintextId,
-		Type:   "SSG_CONNECTOR",
-		Properties: Properties{Property: []Property{{
-			Key:         "MapBy",
-			StringValue: "name",
-		}, {
-			Key:         "MapTo",
-			StringValue: "Default HTTP (8080)",
-		},
-		}},
-	})
-
-	mapping = append(mapping, Mapping{
-		Action: "NewOrUpdate",
-		SrcId:  managementId,
-		Type:   "SSG_CONNECTOR",
-		Properties: Properties{Property: []Property{{
-			Key:         "MapBy",
-			StringValue: "name",
-		}, {
-			Key:         "MapTo",
-			StringValue: "Default HTTPS (9443)",
-		},
-		}},
-	})
-
-	mapping = append(mapping, Mapping{
-		Action: "NewOrUpdate",
-		SrcId:  trafficId,
-		Type:   "SSG_CONNECTOR",
-		Properties: Properties{Property: []Property{{
-			Key:         "MapBy",
-			StringValue: "name",
-		}, {
-			Key:         "MapTo",
-			StringValue: "Default HTTPS (8443)",
-		},
-		}},
-	})
-
-	mapping = append(mapping, Mapping{
-		Action: "NewOrUpdate",
-		SrcId:  internodeCommunicationId,
-		Type:   "SSG_CONNECTOR",
-		Properties: Properties{Property: []Property{{
-			Key:         "MapBy",
-			StringValue: "name",
-		}, {
-			Key:         "MapTo",
-			StringValue: "Node HTTPS (2124)",
+	internodeCommunicationPort := &ListenPort{
+		ID:       entityId("SSG_CONNECTOR", "Node HTTPS (2124)"),
+		Name:     "Node HTTPS (2124)",
+		Enabled:  "false",
+		Protocol: "HTTPS",
+		Port:     "2124",
+		EnabledFeatures: EnabledFeatures{
+			StringValue: []string{
+				"Inter-Node Communication",
+			}},
+		TlsSettings: &TlsSettings{
+			ClientAuthentication: "Optional",
+			EnabledVersions: EnabledVersions{
+				StringValue: []string{
+					"TLSv1.2",
+					"TLSv1.3",
+				},
+			},
+			EnabledCipherSuites: EnabledCipherSuites{
+				StringValue: []string{
+					"TLS_DHE_RSA_WITH_AES_256_CBC_SHA",
+					"TLS_RSA_WITH_AES_256_CBC_SHA",
+					"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA",
+					"TLS_DHE_RSA_WITH_AES_128_CBC_SHA",
+					"TLS_RSA_WITH_AES_128_CBC_SHA",
+					"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA",
+				},
+			},
+			UseCipherSuitesOrder: true,
+			Properties: Properties{
+				Property: []Property{
+					{
+						Key:          "usesTLS",
+						BooleanValue: "true",
+					},
+				},
+			},
 		},
-		}},
-	})
-
-	mappings := Mappings{Mapping: mapping}
-
-	bundle := Bundle{
-		XMLNS:      "http://ns.l7tech.com/2010/04/gateway-management",
-		References: refs,
-		Mappings:   mappings,
-	}
-
-	bundleBytes, err := xml.Marshal(bundle)
-	if err != nil {
-		return nil, err
 	}
 
-	return bundleBytes, nil
+	return []*ListenPort{plaintextPort, managementPort, trafficPort, internodeCommunicationPort}
 }
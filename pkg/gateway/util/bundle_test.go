@@ -0,0 +1,275 @@
+package util
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+)
+
+func TestBundlesAreDeterministic(t *testing.T) {
+	cwps := map[string]string{"a": "1", "b": "2", "c": "3", "d": "4", "e": "5"}
+	first, err := BuildCWPBundle(cwps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		next, _ := BuildCWPBundle(cwps)
+		if !bytes.Equal(first, next) {
+			t.Fatalf("cluster property bundle changed between builds:\n%s\n%s", first, next)
+		}
+	}
+
+	listenPorts := securityv1.ListenPorts{Harden: true, CipherSuites: []string{"TLS_AES_256_GCM_SHA384"}, TlsVersions: []string{"TLSv1.3"}}
+	ports, _ := BuildListenPortBundle(listenPorts)
+	next, _ := BuildListenPortBundle(listenPorts)
+	if !bytes.Equal(ports, next) {
+		t.Fatal("listen port bundle changed between builds")
+	}
+}
+
+func TestCustomListenPorts(t *testing.T) {
+	listenPorts := securityv1.ListenPorts{
+		Harden:      true,
+		TlsVersions: []string{"TLSv1.2"},
+		Custom: []securityv1.CustomListenPort{{
+			Name:                 "Default HTTPS (8443)",
+			Port:                 8443,
+			ClientAuthentication: "Required",
+		}, {
+			Name:            "mTLS (8444)",
+			Port:            8444,
+			PrivateKeyAlias: "api",
+			Properties:      []securityv1.ListenPortProperty{{Name: "threadPoolSize", Value: "20"}},
+		}},
+	}
+
+	b, err := BuildListenPortBundle(listenPorts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle := string(b)
+	if strings.Count(bundle, "<l7:Mapping ") != 5 {
+		t.Fatalf("expected 5 listen ports, got %s", bundle)
+	}
+	for _, s := range []string{
+		"<l7:ClientAuthentication>Required</l7:ClientAuthentication>",
+		"<l7:PrivateKeyId>00000000000000000000000000000002:api</l7:PrivateKeyId>",
+		`<l7:Property key="threadPoolSize"><l7:StringValue>20</l7:StringValue></l7:Property>`,
+		"<l7:Port>8444</l7:Port>",
+	} {
+		if !strings.Contains(bundle, s) {
+			t.Fatalf("expected %s in %s", s, bundle)
+		}
+	}
+
+	// HTTPS ports need TLS versions and cipher suites to accept a handshake
+	b, err = BuildListenPortBundle(securityv1.ListenPorts{Custom: []securityv1.CustomListenPort{{Name: "HTTPS (8445)", Port: 8445}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseBundle(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tls := parsed.References.Item[0].Resource.ListenPort.TlsSettings
+	if !reflect.DeepEqual(tls.EnabledVersions.StringValue, defaultTlsVersions) || !reflect.DeepEqual(tls.EnabledCipherSuites.StringValue, defaultCipherSuites) {
+		t.Fatalf("expected the default TLS versions and cipher suites, got %+v", tls)
+	}
+}
+
+func TestSecurePasswordBundle(t *testing.T) {
+	securePasswords := []securityv1.SecurePassword{
+		{Name: "db", SecretName: "backend", Key: "db-password", Description: "backend database"},
+		{Name: "api", SecretName: "backend", Key: "api-key"},
+	}
+	b, err := BuildSecurePasswordBundle(securePasswords, map[string]string{"db": "s3cr3t", "api": "k3y"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := ValidateBundle(b); len(errs) > 0 {
+		t.Fatalf("generated bundle is invalid: %v", errs)
+	}
+	bundle, err := ParseBundle(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bundle.References.Item) != 2 || bundle.References.Item[0].Name != "api" {
+		t.Fatalf("expected stored passwords api and db, got %+v", bundle.References.Item)
+	}
+	db := bundle.References.Item[1].Resource.StoredPassword
+	if db == nil || db.Password.Value != "s3cr3t" || db.Properties.Get("description") != "backend database" {
+		t.Fatalf("unexpected stored password %+v", db)
+	}
+
+	if _, err := BuildSecurePasswordBundle(securePasswords, map[string]string{"db": "s3cr3t"}); err == nil {
+		t.Fatal("expected an error for a secure password without a value")
+	}
+}
+
+func TestValidateBundle(t *testing.T) {
+	cwps, _ := BuildCWPBundle(map[string]string{"a": "1"})
+	ports, _ := BuildListenPortBundle(securityv1.ListenPorts{Harden: true})
+	for _, b := range [][]byte{cwps, ports} {
+		if problems := ValidateBundle(b); len(problems) > 0 {
+			t.Fatalf("generated bundle is invalid: %v", problems)
+		}
+	}
+
+	item := `<l7:Item><l7:Name>a</l7:Name><l7:Id>1</l7:Id><l7:Type>CLUSTER_PROPERTY</l7:Type></l7:Item>`
+	bundle := func(items string, mappings string) []byte {
+		return []byte(`<l7:Bundle xmlns:l7="http://ns.l7tech.com/2010/04/gateway-management"><l7:References>` + items +
+			`</l7:References><l7:Mappings>` + mappings + `</l7:Mappings></l7:Bundle>`)
+	}
+	cases := map[string][]byte{
+		"malformed":     []byte(`<l7:Bundle xmlns:l7="http://ns.l7tech.com/2010/04/gateway-management"><l7:References>`),
+		"wrong root":    []byte(`<Bundle/>`),
+		"duplicate id":  bundle(item+item, `<l7:Mapping action="NewOrUpdate" srcId="1" type="CLUSTER_PROPERTY"/>`),
+		"dangling":      bundle(item, `<l7:Mapping action="NewOrUpdate" srcId="2" type="CLUSTER_PROPERTY"/>`),
+		"duplicate map": bundle(item, `<l7:Mapping action="NewOrUpdate" srcId="1" type="CLUSTER_PROPERTY"/><l7:Mapping action="Delete" srcId="1" type="CLUSTER_PROPERTY"/>`),
+	}
+	for name, b := range cases {
+		if problems := ValidateBundle(b); len(problems) != 1 {
+			t.Errorf("%s: expected one problem, got %v", name, problems)
+		}
+	}
+
+	existing := bundle(item, `<l7:Mapping action="NewOrExisting" srcId="2" type="FOLDER"><l7:Properties><l7:Property key="FailOnNew"><l7:BooleanValue>true</l7:BooleanValue></l7:Property></l7:Properties></l7:Mapping>`)
+	if problems := ValidateBundle(existing); len(problems) > 0 {
+		t.Fatalf("mappings to existing entities don't need an item: %v", problems)
+	}
+}
+
+const testBundle = `<?xml version="1.0" encoding="UTF-8"?>
+<l7:Item xmlns:l7="http://ns.l7tech.com/2010/04/gateway-management">
+  <l7:Name>Bundle</l7:Name><l7:Type>BUNDLE</l7:Type>
+  <l7:Resource><l7:Bundle><l7:References>
+    <l7:Item><l7:Name>apis</l7:Name><l7:Id>f1</l7:Id><l7:Type>FOLDER</l7:Type><l7:Resource>
+      <l7:Folder folderId="0000000000000000ffffffffffffec76" id="f1"><l7:Name>apis</l7:Name></l7:Folder></l7:Resource></l7:Item>
+    <l7:Item><l7:Name>echo</l7:Name><l7:Id>s1</l7:Id><l7:Type>SERVICE</l7:Type><l7:Resource>
+      <l7:Service id="s1"><l7:ServiceDetail folderId="f1" id="s1"><l7:Name>echo</l7:Name><l7:Enabled>true</l7:Enabled>
+        <l7:ServiceMappings><l7:HttpMapping><l7:UrlPattern>/echo</l7:UrlPattern><l7:Verbs><l7:Verb>GET</l7:Verb></l7:Verbs></l7:HttpMapping></l7:ServiceMappings>
+        <l7:Properties><l7:Property key="soap"><l7:BooleanValue>false</l7:BooleanValue></l7:Property></l7:Properties></l7:ServiceDetail>
+      <l7:Resources><l7:ResourceSet tag="policy"><l7:Resource type="policy">&lt;wsp:Policy/&gt;</l7:Resource></l7:ResourceSet></l7:Resources></l7:Service></l7:Resource></l7:Item>
+    <l7:Item><l7:Name>db</l7:Name><l7:Id>j1</l7:Id><l7:Type>JDBC_CONNECTION</l7:Type><l7:Resource>
+      <l7:JDBCConnection id="j1"><l7:Name>db</l7:Name><l7:Enabled>true</l7:Enabled>
+        <l7:Extension><l7:DriverClass>org.mariadb.jdbc.Driver</l7:DriverClass><l7:JdbcUrl>jdbc:mysql://db:3306/ssg</l7:JdbcUrl></l7:Extension></l7:JDBCConnection></l7:Resource></l7:Item>
+    <l7:Item><l7:Name>nightly</l7:Name><l7:Id>t1</l7:Id><l7:Type>SCHEDULED_TASK</l7:Type><l7:Resource>
+      <l7:ScheduledTask id="t1"><l7:Name>nightly</l7:Name><l7:PolicyReference id="p1"/><l7:OneNode>true</l7:OneNode><l7:JobType>Recurring</l7:JobType>
+        <l7:CronExpression>0 0 * * *</l7:CronExpression><l7:JobStatus>Scheduled</l7:JobStatus><l7:UserId>admin</l7:UserId></l7:ScheduledTask></l7:Resource></l7:Item>
+    <l7:Item><l7:Name>jms</l7:Name><l7:Id>a1</l7:Id><l7:Type>SSG_ACTIVE_CONNECTOR</l7:Type><l7:Resource>
+      <l7:ActiveConnector id="a1"><l7:Name>jms</l7:Name><l7:Enabled>true</l7:Enabled></l7:ActiveConnector></l7:Resource></l7:Item>
+  </l7:References><l7:Mappings>
+    <l7:Mapping action="NewOrExisting" srcId="0000000000000000ffffffffffffec76" type="FOLDER"><l7:Properties><l7:Property key="FailOnNew"><l7:BooleanValue>true</l7:BooleanValue></l7:Property></l7:Properties></l7:Mapping>
+    <l7:Mapping action="NewOrUpdate" srcId="s1" type="SERVICE"/>
+  </l7:Mappings></l7:Bundle></l7:Resource>
+</l7:Item>`
+
+func TestParseBundle(t *testing.T) {
+	b, err := ParseBundle([]byte(testBundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := b.References.Item
+	if len(items) != 5 || items[1].Resource.Service.Detail.ServiceMappings.HttpMapping.UrlPattern != "/echo" ||
+		items[2].Resource.JDBCConnection.Extension.JdbcUrl != "jdbc:mysql://db:3306/ssg" ||
+		items[3].Resource.ScheduledTask.CronExpression != "0 0 * * *" || len(items[3].Resource.ScheduledTask.Extra) != 1 ||
+		len(items[4].Resource.Other) != 1 || len(b.Mappings.Mapping) != 2 {
+		t.Fatalf("unexpected bundle %+v", b)
+	}
+	if entities := b.Entities(); len(entities) != 4 || ItemKey(entities[0]) != "SERVICE/s1" {
+		t.Fatalf("expected every item but the folder, got %+v", entities)
+	}
+
+	data, err := b.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems := ValidateBundle(data); len(problems) > 0 {
+		t.Fatalf("serialized bundle is invalid: %v", problems)
+	}
+	reparsed, err := ParseBundle(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := reparsed.Marshal()
+	if !bytes.Equal(data, again) {
+		t.Fatalf("bundle changed in a round trip:\n%s\n%s", data, again)
+	}
+	for _, s := range []string{"<l7:UserId>admin</l7:UserId>", `<l7:ActiveConnector id="a1">`, "&lt;wsp:Policy/&gt;", "<l7:BooleanValue>false</l7:BooleanValue>"} {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("serialized bundle is missing %s", s)
+		}
+	}
+
+	if _, err := ParseBundle([]byte(`<Bundle/>`)); err == nil {
+		t.Fatal("expected a bundle without the gateway management namespace to be rejected")
+	}
+}
+
+func TestMergeBundles(t *testing.T) {
+	first, _ := BuildCWPBundle(map[string]string{"a": "1", "b": "2"})
+	second, _ := BuildCWPBundle(map[string]string{"b": "3", "c": "4"})
+	third, _ := BuildCWPBundle(map[string]string{"c": "4"})
+	var bundles []*Bundle
+	for _, data := range [][]byte{first, second, third} {
+		b, err := ParseBundle(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bundles = append(bundles, b)
+	}
+
+	merged, conflicts, err := MergeBundles(bundles...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := merged.References.Item
+	if len(items) != 3 || items[1].Name != "b" || items[1].Resource.ClusterProperty.Value != "3" || len(merged.Mappings.Mapping) != 3 {
+		t.Fatalf("unexpected merged bundle %+v", merged)
+	}
+	if len(conflicts) != 1 || conflicts[0].Name != "b" || len(conflicts[0].Bundles) != 2 || conflicts[0].Bundles[1] != 1 {
+		t.Fatalf("unexpected conflicts %+v", conflicts)
+	}
+}
+
+func TestGraphmanConversion(t *testing.T) {
+	b, err := ParseBundle([]byte(testBundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ToGraphman(b); err == nil || !strings.Contains(err.Error(), "JDBC_CONNECTION") {
+		t.Fatalf("expected the JDBC connection not to convert, got %v", err)
+	}
+	b.References.Item = b.References.Item[:2]
+	cwps, _ := BuildCWPBundle(map[string]string{"cwp.one": "1"})
+	cwpBundle, _ := ParseBundle(cwps)
+	b.References.Item = append(b.References.Item, cwpBundle.References.Item...)
+
+	gb, err := ToGraphman(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gb["services"]) != 1 || gb["services"][0]["folderPath"] != "/apis" || gb["services"][0]["resolutionPath"] != "/echo" ||
+		len(gb["clusterProperties"]) != 1 || gb["clusterProperties"][0]["value"] != "1" {
+		t.Fatalf("unexpected graphman bundle %v", gb)
+	}
+
+	restman, err := FromGraphman(gb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := restman.Marshal()
+	if problems := ValidateBundle(data); len(problems) > 0 {
+		t.Fatalf("converted bundle is invalid: %v\n%s", problems, data)
+	}
+	back, err := ToGraphman(restman)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, gb) {
+		t.Fatalf("bundle changed converting back:\n%v\n%v", gb, back)
+	}
+}
@@ -0,0 +1,102 @@
+package util
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+)
+
+// ParseCertificatePEM returns the first certificate in PEM data
+func ParseCertificatePEM(data []byte) (*x509.Certificate, error) {
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return nil, errors.New("no PEM certificate found")
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
+
+// BuildTrustedCertBundle builds a bundle creating or updating each trusted certificate, certs holds
+// their parsed certificates by name
+func BuildTrustedCertBundle(trustedCerts []securityv1.TrustedCert, certs map[string]*x509.Certificate) ([]byte, error) {
+	sorted := append([]securityv1.TrustedCert{}, trustedCerts...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	bundle := Bundle{XMLNS: bundleNamespace}
+	for i, tc := range sorted {
+		if i > 0 && sorted[i-1].Name == tc.Name {
+			return nil, fmt.Errorf("trusted certificate %s is listed more than once", tc.Name)
+		}
+		cert, ok := certs[tc.Name]
+		if !ok {
+			return nil, fmt.Errorf("trusted certificate %s has no certificate", tc.Name)
+		}
+
+		uses := map[string]bool{}
+		for _, use := range tc.TrustedFor {
+			uses[use] = true
+		}
+		revocation := false
+		switch tc.RevocationCheckPolicyType {
+		case "", "NONE":
+		case "USE_DEFAULT":
+			revocation = true
+		default:
+			return nil, fmt.Errorf("trusted certificate %s: unknown revocationCheckPolicyType %s", tc.Name, tc.RevocationCheckPolicyType)
+		}
+
+		properties := []Property{
+			{Key: "revocationCheckingEnabled", BooleanValue: fmt.Sprint(revocation)},
+			{Key: "trustAnchor", BooleanValue: fmt.Sprint(tc.TrustAnchor)},
+		}
+		for _, t := range trustedFor {
+			properties = append(properties, Property{Key: t.property, BooleanValue: fmt.Sprint(uses[t.value])})
+			delete(uses, t.value)
+		}
+		for use := range uses {
+			return nil, fmt.Errorf("trusted certificate %s: unknown trustedFor %s", tc.Name, use)
+		}
+		properties = append(properties, Property{Key: "verifyHostname", BooleanValue: fmt.Sprint(tc.VerifyHostname)})
+
+		id := entityId("TRUSTED_CERT", tc.Name)
+		bundle.References.Item = append(bundle.References.Item, Item{Name: tc.Name,
+			ID:   id,
+			Type: "TRUSTED_CERT",
+			Resource: Resource{TrustedCertificate: &TrustedCertificate{
+				ID:   id,
+				Name: tc.Name,
+				CertificateData: CertificateData{
+					IssuerName:   cert.Issuer.String(),
+					SerialNumber: cert.SerialNumber.String(),
+					SubjectName:  cert.Subject.String(),
+					Encoded:      base64.StdEncoding.EncodeToString(cert.Raw),
+				},
+				Properties: &Properties{Property: properties},
+			}},
+		})
+		bundle.Mappings.Mapping = append(bundle.Mappings.Mapping, Mapping{
+			Action: "NewOrUpdate",
+			SrcId:  id,
+			Type:   "TRUSTED_CERT",
+			Properties: &Properties{Property: []Property{{
+				Key:         "MapBy",
+				StringValue: "name",
+			}, {
+				Key:         "MapTo",
+				StringValue: tc.Name,
+			}}},
+		})
+	}
+
+	return xml.Marshal(bundle)
+}
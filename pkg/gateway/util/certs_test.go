@@ -0,0 +1,70 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestTrustedCertBundle(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	data := tlsSecretData(t, "backend.example.com", key, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	cert, err := ParseCertificatePEM(data[corev1.TLSCertKey])
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs := map[string]*x509.Certificate{"backend": cert}
+
+	trustedCerts := []securityv1.TrustedCert{{
+		Name:                      "backend",
+		ConfigMapName:             "backend-ca",
+		Key:                       "ca.crt",
+		TrustedFor:                []string{"SSL", "SAML_ISSUER"},
+		TrustAnchor:               true,
+		RevocationCheckPolicyType: "USE_DEFAULT",
+	}}
+	b, err := BuildTrustedCertBundle(trustedCerts, certs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := ValidateBundle(b); len(errs) > 0 {
+		t.Fatalf("generated bundle is invalid: %v", errs)
+	}
+	bundle, err := ParseBundle(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := bundle.References.Item[0].Resource.TrustedCertificate
+	if tc == nil || tc.CertificateData.SubjectName != "CN=backend.example.com" {
+		t.Fatalf("unexpected trusted certificate %+v", tc)
+	}
+	for key, value := range map[string]string{
+		"trustedForSsl":                "true",
+		"trustedAsSamlIssuer":          "true",
+		"trustedForSigningClientCerts": "false",
+		"trustAnchor":                  "true",
+		"revocationCheckingEnabled":    "true",
+		"verifyHostname":               "false",
+	} {
+		if got := tc.Properties.Get(key); got != value {
+			t.Fatalf("expected %s to be %s, got %s", key, value, got)
+		}
+	}
+
+	trustedCerts[0].TrustedFor = []string{"SSL_CLIENT"}
+	if _, err := BuildTrustedCertBundle(trustedCerts, certs); err == nil {
+		t.Fatal("expected an error for an unknown trustedFor")
+	}
+	trustedCerts[0].TrustedFor = nil
+	if _, err := BuildTrustedCertBundle(trustedCerts, map[string]*x509.Certificate{}); err == nil {
+		t.Fatal("expected an error for a missing certificate")
+	}
+	if _, err := ParseCertificatePEM([]byte("not a certificate")); err == nil {
+		t.Fatal("expected an error for data without a certificate")
+	}
+}
@@ -0,0 +1,213 @@
+package util
+
+import "encoding/xml"
+
+// Element is an XML element the bundle model doesn't describe, kept so parsed bundles are written back with it
+type Element struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Text     string     `xml:",chardata"`
+	Children []Element  `xml:",any"`
+}
+
+// Reference refers to another entity in the bundle or on the Gateway by id
+type Reference struct {
+	ID string `xml:"id,attr"`
+}
+
+// RootFolderID is the id of the root folder of every Gateway
+const RootFolderID = "0000000000000000ffffffffffffec76"
+
+type Folder struct {
+	ID         string      `xml:"id,attr"`
+	FolderID   string      `xml:"folderId,attr,omitempty"`
+	Version    string      `xml:"version,attr,omitempty"`
+	Name       string      `xml:"l7:Name"`
+	Properties *Properties `xml:"l7:Properties,omitempty"`
+	Extra      []Element   `xml:",any"`
+}
+
+// ResourceSet holds the policy XML of services and policies, and the WSDL of SOAP services
+type ResourceSet struct {
+	Tag       string             `xml:"tag,attr"`
+	RootURL   string             `xml:"rootUrl,attr,omitempty"`
+	Resources []ResourceDocument `xml:"l7:Resource"`
+}
+
+type ResourceDocument struct {
+	Type      string `xml:"type,attr"`
+	SourceURL string `xml:"sourceUrl,attr,omitempty"`
+	Version   string `xml:"version,attr,omitempty"`
+	Content   string `xml:",chardata"`
+}
+
+type Service struct {
+	ID        string        `xml:"id,attr"`
+	Version   string        `xml:"version,attr,omitempty"`
+	Detail    ServiceDetail `xml:"l7:ServiceDetail"`
+	Resources []ResourceSet `xml:"l7:Resources>l7:ResourceSet"`
+	Extra     []Element     `xml:",any"`
+}
+
+type ServiceDetail struct {
+	ID              string          `xml:"id,attr,omitempty"`
+	FolderID        string          `xml:"folderId,attr,omitempty"`
+	Version         string          `xml:"version,attr,omitempty"`
+	Name            string          `xml:"l7:Name"`
+	Enabled         string          `xml:"l7:Enabled"`
+	ServiceMappings ServiceMappings `xml:"l7:ServiceMappings"`
+	Properties      *Properties     `xml:"l7:Properties,omitempty"`
+	Extra           []Element       `xml:",any"`
+}
+
+type ServiceMappings struct {
+	HttpMapping *HttpMapping `xml:"l7:HttpMapping,omitempty"`
+	Extra       []Element    `xml:",any"`
+}
+
+type HttpMapping struct {
+	UrlPattern string   `xml:"l7:UrlPattern"`
+	Verbs      []string `xml:"l7:Verbs>l7:Verb"`
+}
+
+type Policy struct {
+	ID        string        `xml:"id,attr"`
+	Guid      string        `xml:"guid,attr,omitempty"`
+	Version   string        `xml:"version,attr,omitempty"`
+	Detail    PolicyDetail  `xml:"l7:PolicyDetail"`
+	Resources []ResourceSet `xml:"l7:Resources>l7:ResourceSet"`
+	Extra     []Element     `xml:",any"`
+}
+
+type PolicyDetail struct {
+	ID         string      `xml:"id,attr,omitempty"`
+	Guid       string      `xml:"guid,attr,omitempty"`
+	FolderID   string      `xml:"folderId,attr,omitempty"`
+	Version    string      `xml:"version,attr,omitempty"`
+	Name       string      `xml:"l7:Name"`
+	PolicyType string      `xml:"l7:PolicyType"`
+	Properties *Properties `xml:"l7:Properties,omitempty"`
+	Extra      []Element   `xml:",any"`
+}
+
+type EncapsulatedAssertion struct {
+	ID              string                          `xml:"id,attr"`
+	Version         string                          `xml:"version,attr,omitempty"`
+	Name            string                          `xml:"l7:Name"`
+	Guid            string                          `xml:"l7:Guid"`
+	PolicyReference Reference                       `xml:"l7:PolicyReference"`
+	Arguments       []EncapsulatedAssertionArgument `xml:"l7:EncapsulatedArguments>l7:EncapsulatedAssertionArgument"`
+	Results         []EncapsulatedAssertionResult   `xml:"l7:EncapsulatedResults>l7:EncapsulatedAssertionResult"`
+	Properties      *Properties                     `xml:"l7:Properties,omitempty"`
+	Extra           []Element                       `xml:",any"`
+}
+
+type EncapsulatedAssertionArgument struct {
+	Ordinal      string `xml:"l7:Ordinal"`
+	ArgumentName string `xml:"l7:ArgumentName"`
+	ArgumentType string `xml:"l7:ArgumentType"`
+	GuiLabel     string `xml:"l7:GuiLabel,omitempty"`
+	GuiPrompt    string `xml:"l7:GuiPrompt"`
+}
+
+type EncapsulatedAssertionResult struct {
+	ResultName string `xml:"l7:ResultName"`
+	ResultType string `xml:"l7:ResultType"`
+}
+
+type JDBCConnection struct {
+	ID         string         `xml:"id,attr"`
+	Version    string         `xml:"version,attr,omitempty"`
+	Name       string         `xml:"l7:Name"`
+	Enabled    string         `xml:"l7:Enabled"`
+	Properties *Properties    `xml:"l7:Properties,omitempty"`
+	Extension  *JDBCExtension `xml:"l7:Extension,omitempty"`
+	Extra      []Element      `xml:",any"`
+}
+
+type JDBCExtension struct {
+	DriverClass          string      `xml:"l7:DriverClass"`
+	JdbcUrl              string      `xml:"l7:JdbcUrl"`
+	ConnectionProperties *Properties `xml:"l7:ConnectionProperties,omitempty"`
+	Extra                []Element   `xml:",any"`
+}
+
+// StoredPassword is a secure password, Password is plain text unless it's encrypted with a BundleKey
+type StoredPassword struct {
+	ID         string      `xml:"id,attr"`
+	Version    string      `xml:"version,attr,omitempty"`
+	Name       string      `xml:"l7:Name"`
+	Password   *Password   `xml:"l7:Password,omitempty"`
+	Properties *Properties `xml:"l7:Properties,omitempty"`
+	Extra      []Element   `xml:",any"`
+}
+
+type Password struct {
+	BundleKey string `xml:"bundleKey,attr,omitempty"`
+	Value     string `xml:",chardata"`
+}
+
+type PrivateKey struct {
+	ID               string            `xml:"id,attr"`
+	Alias            string            `xml:"alias,attr,omitempty"`
+	KeystoreID       string            `xml:"keystoreId,attr,omitempty"`
+	Version          string            `xml:"version,attr,omitempty"`
+	CertificateChain []CertificateData `xml:"l7:CertificateChain>l7:CertificateData"`
+	Properties       *Properties       `xml:"l7:Properties,omitempty"`
+	Extra            []Element         `xml:",any"`
+}
+
+// CertificateData is a certificate, Encoded is its base64 DER encoding
+type CertificateData struct {
+	IssuerName   string `xml:"l7:IssuerName,omitempty"`
+	SerialNumber string `xml:"l7:SerialNumber,omitempty"`
+	SubjectName  string `xml:"l7:SubjectName,omitempty"`
+	Encoded      string `xml:"l7:Encoded"`
+}
+
+type TrustedCertificate struct {
+	ID              string          `xml:"id,attr"`
+	Version         string          `xml:"version,attr,omitempty"`
+	Name            string          `xml:"l7:Name"`
+	CertificateData CertificateData `xml:"l7:CertificateData"`
+	Properties      *Properties     `xml:"l7:Properties,omitempty"`
+	Extra           []Element       `xml:",any"`
+}
+
+// IdentityProvider keeps the provider type specific settings (e.g. LDAP) in Extra
+type IdentityProvider struct {
+	ID                   string      `xml:"id,attr"`
+	Version              string      `xml:"version,attr,omitempty"`
+	Name                 string      `xml:"l7:Name"`
+	IdentityProviderType string      `xml:"l7:IdentityProviderType"`
+	Properties           *Properties `xml:"l7:Properties,omitempty"`
+	Extra                []Element   `xml:",any"`
+}
+
+type ScheduledTask struct {
+	ID              string      `xml:"id,attr"`
+	Version         string      `xml:"version,attr,omitempty"`
+	Name            string      `xml:"l7:Name"`
+	PolicyReference Reference   `xml:"l7:PolicyReference"`
+	OneNode         string      `xml:"l7:OneNode"`
+	JobType         string      `xml:"l7:JobType"`
+	CronExpression  string      `xml:"l7:CronExpression,omitempty"`
+	ExecuteOnCreate string      `xml:"l7:ExecuteOnCreate,omitempty"`
+	ExecutionDate   string      `xml:"l7:ExecutionDate,omitempty"`
+	JobStatus       string      `xml:"l7:JobStatus"`
+	Properties      *Properties `xml:"l7:Properties,omitempty"`
+	Extra           []Element   `xml:",any"`
+}
+
+// Get returns the value of the property with key, or "" if it isn't set
+func (p *Properties) Get(key string) string {
+	if p == nil {
+		return ""
+	}
+	for _, prop := range p.Property {
+		if prop.Key == key {
+			return prop.StringValue + prop.BooleanValue + prop.IntegerValue + prop.LongValue + prop.DateValue
+		}
+	}
+	return ""
+}
@@ -1,25 +1,659 @@
 package util
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitclient "github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
 )
 
-func GetLatestCommit(url string) (string, error) {
-	r, err := git.Clone(memory.NewStorage(), nil, &git.CloneOptions{
-		URL: url,
-	})
+// GitCredentials are read from the Repository Secret. Token takes precedence over Password for HTTPS
+// and SSHPrivateKey is used with KnownHosts verification for SSH. AccessKeyID and SecretAccessKey are
+// only used for archives in S3 compatible storage and DockerConfig for OCI registries.
+type GitCredentials struct {
+	Username        string
+	Password        string
+	Token           string
+	SSHPrivateKey   []byte
+	SSHPassphrase   string
+	KnownHosts      []byte
+	AccessKeyID     string
+	SecretAccessKey string
+	DockerConfig    []byte
+}
+
+// Git credential keys in the Repository Secret, matching the kubernetes.io/basic-auth and kubernetes.io/ssh-auth Secret types
+const (
+	GitUsernameKey      = "username"
+	GitPasswordKey      = "password"
+	GitTokenKey         = "token"
+	GitSSHPrivateKeyKey = "ssh-privatekey"
+	GitSSHPassphraseKey = "ssh-passphrase"
+	GitKnownHostsKey    = "known_hosts"
+)
+
+// NewGitCredentials reads git credentials from Secret data
+func NewGitCredentials(data map[string][]byte) *GitCredentials {
+	return &GitCredentials{
+		Username:        string(data[GitUsernameKey]),
+		Password:        string(data[GitPasswordKey]),
+		Token:           string(data[GitTokenKey]),
+		SSHPrivateKey:   data[GitSSHPrivateKeyKey],
+		SSHPassphrase:   string(data[GitSSHPassphraseKey]),
+		KnownHosts:      data[GitKnownHostsKey],
+		AccessKeyID:     string(data[S3AccessKeyIDKey]),
+		SecretAccessKey: string(data[S3SecretAccessKeyKey]),
+		DockerConfig:    data[DockerConfigJSONKey],
+	}
+}
+
+// IsGitAuthError returns true if err was caused by the repository rejecting the credentials or
+// the SSH host key failing verification
+func IsGitAuthError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, transport.ErrAuthenticationRequired) || errors.Is(err, transport.ErrAuthorizationFailed) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "ssh: handshake failed") || strings.Contains(msg, "unable to authenticate") || strings.Contains(msg, "knownhosts")
+}
+
+func (c *GitCredentials) authMethod(url string) (transport.AuthMethod, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	if len(c.SSHPrivateKey) > 0 {
+		if len(c.KnownHosts) == 0 {
+			return nil, errors.New("ssh: known_hosts is required to verify the repository host key")
+		}
+		user := "git"
+		if ep, err := transport.NewEndpoint(url); err == nil && ep.User != "" {
+			user = ep.User
+		}
+		auth, err := gitssh.NewPublicKeys(user, c.SSHPrivateKey, c.SSHPassphrase)
+		if err != nil {
+			return nil, err
+		}
+		if err := setKnownHosts(auth, c.KnownHosts); err != nil {
+			return nil, err
+		}
+		return auth, nil
+	}
+
+	password := c.Password
+	if c.Token != "" {
+		password = c.Token
+	}
+	if password == "" {
+		return nil, nil
+	}
+	username := c.Username
+	if username == "" {
+		// token authentication ignores the username but it can't be empty
+		username = "git"
+	}
+	return &githttp.BasicAuth{Username: username, Password: password}, nil
+}
+
+// setKnownHosts verifies host keys against known_hosts data. The known_hosts parser only reads files
+// so the data is written to a temporary file that is removed once it has been parsed.
+func setKnownHosts(auth *gitssh.PublicKeys, knownHosts []byte) error {
+	f, err := os.CreateTemp("", "known_hosts")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(knownHosts); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	auth.HostKeyCallback, err = gitssh.NewKnownHostsCallback(f.Name())
+	return err
+}
+
+// GitReference selects the commit to use from a repository. Commit takes precedence over Tag, and Tag over Branch.
+// The remote's default branch is used when none are set.
+type GitReference struct {
+	Branch string
+	Tag    string
+	Commit string
+}
+
+// Name returns the ref that the reference resolves, e.g. refs/heads/main
+func (ref GitReference) Name() string {
+	switch {
+	case ref.Commit != "":
+		return ref.Commit
+	case ref.Tag != "":
+		return plumbing.NewTagReferenceName(ref.Tag).String()
+	case ref.Branch != "":
+		return plumbing.NewBranchReferenceName(ref.Branch).String()
+	}
+	return plumbing.HEAD.String()
+}
+
+// Validate returns an error if Commit is set to anything but a full 40 character SHA, abbreviated commits
+// can't be fetched from a remote
+func (ref GitReference) Validate() error {
+	if ref.Commit != "" && !plumbing.IsHash(ref.Commit) {
+		return fmt.Errorf("commit %s must be a full 40 character SHA", ref.Commit)
+	}
+	return nil
+}
 
+// GetLatestCommit returns the commit that ref currently resolves to. Like git ls-remote it only lists the
+// references advertised by the repository so nothing is cloned. Pinned commits are returned as they are once
+// the repository has accepted the credentials.
+func GetLatestCommit(url string, creds *GitCredentials, ref GitReference) (string, error) {
+	if err := ref.Validate(); err != nil {
+		return "", err
+	}
+	auth, err := creds.authMethod(url)
 	if err != nil {
 		return "", err
 	}
+	ep, err := transport.NewEndpoint(url)
+	if err != nil {
+		return "", err
+	}
+	c, err := gitclient.NewClient(ep)
+	if err != nil {
+		return "", err
+	}
+	s, err := c.NewUploadPackSession(ep, auth)
+	if err != nil {
+		return "", err
+	}
+	defer s.Close()
 
-	ref, _ := r.Head()
-	commit, err := r.CommitObject(ref.Hash())
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	ar, err := s.AdvertisedReferencesContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	if ref.Commit != "" {
+		return ref.Commit, nil
+	}
 
+	refs, err := ar.AllReferences()
 	if err != nil {
 		return "", err
 	}
+	resolved, err := storer.ResolveReference(refs, plumbing.ReferenceName(ref.Name()))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", ref.Name(), err)
+	}
+	// annotated tags are advertised with the tag object, the commit is advertised as the peeled ref
+	if peeled, ok := ar.Peeled[resolved.Name().String()]; ok {
+		return peeled.String(), nil
+	}
+	return resolved.Hash().String(), nil
+}
+
+// RepositoryCache keeps bare clones of repositories on disk so that each commit is only fetched once
+// and its content doesn't need to be held in memory. Clones are removed by Prune once no user needs them.
+type RepositoryCache struct {
+	dir string
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+	users []func() []string
+}
+
+// NewRepositoryCache returns a RepositoryCache that stores repositories under dir
+func NewRepositoryCache(dir string) *RepositoryCache {
+	return &RepositoryCache{dir: dir, locks: map[string]*sync.Mutex{}}
+}
+
+// Checkout returns the cached clone of url along with commit, cloning the repository or fetching
+// from it if the commit hasn't been seen yet
+func (c *RepositoryCache) Checkout(url string, creds *GitCredentials, commit string) (*git.Repository, *object.Commit, error) {
+	if !plumbing.IsHash(commit) {
+		return nil, nil, fmt.Errorf("commit %s must be a full 40 character SHA", commit)
+	}
+	dir, unlock := c.lock(url)
+	defer unlock()
 
-	return commit.Hash.String(), nil
+	hash := plumbing.NewHash(commit)
+	r, err := git.PlainOpen(dir)
+	if err == nil {
+		if obj, err := r.CommitObject(hash); err == nil {
+			return r, obj, nil
+		}
+	}
+
+	auth, err := creds.authMethod(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	if r == nil {
+		// a partial clone left behind by a failed attempt can't be opened, start over
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, nil, err
+		}
+		r, err = git.PlainClone(dir, true, &git.CloneOptions{URL: url, Auth: auth, Tags: git.AllTags})
+	} else {
+		err = r.Fetch(&git.FetchOptions{Auth: auth, Tags: git.AllTags, Force: true})
+		if err == git.NoErrAlreadyUpToDate {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	obj, err := r.CommitObject(hash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", commit, err)
+	}
+	return r, obj, nil
+}
+
+// Use registers a user of the cache, e.g. a Poller. urls returns the repositories it currently needs.
+func (c *RepositoryCache) Use(urls func() []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.users = append(c.users, urls)
+}
+
+// Prune removes the cached repositories that no user needs anymore, including clones left behind by
+// earlier runs, and returns the number removed
+func (c *RepositoryCache) Prune() (int, error) {
+	c.mu.Lock()
+	users := append([]func() []string{}, c.users...)
+	c.mu.Unlock()
+
+	needed := map[string]bool{}
+	for _, urls := range users {
+		for _, url := range urls() {
+			needed[c.path(url)] = true
+		}
+	}
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	removed := 0
+	for _, e := range entries {
+		dir := filepath.Join(c.dir, e.Name())
+		if !e.IsDir() || needed[dir] {
+			continue
+		}
+		unlock := c.lockDir(dir)
+		err := os.RemoveAll(dir)
+		unlock()
+		if err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Has returns true if commit is in the cached repository for url
+func (c *RepositoryCache) Has(url string, commit string) bool {
+	dir, unlock := c.lock(url)
+	defer unlock()
+
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return false
+	}
+	_, err = r.CommitObject(plumbing.NewHash(commit))
+	return err == nil
+}
+
+// CommitFiles stores files, keyed by slash separated path, as a commit in the cached repository for url so that
+// archives can be used like git repositories. The commit has no parent and a fixed author and time, the same files
+// always produce the same commit.
+func (c *RepositoryCache) CommitFiles(url string, files map[string][]byte) (*git.Repository, *object.Commit, error) {
+	dir, unlock := c.lock(url)
+	defer unlock()
+
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, nil, err
+		}
+		r, err = git.PlainInit(dir, true)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	tree, err := writeTree(r.Storer, files)
+	if err != nil {
+		return nil, nil, err
+	}
+	sig := object.Signature{Name: "layer7-operator", Email: "layer7-operator@localhost", When: time.Unix(0, 0).UTC()}
+	commit := &object.Commit{Author: sig, Committer: sig, Message: "import " + url + "\n", TreeHash: tree}
+	obj := r.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return nil, nil, err
+	}
+	hash, err := r.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := r.Storer.SetReference(plumbing.NewHashReference(plumbing.Master, hash)); err != nil {
+		return nil, nil, err
+	}
+
+	stored, err := r.CommitObject(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, stored, nil
+}
+
+// ImportArchive stores the files of a gzipped tar archive written by ArchiveCommit as a commit in the cached
+// repository for url. Archives of commits made by CommitFiles produce the same commit again.
+func (c *RepositoryCache) ImportArchive(url string, archive []byte) (*git.Repository, *object.Commit, error) {
+	dir, err := os.MkdirTemp("", "archive")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.RemoveAll(dir)
+	dest := filepath.Join(dir, "files")
+	if _, err := untar(bytes.NewReader(archive), dest, newExtractLimits()); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", url, err)
+	}
+	files, err := readFiles(dest)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.CommitFiles(url, files)
+}
+
+// writeTree stores files as blobs and trees and returns the hash of the root tree
+func writeTree(s storer.EncodedObjectStorer, files map[string][]byte) (plumbing.Hash, error) {
+	entries := []object.TreeEntry{}
+	dirs := map[string]map[string][]byte{}
+	for name, contents := range files {
+		if i := strings.Index(name, "/"); i >= 0 {
+			if dirs[name[:i]] == nil {
+				dirs[name[:i]] = map[string][]byte{}
+			}
+			dirs[name[:i]][name[i+1:]] = contents
+			continue
+		}
+		blob := s.NewEncodedObject()
+		blob.SetType(plumbing.BlobObject)
+		w, err := blob.Writer()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		if _, err := w.Write(contents); err != nil {
+			return plumbing.ZeroHash, err
+		}
+		w.Close()
+		hash, err := s.SetEncodedObject(blob)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		entries = append(entries, object.TreeEntry{Name: name, Mode: filemode.Regular, Hash: hash})
+	}
+	for name, dirFiles := range dirs {
+		hash, err := writeTree(s, dirFiles)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		entries = append(entries, object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: hash})
+	}
+
+	// git sorts tree entries by name, with directories compared as if they ended in a slash
+	sortName := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+		return e.Name
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return sortName(entries[i]) < sortName(entries[j])
+	})
+
+	tree := &object.Tree{Entries: entries}
+	obj := s.NewEncodedObject()
+	if err := tree.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return s.SetEncodedObject(obj)
+}
+
+// lock locks the cached repository for url and returns its directory and a function that unlocks it
+func (c *RepositoryCache) lock(url string) (string, func()) {
+	dir := c.path(url)
+	return dir, c.lockDir(dir)
+}
+
+// path returns the directory holding the clone of url
+func (c *RepositoryCache) path(url string) string {
+	sum := sha1.Sum([]byte(url))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+func (c *RepositoryCache) lockDir(dir string) func() {
+	c.mu.Lock()
+	lock, ok := c.locks[dir]
+	if !ok {
+		lock = &sync.Mutex{}
+		c.locks[dir] = lock
+	}
+	c.mu.Unlock()
+	lock.Lock()
+	return lock.Unlock
+}
+
+// BundleDirectoryChanged returns true if the files under bundleDirectory differ between since and commit,
+// or if since can't be found in the repository
+func BundleDirectoryChanged(r *git.Repository, commit *object.Commit, bundleDirectory string, since string) (bool, error) {
+	tree, err := bundleTree(commit, bundleDirectory)
+	if err != nil {
+		return false, err
+	}
+	if since == "" {
+		return true, nil
+	}
+	sinceCommit, err := r.CommitObject(plumbing.NewHash(since))
+	if err != nil {
+		return true, nil
+	}
+	sinceTree, err := bundleTree(sinceCommit, bundleDirectory)
+	if err != nil {
+		return true, nil
+	}
+	return sinceTree.Hash != tree.Hash, nil
+}
+
+// BundleChange is a bundle file that differs between two commits. From is nil for added
+// files and To is nil for deleted files.
+type BundleChange struct {
+	Name string
+	From []byte
+	To   []byte
+}
+
+// GetBundles returns the .bundle files found under bundleDirectory at commit, keyed by path.
+// If since is a commit that exists in the repository only bundles that were added or
+// modified after it are returned, otherwise every bundle is returned.
+func GetBundles(r *git.Repository, commit *object.Commit, bundleDirectory string, since string) (map[string][]byte, error) {
+	changes, err := GetBundleChanges(r, commit, bundleDirectory, since, ".bundle")
+	if err != nil {
+		return nil, err
+	}
+
+	bundles := map[string][]byte{}
+	for _, c := range changes {
+		if c.To != nil {
+			bundles[c.Name] = c.To
+		}
+	}
+	return bundles, nil
+}
+
+// GetBundleChanges returns the files ending in suffix under bundleDirectory that changed between since and commit.
+// If since is empty or can't be found in the repository every file at commit is returned as an addition.
+func GetBundleChanges(r *git.Repository, commit *object.Commit, bundleDirectory string, since string, suffix string) ([]BundleChange, error) {
+	tree, err := bundleTree(commit, bundleDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	var sinceTree *object.Tree
+	if since != "" {
+		if sinceCommit, err := r.CommitObject(plumbing.NewHash(since)); err == nil {
+			sinceTree, _ = bundleTree(sinceCommit, bundleDirectory)
+		}
+	}
+
+	bundleChanges := []BundleChange{}
+	if sinceTree == nil {
+		err = tree.Files().ForEach(func(f *object.File) error {
+			if !strings.HasSuffix(f.Name, suffix) {
+				return nil
+			}
+			contents, err := f.Contents()
+			if err != nil {
+				return err
+			}
+			bundleChanges = append(bundleChanges, BundleChange{Name: f.Name, To: []byte(contents)})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return bundleChanges, nil
+	}
+
+	changes, err := object.DiffTree(sinceTree, tree)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range changes {
+		action, err := c.Action()
+		if err != nil {
+			return nil, err
+		}
+		from, to, err := c.Files()
+		if err != nil {
+			return nil, err
+		}
+
+		bundleChange := BundleChange{}
+		switch action {
+		case merkletrie.Delete:
+			bundleChange.Name = c.From.Name
+		default:
+			bundleChange.Name = c.To.Name
+		}
+		if !strings.HasSuffix(bundleChange.Name, suffix) {
+			continue
+		}
+
+		if from != nil {
+			contents, err := from.Contents()
+			if err != nil {
+				return nil, err
+			}
+			bundleChange.From = []byte(contents)
+		}
+		if to != nil {
+			contents, err := to.Contents()
+			if err != nil {
+				return nil, err
+			}
+			bundleChange.To = []byte(contents)
+		}
+		bundleChanges = append(bundleChanges, bundleChange)
+	}
+
+	return bundleChanges, nil
+}
+
+func bundleTree(commit *object.Commit, bundleDirectory string) (*object.Tree, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	dir := strings.Trim(bundleDirectory, "/")
+	if dir == "" || dir == "." {
+		return tree, nil
+	}
+	return tree.Tree(dir)
+}
+
+// RepositoryStorageKey is the key of the ArchiveCommit archive in the storage Secret of a Repository
+const RepositoryStorageKey = "repository.tar.gz"
+
+// ArchiveCommit returns a gzipped tar archive of the files at commit. Entries are written in tree order with
+// the commit time as their modification time so the same commit always produces the same archive.
+func ArchiveCommit(commit *object.Commit) ([]byte, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	err = tree.Files().ForEach(func(f *object.File) error {
+		contents, err := f.Contents()
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{
+			Name:    f.Name,
+			Mode:    0644,
+			Size:    int64(len(contents)),
+			ModTime: commit.Committer.When,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err = tw.Write([]byte(contents))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
@@ -0,0 +1,104 @@
+package util
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func commitFile(t *testing.T, r *git.Repository, dir string, name string, contents string) plumbing.Hash {
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Add(name); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := w.Commit("update "+name, &git.CommitOptions{Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestRepositoryRefsAndCache(t *testing.T) {
+	dir := t.TempDir()
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := commitFile(t, r, dir, "bundles/a.bundle", "a")
+	if _, err := r.CreateTag("v1", first, &git.CreateTagOptions{Message: "v1", Tagger: &object.Signature{Name: "test", When: time.Now()}}); err != nil {
+		t.Fatal(err)
+	}
+	second := commitFile(t, r, dir, "README.md", "readme")
+
+	for ref, want := range map[GitReference]plumbing.Hash{
+		{}:                       second,
+		{Branch: "master"}:       second,
+		{Tag: "v1"}:              first,
+		{Commit: first.String()}: first,
+	} {
+		commit, err := GetLatestCommit(dir, nil, ref)
+		if err != nil || commit != want.String() {
+			t.Fatalf("%s: expected %s, got %s: %v", ref.Name(), want, commit, err)
+		}
+	}
+	if _, err := GetLatestCommit(dir, nil, GitReference{Branch: "missing"}); err == nil {
+		t.Fatal("expected missing branch to fail")
+	}
+	if _, err := GetLatestCommit(dir, nil, GitReference{Commit: first.String()[:7]}); err == nil {
+		t.Fatal("expected an abbreviated commit to fail")
+	}
+
+	cache := NewRepositoryCache(t.TempDir())
+	repo, commit, err := cache.Checkout(dir, nil, second.String())
+	if err != nil || commit.Hash != second {
+		t.Fatalf("unexpected checkout %v: %v", commit, err)
+	}
+	if changed, err := BundleDirectoryChanged(repo, commit, "bundles", first.String()); err != nil || changed {
+		t.Fatalf("expected bundle directory to be unchanged: %v", err)
+	}
+
+	third := commitFile(t, r, dir, "bundles/b.bundle", "b")
+	repo, commit, err = cache.Checkout(dir, nil, third.String())
+	if err != nil || commit.Hash != third {
+		t.Fatalf("expected cache to fetch %s, got %v: %v", third, commit, err)
+	}
+	if changed, err := BundleDirectoryChanged(repo, commit, "bundles", second.String()); err != nil || !changed {
+		t.Fatalf("expected bundle directory to change: %v", err)
+	}
+
+	// clones are kept while a user of the cache needs them
+	needed := []string{dir}
+	cache.Use(func() []string { return needed })
+	if removed, err := cache.Prune(); err != nil || removed != 0 || !cache.Has(dir, third.String()) {
+		t.Fatalf("expected the needed clone to be kept, removed %d: %v", removed, err)
+	}
+
+	archive, err := ArchiveCommit(commit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ArchiveCommit(commit)
+	if err != nil || !bytes.Equal(archive, again) {
+		t.Fatalf("expected archives of the same commit to match: %v", err)
+	}
+
+	needed = nil
+	if removed, err := cache.Prune(); err != nil || removed != 1 || cache.Has(dir, third.String()) {
+		t.Fatalf("expected the unneeded clone to be pruned, removed %d: %v", removed, err)
+	}
+}
@@ -0,0 +1,415 @@
+package util
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/graphman"
+)
+
+// policyTypes maps Restman policy types to Graphman policy types
+var policyTypes = map[string]string{
+	"Include":  "FRAGMENT",
+	"Internal": "INTERNAL",
+	"Global":   "GLOBAL",
+}
+
+// trustedFor maps Restman trusted certificate properties to Graphman trustedFor values
+var trustedFor = []struct {
+	property string
+	value    string
+}{
+	{"trustedForSsl", "SSL"},
+	{"trustedForSigningServerCerts", "SIGNING_SERVER_CERTS"},
+	{"trustedForSigningClientCerts", "SIGNING_CLIENT_CERTS"},
+	{"trustedAsSamlIssuer", "SAML_ISSUER"},
+	{"trustedAsSamlAttestingEntity", "SAML_ATTESTING_ENTITY"},
+}
+
+// ToGraphman converts a Restman bundle to a Graphman bundle. Folders become the folderPath of the policies and
+// services in them. Entity types Graphman bundles don't carry, encrypted passwords and private keys, which
+// Restman bundles only carry encrypted, can't be converted and are reported as errors.
+func ToGraphman(b *Bundle) (graphman.Bundle, error) {
+	folders := map[string]Folder{}
+	for _, item := range b.References.Item {
+		if item.Resource.Folder != nil {
+			folders[item.ID] = *item.Resource.Folder
+		}
+	}
+	folderPath := func(id string) (string, error) {
+		p := ""
+		for depth := 0; id != "" && id != RootFolderID; depth++ {
+			f, ok := folders[id]
+			if !ok {
+				return "", fmt.Errorf("folder %s isn't in the bundle", id)
+			}
+			if depth > len(folders) {
+				return "", fmt.Errorf("folder %s is its own parent", id)
+			}
+			p = "/" + f.Name + p
+			id = f.FolderID
+		}
+		if p == "" {
+			return "/", nil
+		}
+		return p, nil
+	}
+
+	gb := graphman.Bundle{}
+	for _, item := range b.References.Item {
+		r := item.Resource
+		var name string
+		var entity map[string]interface{}
+		var err error
+		switch {
+		case r.Folder != nil:
+			continue
+		case r.ClusterProperty != nil:
+			name, entity = "clusterProperties", map[string]interface{}{"name": r.ClusterProperty.Name, "value": r.ClusterProperty.Value}
+		case r.StoredPassword != nil:
+			name, entity, err = storedPasswordToGraphman(r.StoredPassword)
+		case r.TrustedCertificate != nil:
+			name, entity, err = trustedCertificateToGraphman(r.TrustedCertificate)
+		case r.Policy != nil:
+			name = "policies"
+			entity, err = policyToGraphman(r.Policy, folderPath)
+		case r.Service != nil:
+			name = "services"
+			entity, err = serviceToGraphman(r.Service, folderPath)
+		default:
+			err = fmt.Errorf("%s can't be converted to Graphman", item.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", item.Type, item.Name, err)
+		}
+		gb[name] = append(gb[name], entity)
+	}
+	return gb, nil
+}
+
+func storedPasswordToGraphman(p *StoredPassword) (string, map[string]interface{}, error) {
+	if p.Password == nil || p.Password.BundleKey != "" {
+		return "", nil, fmt.Errorf("password isn't in plain text")
+	}
+	secret := map[string]interface{}{
+		"name":                 p.Name,
+		"secret":               p.Password.Value,
+		"secretType":           "PASSWORD",
+		"variableReferencable": p.Properties.Get("usageFromVariable") == "true",
+	}
+	if d := p.Properties.Get("description"); d != "" {
+		secret["description"] = d
+	}
+	return "secrets", secret, nil
+}
+
+func trustedCertificateToGraphman(c *TrustedCertificate) (string, map[string]interface{}, error) {
+	der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(c.CertificateData.Encoded))
+	if err != nil {
+		return "", nil, err
+	}
+	thumbprint := sha1.Sum(der)
+	uses := []interface{}{}
+	for _, t := range trustedFor {
+		if c.Properties.Get(t.property) == "true" {
+			uses = append(uses, t.value)
+		}
+	}
+	revocation := "NONE"
+	if c.Properties.Get("revocationCheckingEnabled") == "true" {
+		revocation = "USE_DEFAULT"
+	}
+	return "trustedCerts", map[string]interface{}{
+		"name":                      c.Name,
+		"certBase64":                base64.StdEncoding.EncodeToString(der),
+		"thumbprintSha1":            base64.StdEncoding.EncodeToString(thumbprint[:]),
+		"verifyHostname":            c.Properties.Get("verifyHostname") == "true",
+		"trustAnchor":               c.Properties.Get("trustAnchor") == "true",
+		"trustedFor":                uses,
+		"revocationCheckPolicyType": revocation,
+	}, nil
+}
+
+func policyToGraphman(p *Policy, folderPath func(string) (string, error)) (map[string]interface{}, error) {
+	policyType, ok := policyTypes[p.Detail.PolicyType]
+	if !ok {
+		return nil, fmt.Errorf("policy type %s can't be converted to Graphman", p.Detail.PolicyType)
+	}
+	xml, ok := policyXML(p.Resources)
+	if !ok {
+		return nil, fmt.Errorf("no policy XML")
+	}
+	folder, err := folderPath(p.Detail.FolderID)
+	if err != nil {
+		return nil, err
+	}
+	policy := map[string]interface{}{
+		"name":       p.Detail.Name,
+		"folderPath": folder,
+		"policyType": policyType,
+		"policy":     map[string]interface{}{"xml": xml},
+	}
+	if p.Guid != "" {
+		policy["guid"] = p.Guid
+	}
+	return policy, nil
+}
+
+func serviceToGraphman(s *Service, folderPath func(string) (string, error)) (map[string]interface{}, error) {
+	if s.Detail.ServiceMappings.HttpMapping == nil {
+		return nil, fmt.Errorf("no HTTP mapping")
+	}
+	for _, rs := range s.Resources {
+		if rs.Tag == "wsdl" {
+			return nil, fmt.Errorf("SOAP services can't be converted to Graphman")
+		}
+	}
+	xml, ok := policyXML(s.Resources)
+	if !ok {
+		return nil, fmt.Errorf("no policy XML")
+	}
+	folder, err := folderPath(s.Detail.FolderID)
+	if err != nil {
+		return nil, err
+	}
+	verbs := []interface{}{}
+	for _, v := range s.Detail.ServiceMappings.HttpMapping.Verbs {
+		verbs = append(verbs, v)
+	}
+	return map[string]interface{}{
+		"name":           s.Detail.Name,
+		"resolutionPath": s.Detail.ServiceMappings.HttpMapping.UrlPattern,
+		"enabled":        s.Detail.Enabled == "true",
+		"folderPath":     folder,
+		"methodsAllowed": verbs,
+		"serviceType":    "WEB_API",
+		"policy":         map[string]interface{}{"xml": xml},
+	}, nil
+}
+
+func policyXML(resources []ResourceSet) (string, bool) {
+	for _, rs := range resources {
+		if rs.Tag != "policy" {
+			continue
+		}
+		for _, r := range rs.Resources {
+			if r.Type == "policy" {
+				return r.Content, true
+			}
+		}
+	}
+	return "", false
+}
+
+// FromGraphman converts a Graphman bundle to a Restman bundle. Entities are mapped by name (services by id),
+// the folders of policies and services are created if they don't exist. Private keys and secrets other than
+// passwords can't be converted and are reported as errors.
+func FromGraphman(gb graphman.Bundle) (*Bundle, error) {
+	b := &Bundle{XMLNS: bundleNamespace}
+	folders := map[string]string{"/": RootFolderID}
+	items := []Item{}
+	mappings := []Mapping{}
+	mapByName := func(name string) *Properties {
+		return &Properties{Property: []Property{{Key: "MapBy", StringValue: "name"}, {Key: "MapTo", StringValue: name}}}
+	}
+	folderID := func(p string) string {
+		p = path.Clean("/" + p)
+		if id, ok := folders[p]; ok {
+			return id
+		}
+		parts := strings.Split(strings.Trim(p, "/"), "/")
+		current := "/"
+		for _, part := range parts {
+			parent := folders[current]
+			current = path.Join(current, part)
+			if _, ok := folders[current]; !ok {
+				folders[current] = entityId("FOLDER", current)
+				items = append(items, Item{Name: part, ID: folders[current], Type: "FOLDER", Resource: Resource{Folder: &Folder{ID: folders[current], FolderID: parent, Name: part}}})
+				mappings = append(mappings, Mapping{Action: "NewOrExisting", SrcId: folders[current], Type: "FOLDER",
+					Properties: &Properties{Property: []Property{{Key: "MapBy", StringValue: "path"}, {Key: "MapTo", StringValue: current}}}})
+			}
+		}
+		return folders[p]
+	}
+
+	names := make([]string, 0, len(gb))
+	for name := range gb {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch name {
+		case "trustedCerts", "secrets", "clusterProperties", "policies", "services":
+		default:
+			return nil, fmt.Errorf("%s can't be converted to Restman", name)
+		}
+	}
+
+	// dependencies first, as Graphman installs them
+	for _, name := range []string{"trustedCerts", "secrets", "clusterProperties", "policies", "services"} {
+		for _, e := range gb[name] {
+			key := stringField(e, "name")
+			var item Item
+			var err error
+			switch name {
+			case "trustedCerts":
+				item, err = trustedCertFromGraphman(e)
+			case "secrets":
+				item, err = secretFromGraphman(e)
+			case "clusterProperties":
+				item = Item{Type: "CLUSTER_PROPERTY", Resource: Resource{ClusterProperty: &ClusterProperty{Name: key, Value: stringField(e, "value")}}}
+			case "policies":
+				item, err = policyFromGraphman(e, folderID)
+			case "services":
+				key = stringField(e, "resolutionPath")
+				item, err = serviceFromGraphman(e, folderID)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", name, key, err)
+			}
+
+			item.Name = stringField(e, "name")
+			item.ID = stringField(e, "goid")
+			if item.ID == "" {
+				item.ID = entityId(item.Type, key)
+			}
+			setResourceID(&item)
+			items = append(items, item)
+			mapping := Mapping{Action: "NewOrUpdate", SrcId: item.ID, Type: item.Type}
+			if name != "services" {
+				mapping.Properties = mapByName(item.Name)
+			}
+			mappings = append(mappings, mapping)
+		}
+	}
+
+	b.References.Item = items
+	b.Mappings.Mapping = mappings
+	return b, nil
+}
+
+func setResourceID(item *Item) {
+	r := item.Resource
+	switch {
+	case r.ClusterProperty != nil:
+		r.ClusterProperty.ID = item.ID
+	case r.StoredPassword != nil:
+		r.StoredPassword.ID = item.ID
+	case r.TrustedCertificate != nil:
+		r.TrustedCertificate.ID = item.ID
+	case r.Policy != nil:
+		r.Policy.ID = item.ID
+		r.Policy.Detail.ID = item.ID
+	case r.Service != nil:
+		r.Service.ID = item.ID
+		r.Service.Detail.ID = item.ID
+	}
+}
+
+func trustedCertFromGraphman(e map[string]interface{}) (Item, error) {
+	encoded := stringField(e, "certBase64")
+	if _, err := base64.StdEncoding.DecodeString(encoded); err != nil || encoded == "" {
+		return Item{}, fmt.Errorf("invalid certBase64")
+	}
+	uses := map[string]bool{}
+	if list, ok := e["trustedFor"].([]interface{}); ok {
+		for _, u := range list {
+			uses[fmt.Sprint(u)] = true
+		}
+	}
+	props := []Property{
+		{Key: "revocationCheckingEnabled", BooleanValue: fmt.Sprint(stringField(e, "revocationCheckPolicyType") != "" && stringField(e, "revocationCheckPolicyType") != "NONE")},
+		{Key: "trustAnchor", BooleanValue: fmt.Sprint(e["trustAnchor"] == true)},
+	}
+	for _, t := range trustedFor {
+		props = append(props, Property{Key: t.property, BooleanValue: fmt.Sprint(uses[t.value])})
+	}
+	props = append(props, Property{Key: "verifyHostname", BooleanValue: fmt.Sprint(e["verifyHostname"] == true)})
+	return Item{Type: "TRUSTED_CERT", Resource: Resource{TrustedCertificate: &TrustedCertificate{
+		Name:            stringField(e, "name"),
+		CertificateData: CertificateData{Encoded: encoded},
+		Properties:      &Properties{Property: props},
+	}}}, nil
+}
+
+func secretFromGraphman(e map[string]interface{}) (Item, error) {
+	if t := stringField(e, "secretType"); t != "" && t != "PASSWORD" {
+		return Item{}, fmt.Errorf("secret type %s can't be converted to Restman", t)
+	}
+	props := []Property{{Key: "type", StringValue: "Password"}, {Key: "usageFromVariable", BooleanValue: fmt.Sprint(e["variableReferencable"] == true)}}
+	if d := stringField(e, "description"); d != "" {
+		props = append([]Property{{Key: "description", StringValue: d}}, props...)
+	}
+	return Item{Type: "SECURE_PASSWORD", Resource: Resource{StoredPassword: &StoredPassword{
+		Name:       stringField(e, "name"),
+		Password:   &Password{Value: stringField(e, "secret")},
+		Properties: &Properties{Property: props},
+	}}}, nil
+}
+
+func policyFromGraphman(e map[string]interface{}, folderID func(string) string) (Item, error) {
+	policyType := ""
+	for restman, gm := range policyTypes {
+		if gm == stringField(e, "policyType") || (gm == "FRAGMENT" && stringField(e, "policyType") == "") {
+			policyType = restman
+		}
+	}
+	if policyType == "" {
+		return Item{}, fmt.Errorf("policy type %s can't be converted to Restman", stringField(e, "policyType"))
+	}
+	xml, err := graphmanPolicyXML(e)
+	if err != nil {
+		return Item{}, err
+	}
+	guid := stringField(e, "guid")
+	return Item{Type: "POLICY", Resource: Resource{Policy: &Policy{
+		Guid:      guid,
+		Detail:    PolicyDetail{Guid: guid, FolderID: folderID(stringField(e, "folderPath")), Name: stringField(e, "name"), PolicyType: policyType},
+		Resources: []ResourceSet{{Tag: "policy", Resources: []ResourceDocument{{Type: "policy", Content: xml}}}},
+	}}}, nil
+}
+
+func serviceFromGraphman(e map[string]interface{}, folderID func(string) string) (Item, error) {
+	if t := stringField(e, "serviceType"); t != "" && t != "WEB_API" {
+		return Item{}, fmt.Errorf("service type %s can't be converted to Restman", t)
+	}
+	xml, err := graphmanPolicyXML(e)
+	if err != nil {
+		return Item{}, err
+	}
+	verbs := []string{}
+	if list, ok := e["methodsAllowed"].([]interface{}); ok {
+		for _, v := range list {
+			verbs = append(verbs, fmt.Sprint(v))
+		}
+	}
+	return Item{Type: "SERVICE", Resource: Resource{Service: &Service{
+		Detail: ServiceDetail{
+			FolderID:        folderID(stringField(e, "folderPath")),
+			Name:            stringField(e, "name"),
+			Enabled:         fmt.Sprint(e["enabled"] != false),
+			ServiceMappings: ServiceMappings{HttpMapping: &HttpMapping{UrlPattern: stringField(e, "resolutionPath"), Verbs: verbs}},
+		},
+		Resources: []ResourceSet{{Tag: "policy", Resources: []ResourceDocument{{Type: "policy", Content: xml}}}},
+	}}}, nil
+}
+
+func graphmanPolicyXML(e map[string]interface{}) (string, error) {
+	if policy, ok := e["policy"].(map[string]interface{}); ok {
+		if xml, ok := policy["xml"].(string); ok && xml != "" {
+			return xml, nil
+		}
+	}
+	return "", fmt.Errorf("no policy XML")
+}
+
+func stringField(e map[string]interface{}, field string) string {
+	if s, ok := e[field].(string); ok {
+		return s
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+package util
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+)
+
+// SecurePasswords returns the stored passwords the operator creates on the Gateway, those listed in
+// spec.app.securePasswords followed by the passwords of its JDBC connections
+func SecurePasswords(gw *securityv1.Gateway) []securityv1.SecurePassword {
+	securePasswords := append([]securityv1.SecurePassword{}, gw.Spec.App.SecurePasswords...)
+	for _, c := range gw.Spec.App.JDBCConnections {
+		securePasswords = append(securePasswords, securityv1.SecurePassword{
+			Name:        JDBCPasswordName(c.Name),
+			SecretName:  c.SecretName,
+			Key:         JDBCPasswordKey(c),
+			Description: "password of JDBC connection " + c.Name,
+		})
+	}
+	return securePasswords
+}
+
+// JDBCPasswordName is the secure password holding the password of a JDBC connection
+func JDBCPasswordName(connection string) string {
+	return "jdbc-" + connection
+}
+
+// JDBCUsernameKey returns the Secret key holding the user of a JDBC connection
+func JDBCUsernameKey(c securityv1.JDBCConnection) string {
+	if c.UsernameKey == "" {
+		return "username"
+	}
+	return c.UsernameKey
+}
+
+// JDBCPasswordKey returns the Secret key holding the password of a JDBC connection
+func JDBCPasswordKey(c securityv1.JDBCConnection) string {
+	if c.PasswordKey == "" {
+		return "password"
+	}
+	return c.PasswordKey
+}
+
+// BuildJDBCBundle builds a bundle creating or updating each JDBC connection, usernames holds their users by
+// connection name. Passwords refer to the secure password named by JDBCPasswordName.
+func BuildJDBCBundle(connections []securityv1.JDBCConnection, usernames map[string]string) ([]byte, error) {
+	sorted := append([]securityv1.JDBCConnection{}, connections...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	bundle := Bundle{XMLNS: bundleNamespace}
+	for i, c := range sorted {
+		if i > 0 && sorted[i-1].Name == c.Name {
+			return nil, fmt.Errorf("jdbc connection %s is listed more than once", c.Name)
+		}
+		if c.DriverClass == "" || c.JdbcURL == "" {
+			return nil, fmt.Errorf("jdbc connection %s needs a driverClass and jdbcUrl", c.Name)
+		}
+		minPool, maxPool := c.MinimumPoolSize, c.MaximumPoolSize
+		if minPool == 0 {
+			minPool = 3
+		}
+		if maxPool == 0 {
+			maxPool = 15
+		}
+		if minPool > maxPool {
+			return nil, fmt.Errorf("jdbc connection %s has a minimumPoolSize above its maximumPoolSize", c.Name)
+		}
+
+		connectionProperties := []Property{{
+			Key:         "password",
+			StringValue: "${secpass." + JDBCPasswordName(c.Name) + ".plaintext}",
+		}, {
+			Key:         "user",
+			StringValue: usernames[c.Name],
+		}}
+		for _, p := range c.Properties {
+			if p.Name == "user" || p.Name == "password" {
+				return nil, fmt.Errorf("jdbc connection %s sets %s in properties, it's read from secret %s", c.Name, p.Name, c.SecretName)
+			}
+			connectionProperties = append(connectionProperties, Property{Key: p.Name, StringValue: p.Value})
+		}
+
+		id := entityId("JDBC_CONNECTION", c.Name)
+		bundle.References.Item = append(bundle.References.Item, Item{Name: c.Name,
+			ID:   id,
+			Type: "JDBC_CONNECTION",
+			Resource: Resource{JDBCConnection: &JDBCConnection{
+				ID:      id,
+				Name:    c.Name,
+				Enabled: "true",
+				Properties: &Properties{Property: []Property{
+					{Key: "maximumPoolSize", IntegerValue: strconv.Itoa(int(maxPool))},
+					{Key: "minimumPoolSize", IntegerValue: strconv.Itoa(int(minPool))},
+				}},
+				Extension: &JDBCExtension{
+					DriverClass:          c.DriverClass,
+					JdbcUrl:              c.JdbcURL,
+					ConnectionProperties: &Properties{Property: connectionProperties},
+				},
+			}},
+		})
+		bundle.Mappings.Mapping = append(bundle.Mappings.Mapping, Mapping{
+			Action: "NewOrUpdate",
+			SrcId:  id,
+			Type:   "JDBC_CONNECTION",
+			Properties: &Properties{Property: []Property{{
+				Key:         "MapBy",
+				StringValue: "name",
+			}, {
+				Key:         "MapTo",
+				StringValue: c.Name,
+			}}},
+		})
+	}
+
+	return xml.Marshal(bundle)
+}
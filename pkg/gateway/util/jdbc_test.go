@@ -0,0 +1,55 @@
+package util
+
+import (
+	"testing"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+)
+
+func TestJDBCBundle(t *testing.T) {
+	gw := &securityv1.Gateway{}
+	gw.Spec.App.SecurePasswords = []securityv1.SecurePassword{{Name: "api", SecretName: "backend", Key: "api-key"}}
+	gw.Spec.App.JDBCConnections = []securityv1.JDBCConnection{{
+		Name:            "orders",
+		DriverClass:     "com.mysql.cj.jdbc.Driver",
+		JdbcURL:         "jdbc:mysql://mysql:3306/orders",
+		MaximumPoolSize: 30,
+		Properties:      []securityv1.JDBCProperty{{Name: "EnableCancelTimeout", Value: "true"}},
+		SecretName:      "orders-db",
+		PasswordKey:     "db-password",
+	}}
+
+	securePasswords := SecurePasswords(gw)
+	if len(securePasswords) != 2 || securePasswords[1].Name != "jdbc-orders" || securePasswords[1].SecretName != "orders-db" || securePasswords[1].Key != "db-password" {
+		t.Fatalf("unexpected secure passwords %+v", securePasswords)
+	}
+
+	b, err := BuildJDBCBundle(gw.Spec.App.JDBCConnections, map[string]string{"orders": "gateway"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := ValidateBundle(b); len(errs) > 0 {
+		t.Fatalf("generated bundle is invalid: %v", errs)
+	}
+	bundle, err := ParseBundle(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := bundle.References.Item[0].Resource.JDBCConnection
+	if c == nil || c.Extension.JdbcUrl != "jdbc:mysql://mysql:3306/orders" {
+		t.Fatalf("unexpected JDBC connection %+v", c)
+	}
+	for key, value := range map[string]string{"password": "${secpass.jdbc-orders.plaintext}", "user": "gateway", "EnableCancelTimeout": "true"} {
+		if got := c.Extension.ConnectionProperties.Get(key); got != value {
+			t.Fatalf("expected connection property %s to be %s, got %s", key, value, got)
+		}
+	}
+	if c.Properties.Get("minimumPoolSize") != "3" || c.Properties.Get("maximumPoolSize") != "30" {
+		t.Fatalf("unexpected pool sizes %+v", c.Properties)
+	}
+
+	gw.Spec.App.JDBCConnections[0].Properties = []securityv1.JDBCProperty{{Name: "password", Value: "plain"}}
+	if _, err := BuildJDBCBundle(gw.Spec.App.JDBCConnections, map[string]string{"orders": "gateway"}); err == nil {
+		t.Fatal("expected an error for a password set in properties")
+	}
+}
@@ -0,0 +1,129 @@
+package util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/graphman"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// DefaultKeystoreID is the id of the Gateway's software keystore
+const DefaultKeystoreID = "00000000000000000000000000000002"
+
+// BuildPrivateKeyBundle builds a Graphman bundle setting each private key from the data of its
+// kubernetes.io/tls Secret, tlsSecrets holds the Secret data by Secret name
+func BuildPrivateKeyBundle(privateKeys []securityv1.PrivateKey, tlsSecrets map[string]map[string][]byte) ([]byte, error) {
+	sorted := append([]securityv1.PrivateKey{}, privateKeys...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Alias < sorted[j].Alias })
+
+	keys := []graphman.Key{}
+	defaultKey := ""
+	for i, pk := range sorted {
+		if i > 0 && sorted[i-1].Alias == pk.Alias {
+			return nil, fmt.Errorf("private key %s is listed more than once", pk.Alias)
+		}
+		if pk.DefaultSSLKey {
+			if defaultKey != "" {
+				return nil, fmt.Errorf("private keys %s and %s are both the default SSL key", defaultKey, pk.Alias)
+			}
+			defaultKey = pk.Alias
+		}
+		data, ok := tlsSecrets[pk.SecretName]
+		if !ok {
+			return nil, fmt.Errorf("no data for secret %s of private key %s", pk.SecretName, pk.Alias)
+		}
+		key, err := tlsKey(pk.Alias, data[corev1.TLSPrivateKeyKey], data[corev1.TLSCertKey])
+		if err != nil {
+			return nil, fmt.Errorf("private key %s: %w", pk.Alias, err)
+		}
+		if pk.DefaultSSLKey {
+			key.UsageTypes = []string{"SSL"}
+		}
+		keys = append(keys, key)
+	}
+
+	return json.Marshal(map[string][]graphman.Key{"keys": keys})
+}
+
+// tlsKey converts a PEM private key and certificate chain, leaf first, to a Graphman key in the default keystore
+func tlsKey(alias string, keyPEM []byte, certPEM []byte) (graphman.Key, error) {
+	key := graphman.Key{Alias: alias, KeystoreId: DefaultKeystoreID}
+
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return key, errors.New("tls.key has no PEM private key")
+	}
+	privateKey, err := parsePrivateKey(block.Bytes)
+	if err != nil {
+		return key, err
+	}
+
+	certs := []*x509.Certificate{}
+	for rest := certPEM; ; {
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return key, err
+		}
+		certs = append(certs, cert)
+		key.CertChain = append(key.CertChain, string(pem.EncodeToMemory(block)))
+	}
+	if len(certs) == 0 {
+		return key, errors.New("tls.crt has no PEM certificates")
+	}
+
+	publicKey, ok := privateKey.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !publicKey.Equal(certs[0].PublicKey) {
+		return key, errors.New("tls.key doesn't match the first certificate in tls.crt")
+	}
+
+	switch privateKey.(type) {
+	case *rsa.PrivateKey:
+		key.KeyType = "RSA"
+	case *ecdsa.PrivateKey:
+		key.KeyType = "EC"
+	default:
+		return key, fmt.Errorf("unsupported private key type %T", privateKey)
+	}
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return key, err
+	}
+	key.Pem = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}))
+	key.SubjectDn = certs[0].Subject.String()
+	return key, nil
+}
+
+// parsePrivateKey reads PKCS#8, PKCS#1 and SEC 1 (EC) encoded keys
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("tls.key isn't a PKCS#8, PKCS#1 or EC private key")
+}
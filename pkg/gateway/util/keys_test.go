@@ -0,0 +1,90 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+	"github.com/Layer7-Community/layer7-operator/pkg/gateway/graphman"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func tlsSecretData(t *testing.T, cn string, key interface{}, keyPEM *pem.Block) map[string][]byte {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	var public interface{}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		public = &k.PublicKey
+	case *ecdsa.PrivateKey:
+		public = &k.PublicKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, public, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string][]byte{
+		corev1.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		corev1.TLSPrivateKeyKey: pem.EncodeToMemory(keyPEM),
+	}
+}
+
+func TestPrivateKeyBundle(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecDER, _ := x509.MarshalECPrivateKey(ecKey)
+	tlsSecrets := map[string]map[string][]byte{
+		"ssl-tls": tlsSecretData(t, "gateway.example.com", rsaKey, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+		"mtls":    tlsSecretData(t, "client", ecKey, &pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}),
+	}
+
+	privateKeys := []securityv1.PrivateKey{
+		{Alias: "ssl", SecretName: "ssl-tls", DefaultSSLKey: true},
+		{Alias: "client", SecretName: "mtls"},
+	}
+	b, err := BuildPrivateKeyBundle(privateKeys, tlsSecrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle := map[string][]graphman.Key{}
+	if err := json.Unmarshal(b, &bundle); err != nil {
+		t.Fatal(err)
+	}
+	keys := bundle["keys"]
+	if len(keys) != 2 || keys[0].Alias != "client" || keys[1].Alias != "ssl" {
+		t.Fatalf("expected keys client and ssl, got %+v", keys)
+	}
+	if keys[0].KeyType != "EC" || len(keys[0].UsageTypes) != 0 {
+		t.Fatalf("unexpected client key %+v", keys[0])
+	}
+	if keys[1].KeyType != "RSA" || keys[1].SubjectDn != "CN=gateway.example.com" || keys[1].KeystoreId != DefaultKeystoreID || len(keys[1].UsageTypes) != 1 {
+		t.Fatalf("unexpected ssl key %+v", keys[1])
+	}
+	if _, err := graphman.ParseBundle(b); err != nil {
+		t.Fatal(err)
+	}
+
+	privateKeys[1].DefaultSSLKey = true
+	if _, err := BuildPrivateKeyBundle(privateKeys, tlsSecrets); err == nil {
+		t.Fatal("expected an error for two default SSL keys")
+	}
+
+	tlsSecrets["ssl-tls"][corev1.TLSCertKey] = tlsSecrets["mtls"][corev1.TLSCertKey]
+	if _, err := BuildPrivateKeyBundle(privateKeys[:1], tlsSecrets); err == nil {
+		t.Fatal("expected an error for a key that doesn't match its certificate")
+	}
+}
@@ -0,0 +1,364 @@
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// DockerConfigJSONKey is the key of registry credentials in kubernetes.io/dockerconfigjson Secrets, the type
+// of Secret used for imagePullSecrets
+const DockerConfigJSONKey = ".dockerconfigjson"
+
+// manifestMediaTypes are the manifests accepted when resolving an OCI artifact
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// ociTitleAnnotation names the file a layer holds in artifacts pushed with oras
+const ociTitleAnnotation = "org.opencontainers.image.title"
+
+type ociManifest struct {
+	Layers []struct {
+		MediaType   string            `json:"mediaType"`
+		Digest      string            `json:"digest"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"layers"`
+}
+
+// ociRegistry is a repository in an OCI registry, e.g. registry.example.com/bundles/gateway
+type ociRegistry struct {
+	base       string
+	host       string
+	repository string
+	creds      *GitCredentials
+	token      string
+}
+
+func newOCIRegistry(ref string, creds *GitCredentials) (*ociRegistry, error) {
+	scheme := "https"
+	switch {
+	case strings.HasPrefix(ref, "http://"):
+		scheme = "http"
+		ref = strings.TrimPrefix(ref, "http://")
+	case strings.HasPrefix(ref, "https://"):
+		ref = strings.TrimPrefix(ref, "https://")
+	default:
+		ref = strings.TrimPrefix(ref, "oci://")
+	}
+	parts := strings.SplitN(strings.Trim(ref, "/"), "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("%s: expected registry/repository", ref)
+	}
+	return &ociRegistry{base: scheme + "://" + parts[0], host: parts[0], repository: parts[1], creds: creds}, nil
+}
+
+// GetOCIDigest returns the digest of the manifest the artifact tag resolves to. Pinned digests are
+// returned as they are once the registry has accepted the credentials.
+func GetOCIDigest(ref string, creds *GitCredentials, archive ArchiveReference) (string, error) {
+	reg, err := newOCIRegistry(ref, creds)
+	if err != nil {
+		return "", err
+	}
+	_, digest, err := reg.manifest(archive)
+	if err != nil {
+		return "", err
+	}
+	return digest, nil
+}
+
+// GetOCIFiles pulls the artifact and returns the files its layers contain keyed by their slash separated path.
+// Layers that are .tar.gz or .zip archives are extracted, other layers are files named by their title annotation.
+// Later layers replace files of earlier ones.
+func GetOCIFiles(ref string, creds *GitCredentials, archive ArchiveReference) (map[string][]byte, error) {
+	reg, err := newOCIRegistry(ref, creds)
+	if err != nil {
+		return nil, err
+	}
+	manifest, _, err := reg.manifest(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "oci")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	dest := filepath.Join(dir, "files")
+
+	// layers are extracted into the same directory, the limits apply to the artifact as a whole
+	limits := newExtractLimits()
+	for i, layer := range manifest.Layers {
+		data, err := reg.blob(layer.Digest)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+			_, err = untar(bytes.NewReader(data), dest, limits)
+		case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+			src := filepath.Join(dir, fmt.Sprintf("layer%d.zip", i))
+			if err = os.WriteFile(src, data, 0600); err == nil {
+				_, err = unzip(src, dest, limits)
+			}
+		case layer.Annotations[ociTitleAnnotation] != "":
+			var fpath string
+			fpath, err = archivePath(dest, layer.Annotations[ociTitleAnnotation])
+			if err == nil {
+				err = limits.entry()
+			}
+			if err == nil {
+				if err = os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err == nil {
+					err = writeLimited(fpath, data, limits)
+				}
+			}
+		default:
+			err = fmt.Errorf("unsupported layer %s of type %s", layer.Digest, layer.MediaType)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ref, err)
+		}
+	}
+	return readFiles(dest)
+}
+
+// manifest fetches the manifest of the artifact and returns it along with its digest
+func (reg *ociRegistry) manifest(archive ArchiveReference) (*ociManifest, string, error) {
+	reference := archive.Digest
+	if reference == "" {
+		reference = archive.Tag
+	}
+	if reference == "" {
+		reference = "latest"
+	}
+
+	header := http.Header{"Accept": []string{strings.Join(manifestMediaTypes, ", ")}}
+	data, resp, err := reg.get("/v2/"+reg.repository+"/manifests/"+reference, header)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(data)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	if archive.Digest != "" && digest != archive.Digest {
+		return nil, "", fmt.Errorf("%s: manifest digest %s doesn't match %s", reg.repository, digest, archive.Digest)
+	}
+	if d := resp.Header.Get("Docker-Content-Digest"); d != "" && d != digest {
+		return nil, "", fmt.Errorf("%s: manifest digest %s doesn't match %s", reg.repository, digest, d)
+	}
+
+	manifest := &ociManifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, "", err
+	}
+	return manifest, digest, nil
+}
+
+// blob fetches a blob and verifies its digest
+func (reg *ociRegistry) blob(digest string) ([]byte, error) {
+	data, _, err := reg.get("/v2/"+reg.repository+"/blobs/"+digest, nil)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if "sha256:"+hex.EncodeToString(sum[:]) != digest {
+		return nil, fmt.Errorf("%s: blob doesn't match its digest", digest)
+	}
+	return data, nil
+}
+
+// get sends a GET request to the registry, authenticating as the registry asks when the request is rejected
+func (reg *ociRegistry) get(path string, header http.Header) ([]byte, *http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(http.MethodGet, reg.base+path, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if reg.token != "" {
+			req.Header.Set("Authorization", reg.token)
+		}
+
+		resp, err := archiveClient.Do(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxArchiveSize+1))
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized && attempt == 0:
+			if err := reg.authenticate(resp.Header.Get("WWW-Authenticate")); err != nil {
+				return nil, nil, err
+			}
+			continue
+		case resp.StatusCode == http.StatusUnauthorized:
+			err = transport.ErrAuthenticationRequired
+		case resp.StatusCode == http.StatusForbidden:
+			err = transport.ErrAuthorizationFailed
+		case resp.StatusCode != http.StatusOK:
+			err = fmt.Errorf("unexpected response %s", resp.Status)
+		case len(data) > maxArchiveSize:
+			err = fmt.Errorf("larger than %d bytes", maxArchiveSize)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s%s: %w", reg.host, path, err)
+		}
+		return data, resp, nil
+	}
+}
+
+// authenticate answers a Basic or Bearer challenge from the registry
+func (reg *ociRegistry) authenticate(challenge string) error {
+	username, password := reg.credentials()
+	scheme, params := parseChallenge(challenge)
+	switch scheme {
+	case "basic":
+		if username == "" && password == "" {
+			return fmt.Errorf("%s: %w", reg.host, transport.ErrAuthenticationRequired)
+		}
+		reg.token = "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+		return nil
+	case "bearer":
+	default:
+		return fmt.Errorf("%s: unsupported authentication challenge %q", reg.host, challenge)
+	}
+
+	u, err := url.Parse(params["realm"])
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + reg.repository + ":pull"
+	}
+	q.Set("scope", scope)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	if username != "" || password != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := archiveClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("%s: %w", reg.host, transport.ErrAuthenticationRequired)
+	case resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%s: %w", reg.host, transport.ErrAuthorizationFailed)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("%s: unexpected token response %s", reg.host, resp.Status)
+	}
+
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return err
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	reg.token = "Bearer " + token.Token
+	return nil
+}
+
+// credentials returns the username and password for the registry from a docker config, falling back to
+// the username and password or token of the Secret
+func (reg *ociRegistry) credentials() (string, string) {
+	if reg.creds == nil {
+		return "", ""
+	}
+	if len(reg.creds.DockerConfig) > 0 {
+		config := struct {
+			Auths map[string]struct {
+				Username string `json:"username"`
+				Password string `json:"password"`
+				Auth     string `json:"auth"`
+			} `json:"auths"`
+		}{}
+		if err := json.Unmarshal(reg.creds.DockerConfig, &config); err == nil {
+			for server, auth := range config.Auths {
+				host := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://"), "/")
+				if host != reg.host && !(reg.host == "registry-1.docker.io" && strings.HasPrefix(host, "index.docker.io")) {
+					continue
+				}
+				if auth.Auth != "" {
+					if decoded, err := base64.StdEncoding.DecodeString(auth.Auth); err == nil {
+						if parts := strings.SplitN(string(decoded), ":", 2); len(parts) == 2 {
+							return parts[0], parts[1]
+						}
+					}
+				}
+				return auth.Username, auth.Password
+			}
+		}
+	}
+	if reg.creds.Token != "" {
+		return reg.creds.Username, reg.creds.Token
+	}
+	return reg.creds.Username, reg.creds.Password
+}
+
+// parseChallenge parses a WWW-Authenticate header, e.g. Bearer realm="https://auth.example.com/token",service="registry"
+func parseChallenge(challenge string) (string, map[string]string) {
+	params := map[string]string{}
+	parts := strings.SplitN(strings.TrimSpace(challenge), " ", 2)
+	scheme := strings.ToLower(parts[0])
+	if len(parts) == 1 {
+		return scheme, params
+	}
+	rest := parts[1]
+	for rest != "" {
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+		value := ""
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else if comma := strings.Index(rest, ","); comma >= 0 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+		params[key] = value
+		rest = strings.TrimLeft(rest, ", ")
+	}
+	return scheme, params
+}
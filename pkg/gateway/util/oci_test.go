@@ -0,0 +1,87 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestOCIArtifacts(t *testing.T) {
+	layer := tarGz(t, map[string]string{"bundles/a.json": "{}"})
+	file := []byte("<l7:Bundle/>")
+	blobs := map[string][]byte{digestOf(layer): layer, digestOf(file): file}
+	manifest, _ := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"layers": []map[string]interface{}{
+			{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "digest": digestOf(layer), "size": len(layer)},
+			{"mediaType": "application/vnd.layer7.bundle", "digest": digestOf(file), "size": len(file), "annotations": map[string]string{ociTitleAnnotation: "bundles/b.bundle"}},
+		},
+	})
+
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if user, pass, _ := r.BasicAuth(); user != "robot" || pass != "s3cr3t" || r.URL.Query().Get("scope") != "repository:bundles/gateway:pull" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"token": "t0ken"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+s.URL+`/token",service="registry",scope="repository:bundles/gateway:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/v2/bundles/gateway/manifests/1.0" || r.URL.Path == "/v2/bundles/gateway/manifests/"+digestOf(manifest):
+			w.Header().Set("Docker-Content-Digest", digestOf(manifest))
+			w.Write(manifest)
+		case strings.HasPrefix(r.URL.Path, "/v2/bundles/gateway/blobs/"):
+			blob, ok := blobs[strings.TrimPrefix(r.URL.Path, "/v2/bundles/gateway/blobs/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+			}
+			w.Write(blob)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	host := strings.TrimPrefix(s.URL, "http://")
+	config, _ := json.Marshal(map[string]interface{}{"auths": map[string]interface{}{host: map[string]string{"auth": base64.StdEncoding.EncodeToString([]byte("robot:s3cr3t"))}}})
+	creds := NewGitCredentials(map[string][]byte{DockerConfigJSONKey: config})
+	ref := "http://" + host + "/bundles/gateway"
+
+	if _, err := GetArchiveRevision(ref, nil, ArchiveReference{Type: "oci", Tag: "1.0"}); !IsGitAuthError(err) {
+		t.Fatalf("expected authentication error, got %v", err)
+	}
+	digest, err := GetArchiveRevision(ref, creds, ArchiveReference{Type: "oci", Tag: "1.0"})
+	if err != nil || digest != digestOf(manifest) {
+		t.Fatalf("unexpected digest %s: %v", digest, err)
+	}
+
+	files, err := GetArchiveFiles(ref, creds, ArchiveReference{Type: "oci", Digest: digest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || string(files["bundles/a.json"]) != "{}" || string(files["bundles/b.bundle"]) != string(file) {
+		t.Fatalf("unexpected files %v", files)
+	}
+
+	if _, err := GetArchiveFiles(ref, creds, ArchiveReference{Type: "oci", Tag: "1.0", Digest: "sha256:" + strings.Repeat("0", 64)}); err == nil {
+		t.Fatal("expected a missing digest to fail")
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"crypto/tls"
 	"errors"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"strings"
 )
@@ -27,15 +26,15 @@ func RestCall(method string, URL string, contentType string, data []byte, userna
 		return []byte{}, err
 	}
 
+	defer resp.Body.Close()
+
 	if resp.StatusCode != 200 && resp.StatusCode != 201 {
 		return []byte{}, errors.New(resp.Status)
 	}
 
-	defer resp.Body.Close()
-
 	bytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatalln(err)
+		return []byte{}, err
 	}
 	return bytes, nil
 }
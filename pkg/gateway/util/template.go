@@ -0,0 +1,102 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"regexp"
+	"sort"
+	"strings"
+
+	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
+)
+
+// DefaultTemplatePrefix is the placeholder prefix of Gateways that don't set one, it keeps Gateway context
+// variables such as ${request.url.path} and ${secpass.*} out of templating
+const DefaultTemplatePrefix = "env."
+
+// Placeholders resolves the ${<prefix><name>} placeholders of bundles
+type Placeholders struct {
+	prefix  string
+	pattern *regexp.Regexp
+}
+
+// NewPlaceholders compiles the placeholder pattern for prefix
+func NewPlaceholders(prefix string) *Placeholders {
+	return &Placeholders{
+		prefix:  prefix,
+		pattern: regexp.MustCompile(`\$\{` + regexp.QuoteMeta(prefix) + `[-._a-zA-Z0-9]+\}`),
+	}
+}
+
+// Placeholder returns the placeholder of name
+func (p *Placeholders) Placeholder(name string) string {
+	return "${" + p.prefix + name + "}"
+}
+
+// Resolve replaces each placeholder in data with escape(values[name]). It returns the sorted names without a
+// value, their placeholders are left in place.
+func (p *Placeholders) Resolve(data []byte, values map[string]string, escape func(string) string) ([]byte, []string) {
+	missing := map[string]bool{}
+	resolved := p.pattern.ReplaceAllFunc(data, func(placeholder []byte) []byte {
+		name := string(placeholder[len("${")+len(p.prefix) : len(placeholder)-1])
+		value, ok := values[name]
+		if !ok {
+			missing[name] = true
+			return placeholder
+		}
+		return []byte(escape(value))
+	})
+
+	unresolved := make([]string, 0, len(missing))
+	for name := range missing {
+		unresolved = append(unresolved, name)
+	}
+	sort.Strings(unresolved)
+	return resolved, unresolved
+}
+
+// BundleEscaper returns the escaping of values resolved into a bundle file, XML for Restman bundles and
+// JSON for Graphman bundles. Values are inserted as is into other files.
+func BundleEscaper(file string) func(string) string {
+	switch {
+	case strings.HasSuffix(file, ".bundle"):
+		return EscapeXML
+	case strings.HasSuffix(file, ".json"):
+		return EscapeJSON
+	}
+	return func(value string) string { return value }
+}
+
+// EscapeXML escapes a value for XML text or attributes
+func EscapeXML(value string) string {
+	b := bytes.Buffer{}
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
+
+// EscapeJSON escapes a value for a JSON string
+func EscapeJSON(value string) string {
+	b := bytes.Buffer{}
+	e := json.NewEncoder(&b)
+	e.SetEscapeHTML(false)
+	e.Encode(value)
+	quoted := strings.TrimSuffix(b.String(), "\n")
+	return quoted[1 : len(quoted)-1]
+}
+
+// TemplateValuesFromSecrets returns true if a template value is read from a Secret, resolved ConfigMap
+// bundles are then stored in Secrets
+func TemplateValuesFromSecrets(gw *securityv1.Gateway) bool {
+	for _, v := range gw.Spec.App.Templating.Values {
+		if v.SecretName != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolvedBundleName is the ConfigMap or Secret holding the resolved copy of a ConfigMap bundle
+func ResolvedBundleName(gw *securityv1.Gateway, bundle string) string {
+	return gw.Name + "-" + bundle + "-resolved"
+}
@@ -0,0 +1,34 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolvePlaceholders(t *testing.T) {
+	values := map[string]string{"backend.host": "orders.prod.svc", "apiKey": `a<b&"c"`}
+
+	data := []byte(`<l7:StringValue>https://${tpl.backend.host}/${request.url.path}?key=${tpl.apiKey}&amp;env=${tpl.env}&amp;zone=${tpl.env}</l7:StringValue>`)
+	resolved, unresolved := NewPlaceholders("tpl.").Resolve(data, values, BundleEscaper("service.bundle"))
+	expected := `<l7:StringValue>https://orders.prod.svc/${request.url.path}?key=a&lt;b&amp;&#34;c&#34;&amp;env=${tpl.env}&amp;zone=${tpl.env}</l7:StringValue>`
+	if string(resolved) != expected {
+		t.Fatalf("expected %s, got %s", expected, resolved)
+	}
+	if !reflect.DeepEqual(unresolved, []string{"env"}) {
+		t.Fatalf("expected env to be unresolved, got %v", unresolved)
+	}
+
+	placeholders := NewPlaceholders(DefaultTemplatePrefix)
+	resolved, unresolved = placeholders.Resolve([]byte(`{"password":"${env.apiKey}"}`), values, BundleEscaper("secrets.json"))
+	if string(resolved) != `{"password":"a<b&\"c\""}` || len(unresolved) != 0 {
+		t.Fatalf("unexpected JSON resolution %s %v", resolved, unresolved)
+	}
+
+	_, unresolved = placeholders.Resolve([]byte("${request.url.path} ${secpass.db.plaintext} ${env.region}"), values, BundleEscaper("policy.xml"))
+	if !reflect.DeepEqual(unresolved, []string{"region"}) {
+		t.Fatalf("expected Gateway context variables to be left alone, got %v", unresolved)
+	}
+	if placeholder := placeholders.Placeholder("region"); placeholder != "${env.region}" {
+		t.Fatalf("unexpected placeholder %s", placeholder)
+	}
+}
@@ -1,9 +1,12 @@
 package util
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sort"
 	"strings"
 
 	securityv1 "github.com/Layer7-Community/layer7-operator/api/v1"
@@ -18,6 +21,40 @@ func DefaultLabels(gw *securityv1.Gateway) map[string]string {
 	}
 }
 
+// Checksum returns a hex encoded SHA-1 checksum of data that doesn't depend on map order
+func Checksum(data map[string][]byte) string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha1.New()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write(data[k])
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// RepositoryReferences returns the Repositories referenced by the Gateway in the order they're applied,
+// ascending Priority, then list order
+func RepositoryReferences(gw *securityv1.Gateway) []securityv1.RepositoryReference {
+	refs := append([]securityv1.RepositoryReference{}, gw.Spec.App.RepositoryReferences...)
+	sort.SliceStable(refs, func(i, j int) bool {
+		return refs[i].Priority < refs[j].Priority
+	})
+	return refs
+}
+
+// RepositoryBundleName is the ConfigMap, or Secret if template values are read from Secrets, holding the
+// bundles of a Repository referenced with the mount method
+func RepositoryBundleName(gw *securityv1.Gateway, repository string) string {
+	return gw.Name + "-repository-" + repository + "-bundle"
+}
+
 // Contains returns true if string array contains string
 func Contains(arr []string, str string) bool {
 	for _, a := range arr {
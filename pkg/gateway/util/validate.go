@@ -0,0 +1,68 @@
+package util
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ValidateBundle checks that a Restman bundle is well formed XML with an l7:Bundle root, that its items and
+// mappings have unique ids and that every mapping that may create an entity refers to an item in the bundle.
+// It returns a description of each problem found.
+func ValidateBundle(data []byte) []string {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return []string{err.Error()}
+		}
+	}
+
+	b, err := ParseBundle(data)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	problems := []string{}
+	ids := map[string]bool{}
+	for i, item := range b.References.Item {
+		if item.ID == "" || item.Type == "" {
+			problems = append(problems, fmt.Sprintf("item %d (%s) has no id or type", i+1, item.Name))
+			continue
+		}
+		if ids[item.ID] {
+			problems = append(problems, fmt.Sprintf("duplicate item id %s (%s %s)", item.ID, item.Type, item.Name))
+		}
+		ids[item.ID] = true
+	}
+
+	srcIds := map[string]bool{}
+	for i, m := range b.Mappings.Mapping {
+		if m.SrcId == "" || m.Action == "" {
+			problems = append(problems, fmt.Sprintf("mapping %d (%s) has no srcId or action", i+1, m.Type))
+			continue
+		}
+		if srcIds[m.SrcId] {
+			problems = append(problems, fmt.Sprintf("duplicate mapping srcId %s (%s)", m.SrcId, m.Type))
+		}
+		srcIds[m.SrcId] = true
+
+		// entities that must already exist on the Gateway or are deleted aren't included in the bundle
+		creates := false
+		switch m.Action {
+		case "NewOrUpdate", "AlwaysCreateNew":
+			creates = true
+		case "NewOrExisting":
+			creates = strings.TrimSpace(m.Properties.Get("FailOnNew")) != "true"
+		}
+		if creates && !ids[m.SrcId] {
+			problems = append(problems, fmt.Sprintf("mapping srcId %s (%s) doesn't refer to an item in the bundle", m.SrcId, m.Type))
+		}
+	}
+	return problems
+}
@@ -1,7 +1,11 @@
 package util
 
 import (
+	"archive/tar"
 	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -9,9 +13,68 @@ import (
 	"strings"
 )
 
+// Limits on what extracting an archive may write, so a small zip or tar bomb can't fill the disk
+const (
+	maxExtractedSize    = 256 << 20
+	maxExtractedEntries = 10000
+)
+
+// ErrArchiveTooLarge is returned when an archive extracts to more than the size or entry limit
+var ErrArchiveTooLarge = errors.New("archive is too large to extract")
+
+// extractLimits is the remaining size and number of entries extraction may write, shared by the
+// archives extracted into the same directory
+type extractLimits struct {
+	size    int64
+	entries int
+}
+
+func newExtractLimits() *extractLimits {
+	return &extractLimits{size: maxExtractedSize, entries: maxExtractedEntries}
+}
+
+// entry counts an archive entry against the limit
+func (l *extractLimits) entry() error {
+	l.entries--
+	if l.entries < 0 {
+		return fmt.Errorf("%w: more than %d entries", ErrArchiveTooLarge, maxExtractedEntries)
+	}
+	return nil
+}
+
+// copy copies src to dst, failing once the size limit is exceeded
+func (l *extractLimits) copy(dst io.Writer, src io.Reader) error {
+	n, err := io.Copy(dst, io.LimitReader(src, l.size+1))
+	l.size -= n
+	if err != nil {
+		return err
+	}
+	if l.size < 0 {
+		return fmt.Errorf("%w: more than %d bytes", ErrArchiveTooLarge, maxExtractedSize)
+	}
+	return nil
+}
+
+// writeLimited writes data to a new file at fpath, counting it against the size limit
+func writeLimited(fpath string, data []byte, limits *extractLimits) error {
+	f, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	err = limits.copy(f, bytes.NewReader(data))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
 // Unzip will decompress a zip archive, moving all files and folders
 // within the zip file (parameter 1) to an output directory (parameter 2).
 func Unzip(src string, dest string) ([]string, error) {
+	return unzip(src, dest, newExtractLimits())
+}
+
+func unzip(src string, dest string, limits *extractLimits) ([]string, error) {
 
 	var filenames []string
 
@@ -22,20 +85,23 @@ func Unzip(src string, dest string) ([]string, error) {
 	defer r.Close()
 
 	for _, f := range r.File {
+		if err := limits.entry(); err != nil {
+			return filenames, err
+		}
 
 		// Store filename/path for returning and using later on
-		fpath := filepath.Join(dest, f.Name)
-
-		// Check for ZipSlip. More Info: http://bit.ly/2MsjAWE
-		if !strings.HasPrefix(fpath, filepath.Clean(dest)+string(os.PathSeparator)) {
-			return filenames, fmt.Errorf("%s: illegal file path", fpath)
+		fpath, err := archivePath(dest, f.Name)
+		if err != nil {
+			return filenames, err
 		}
 
 		filenames = append(filenames, fpath)
 
 		if f.FileInfo().IsDir() {
 			// Make Folder
-			os.MkdirAll(fpath, os.ModePerm)
+			if err := os.MkdirAll(fpath, os.ModePerm); err != nil {
+				return filenames, err
+			}
 			continue
 		}
 
@@ -54,7 +120,7 @@ func Unzip(src string, dest string) ([]string, error) {
 			return filenames, err
 		}
 
-		_, err = io.Copy(outFile, rc)
+		err = limits.copy(outFile, rc)
 
 		// Close the file without defer to close before next iteration of loop
 		outFile.Close()
@@ -66,3 +132,76 @@ func Unzip(src string, dest string) ([]string, error) {
 	}
 	return filenames, nil
 }
+
+// Untar will decompress a gzipped tar archive, moving all regular files
+// and folders within it to an output directory.
+func Untar(src io.Reader, dest string) ([]string, error) {
+	return untar(src, dest, newExtractLimits())
+}
+
+func untar(src io.Reader, dest string, limits *extractLimits) ([]string, error) {
+
+	var filenames []string
+
+	gz, err := gzip.NewReader(src)
+	if err != nil {
+		return filenames, err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return filenames, nil
+		}
+		if err != nil {
+			return filenames, err
+		}
+		if err := limits.entry(); err != nil {
+			return filenames, err
+		}
+
+		fpath, err := archivePath(dest, hdr.Name)
+		if err != nil {
+			return filenames, err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(fpath, os.ModePerm); err != nil {
+				return filenames, err
+			}
+			continue
+		case tar.TypeReg:
+		default:
+			// links and devices aren't bundles
+			continue
+		}
+
+		filenames = append(filenames, fpath)
+		if err = os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
+			return filenames, err
+		}
+
+		outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return filenames, err
+		}
+		err = limits.copy(outFile, tr)
+		outFile.Close()
+		if err != nil {
+			return filenames, err
+		}
+	}
+}
+
+// archivePath returns the path of an archive entry under dest, rejecting entries that would
+// be written outside of it. Check for ZipSlip. More Info: http://bit.ly/2MsjAWE
+func archivePath(dest string, name string) (string, error) {
+	fpath := filepath.Join(dest, name)
+	if !strings.HasPrefix(fpath, filepath.Clean(dest)+string(os.PathSeparator)) {
+		return "", fmt.Errorf("%s: illegal file path", fpath)
+	}
+	return fpath, nil
+}